@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	roleRead  = "read"
+	roleAdmin = "admin"
+)
+
+// APIKeyConfig is one client credential. Admin keys can also read. Keys are
+// usually given as ${VAR} references so they stay out of the file.
+type APIKeyConfig struct {
+	Name string `json:"name" yaml:"name"`
+	Key  string `json:"key" yaml:"key"`
+	Role string `json:"role" yaml:"role"`
+}
+
+type AuthConfig struct {
+	Keys []APIKeyConfig `json:"keys" yaml:"keys"`
+}
+
+func (c *AuthConfig) validate() error {
+	names := make(map[string]bool)
+	keys := make(map[string]bool)
+	for i, key := range c.Keys {
+		if key.Name == "" {
+			return fmt.Errorf("auth.keys[%d]: name is required", i)
+		}
+		if names[key.Name] {
+			return fmt.Errorf("auth.keys[%d]: duplicate key name %q", i, key.Name)
+		}
+		names[key.Name] = true
+		if key.Key == "" {
+			return fmt.Errorf("auth key %s: key is empty", key.Name)
+		}
+		if keys[key.Key] {
+			return fmt.Errorf("auth key %s: key is shared with another entry", key.Name)
+		}
+		keys[key.Key] = true
+		if key.Role != roleRead && key.Role != roleAdmin {
+			return fmt.Errorf("auth key %s: role must be %q or %q", key.Name, roleRead, roleAdmin)
+		}
+	}
+	return nil
+}
+
+type principalKey struct{}
+
+// Authenticator checks API keys. Keys are held as SHA-256 digests so the
+// lookup doesn't leak how much of a guessed key matched.
+type Authenticator struct {
+	keys map[[32]byte]APIKeyConfig
+}
+
+func NewAuthenticator(cfg AuthConfig) *Authenticator {
+	a := &Authenticator{keys: make(map[[32]byte]APIKeyConfig)}
+	for _, key := range cfg.Keys {
+		a.keys[sha256.Sum256([]byte(key.Key))] = key
+	}
+	return a
+}
+
+// Require admits requests carrying a key with role, or admin, in
+// "Authorization: Bearer <key>".
+func (a *Authenticator) Require(role string) func(http.Handler) http.Handler {
+	return a.require(role, false)
+}
+
+// RequireUpgrade is Require for the websocket endpoint. Browsers can't set
+// headers on a websocket upgrade, so ?api_key= is accepted there as well;
+// nowhere else, as query strings end up in proxy and access logs.
+func (a *Authenticator) RequireUpgrade(role string) func(http.Handler) http.Handler {
+	return a.require(role, true)
+}
+
+func (a *Authenticator) require(role string, allowQuery bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			presented := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if presented == "" && allowQuery {
+				presented = r.URL.Query().Get("api_key")
+			}
+			if presented == "" {
+				a.reject(w, r, http.StatusUnauthorized, "API key required")
+				return
+			}
+			key, ok := a.keys[sha256.Sum256([]byte(presented))]
+			if !ok {
+				a.reject(w, r, http.StatusUnauthorized, "invalid API key")
+				return
+			}
+			if key.Role != role && key.Role != roleAdmin {
+				log.Printf("Key %s lacks role %s for %s %s from %s", key.Name, role, r.Method, r.URL.Path, clientIP(r))
+				writeError(w, http.StatusForbidden, "API key lacks the "+role+" role")
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, key.Name)))
+		})
+	}
+}
+
+func (a *Authenticator) reject(w http.ResponseWriter, r *http.Request, status int, message string) {
+	log.Printf("Rejected %s %s from %s: %s", r.Method, r.URL.Path, clientIP(r), message)
+	writeError(w, status, message)
+}
+
+// requestActor names the API key a request was authenticated with.
+func requestActor(r *http.Request) string {
+	name, _ := r.Context().Value(principalKey{}).(string)
+	return name
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// originChecker admits websocket upgrades from the allowed origins. Clients
+// that send no Origin aren't browsers and are admitted; with no allowlist
+// only same-host pages are.
+func originChecker(allowed []string) func(r *http.Request) bool {
+	allow := make(map[string]bool, len(allowed))
+	for _, origin := range allowed {
+		allow[strings.ToLower(strings.TrimRight(origin, "/"))] = true
+	}
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		if allow["*"] || allow[strings.ToLower(origin)] {
+			return true
+		}
+		if len(allow) == 0 {
+			u, err := url.Parse(origin)
+			if err == nil && strings.EqualFold(u.Host, r.Host) {
+				return true
+			}
+		}
+		log.Printf("Rejected websocket upgrade from origin %s at %s", origin, clientIP(r))
+		return false
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	testReadKey  = "read-key"
+	testAdminKey = "admin-key"
+)
+
+func newTestAuthenticator() *Authenticator {
+	return NewAuthenticator(AuthConfig{Keys: []APIKeyConfig{
+		{Name: "dashboard", Key: testReadKey, Role: roleRead},
+		{Name: "ops", Key: testAdminKey, Role: roleAdmin},
+	}})
+}
+
+func TestAuthenticatorRequire(t *testing.T) {
+	tests := []struct {
+		name   string
+		role   string
+		header string
+		query  string
+		want   int
+		actor  string
+	}{
+		{"no key", roleRead, "", "", http.StatusUnauthorized, ""},
+		{"unknown key", roleRead, "Bearer nope", "", http.StatusUnauthorized, ""},
+		{"key without bearer", roleRead, "Basic " + testReadKey, "", http.StatusUnauthorized, ""},
+		{"read key on read", roleRead, "Bearer " + testReadKey, "", http.StatusOK, "dashboard"},
+		{"admin key on read", roleRead, "Bearer " + testAdminKey, "", http.StatusOK, "ops"},
+		{"read key on admin", roleAdmin, "Bearer " + testReadKey, "", http.StatusForbidden, ""},
+		{"admin key on admin", roleAdmin, "Bearer " + testAdminKey, "", http.StatusOK, "ops"},
+		{"key in query", roleRead, "", testReadKey, http.StatusUnauthorized, ""},
+		{"admin key in query on admin", roleAdmin, "", testAdminKey, http.StatusUnauthorized, ""},
+	}
+	auth := newTestAuthenticator()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, actor := serveAuthenticated(auth.Require(tt.role), tt.header, tt.query)
+			if code != tt.want || actor != tt.actor {
+				t.Errorf("HTTP %d as %q, want %d as %q", code, actor, tt.want, tt.actor)
+			}
+		})
+	}
+}
+
+// The websocket endpoint also takes the key from the query string, as
+// browsers can't set headers on an upgrade.
+func TestAuthenticatorRequireUpgrade(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		query  string
+		want   int
+	}{
+		{"no key", "", "", http.StatusUnauthorized},
+		{"unknown key in query", "", "nope", http.StatusUnauthorized},
+		{"key in query", "", testReadKey, http.StatusOK},
+		{"key in header", "Bearer " + testReadKey, "", http.StatusOK},
+		{"header wins over query", "Bearer nope", testReadKey, http.StatusUnauthorized},
+	}
+	auth := newTestAuthenticator()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code, _ := serveAuthenticated(auth.RequireUpgrade(roleRead), tt.header, tt.query); code != tt.want {
+				t.Errorf("HTTP %d, want %d", code, tt.want)
+			}
+		})
+	}
+
+	readOnly := NewAuthenticator(AuthConfig{Keys: []APIKeyConfig{{Name: "dashboard", Key: testReadKey, Role: roleRead}}})
+	if code, _ := serveAuthenticated(readOnly.RequireUpgrade(roleAdmin), "", testReadKey); code != http.StatusForbidden {
+		t.Errorf("read key in query on admin: HTTP %d, want 403", code)
+	}
+}
+
+// The API routes are behind Require, so a key in the query is refused there.
+func TestAPIRoutesRefuseKeyInQuery(t *testing.T) {
+	tb := newTestBridge(t)
+	tb.auth = newTestAuthenticator()
+	tb.warmup.Run(context.Background(), nil)
+	router := mux.NewRouter()
+	tb.registerAPIRoutes(router)
+	tb.registerTokenRoutes(router)
+
+	for _, tt := range []struct {
+		path   string
+		header string
+		want   int
+	}{
+		{"/api/transactions?api_key=" + testReadKey, "", http.StatusUnauthorized},
+		{"/api/transactions", "Bearer " + testReadKey, http.StatusOK},
+		{"/admin/tokens?api_key=" + testAdminKey, "", http.StatusUnauthorized},
+		{"/admin/tokens", "Bearer " + testReadKey, http.StatusForbidden},
+		{"/admin/tokens", "Bearer " + testAdminKey, http.StatusOK},
+	} {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("GET %s with %q: HTTP %d, want %d", tt.path, tt.header, rec.Code, tt.want)
+		}
+	}
+}
+
+// serveAuthenticated sends a request through middleware and returns the
+// status and the key name the handler saw.
+func serveAuthenticated(middleware func(http.Handler) http.Handler, header, query string) (int, string) {
+	var actor string
+	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor = requestActor(r)
+	}))
+	target := "/ws"
+	if query != "" {
+		target += "?api_key=" + query
+	}
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if header != "" {
+		req.Header.Set("Authorization", header)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec.Code, actor
+}
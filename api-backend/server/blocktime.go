@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/lru"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	blockTimeCacheSize = 1024
+	blockTimeTimeout   = 5 * time.Second
+)
+
+// blockKey identifies a block by hash as well as chain, so a block replaced
+// by a reorg doesn't lend its timestamp to the one that replaced it.
+type blockKey struct {
+	chain string
+	hash  common.Hash
+}
+
+// blockTime returns when vLog's block was produced. Many logs share a block,
+// so timestamps are cached. If the header can't be fetched the relayer's
+// clock stands in, so a slow endpoint never holds up recording a transfer.
+func (bs *BridgeService) blockTime(chainName string, vLog types.Log) time.Time {
+	key := blockKey{chain: chainName, hash: vLog.BlockHash}
+	if ts, ok := bs.blockTimes.Get(key); ok {
+		return ts
+	}
+
+	ts, err := bs.fetchBlockTime(chainName, vLog.BlockNumber)
+	if err != nil {
+		log.Printf("Failed to fetch time of block %d on %s, using observed time: %v", vLog.BlockNumber, chainName, err)
+		return bs.clock.Now()
+	}
+	bs.blockTimes.Add(key, ts)
+	return ts
+}
+
+func (bs *BridgeService) fetchBlockTime(chainName string, number uint64) (time.Time, error) {
+	client, ok := bs.chains.GetClient(chainName)
+	if !ok {
+		return time.Time{}, fmt.Errorf("no client for chain %s", chainName)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), blockTimeTimeout)
+	defer cancel()
+
+	header, err := client.HeaderByNumber(ctx, new(big.Int).SetUint64(number))
+	if err != nil {
+		rpcErrors.WithLabelValues(chainName, "header_by_number").Inc()
+		return time.Time{}, err
+	}
+	return time.Unix(int64(header.Time), 0), nil
+}
+
+func newBlockTimeCache() *lru.Cache[blockKey, time.Time] {
+	return lru.NewCache[blockKey, time.Time](blockTimeCacheSize)
+}
@@ -3,45 +3,118 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"math/big"
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/lru"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 type BridgeService struct {
-	clients    map[string]*ethclient.Client
-	contracts  map[string]common.Address
+	clock      Clock
+	chains     *ChainRegistry
 	wsUpgrader websocket.Upgrader
 	eventChan  chan BridgeEvent
+	egress     *EgressConfig
+	egressMon  *EgressMonitor
+	events     *EventRegistry
+	listening  map[string][]EventDefinition
+	heads      *HeadCache
+	blockTimes *lru.Cache[blockKey, time.Time]
+	explorers  map[string]ExplorerTemplates
+	hub        *Hub
+	auth       *Authenticator
+	signer     *Signer
+	store      BridgeStore
+
+	confirmations  *ConfirmationTracker
+	pauses         *Pauses
+	tokens         *TokenRegistry
+	limits         *Limits
+	webhooks       *Webhooks
+	webhookCfg     WebhooksConfig
+	delivering     sync.Map
+	tokenMeta      *TokenMetadataCache
+	websocket      WebSocketConfig
+	warmup         *Warmup
+	fromBlocks     map[string]uint64
+	callbacks      []statusCallback
+	chainChecks    *ChainChecks
+	chainChecksCfg ChainChecksConfig
+	callbackQueue  CallbackQueueConfig
+	flushing       sync.Map
+	integrity      *IntegritySampler
+	retry          RetryConfig
+
+	nonces      sync.Map
+	duplicates  atomic.Uint64
+	retrying    sync.Map
+	callbackSeq atomic.Uint64
+
+	listeners   sync.WaitGroup
+	settlements sync.WaitGroup
+	settleMu    sync.Mutex
+	stopping    bool
+
+	// Set by the backfill command. A manual backfill replays an arbitrary
+	// range, so it leaves checkpoints alone; a dry run records nothing.
+	manualBackfill bool
+	dryRun         bool
 }
 
 type BridgeEvent struct {
-	ID          string    `json:"id"`
-	Type        string    `json:"type"`
-	FromChain   string    `json:"fromChain"`
-	ToChain     string    `json:"toChain"`
-	Token       string    `json:"token"`
-	Amount      string    `json:"amount"`
-	Sender      string    `json:"sender"`
-	Recipient   string    `json:"recipient"`
-	TxHash      string    `json:"txHash"`
-	BlockNumber uint64    `json:"blockNumber"`
-	Nonce       string    `json:"nonce"`
-	Status      string    `json:"status"`
-	Timestamp   time.Time `json:"timestamp"`
+	ID            string         `json:"id"`
+	Type          string         `json:"type"`
+	FromChain     string         `json:"fromChain"`
+	ToChain       string         `json:"toChain"`
+	Token         string         `json:"token"`
+	TokenSymbol   string         `json:"tokenSymbol,omitempty"`
+	TokenDecimals *uint8         `json:"tokenDecimals,omitempty"`
+	Amount        string         `json:"amount"`
+	Sender        string         `json:"sender"`
+	Recipient     string         `json:"recipient"`
+	TxHash        string         `json:"txHash"`
+	BlockNumber   uint64         `json:"blockNumber"`
+	BlockHash     string         `json:"blockHash,omitempty"`
+	Confirmation  string         `json:"confirmation,omitempty"`
+	Corridor      string         `json:"corridor,omitempty"`
+	CorridorSeq   uint64         `json:"corridorSeq,omitempty"`
+	Nonce         string         `json:"nonce"`
+	TransferKey   string         `json:"transferKey"`
+	Status        TransferStatus `json:"status"`
+	Error         string         `json:"error,omitempty"`
+	LimitRule     string         `json:"limitRule,omitempty"`
+	Attempts      int            `json:"attempts,omitempty"`
+	NextAttemptAt *time.Time     `json:"nextAttemptAt,omitempty"`
+	Gas           *GasParams     `json:"gas,omitempty"`
+	// Set on the updates sent while a stuck settlement is replaced with a
+	// higher fee.
+	FeeBumps       int    `json:"feeBumps,omitempty"`
+	ReplacedTxHash string `json:"replacedTxHash,omitempty"`
+	// For locks and burns Timestamp is the time of the source block and
+	// ObservedAt when the relayer saw the log; for the rest they coincide.
+	Timestamp  time.Time `json:"timestamp"`
+	ObservedAt time.Time `json:"observedAt"`
+
+	ExplorerLinks *ExplorerLinks `json:"explorerLinks,omitempty"`
 }
 
 type LockEvent struct {
@@ -53,130 +126,395 @@ type LockEvent struct {
 	Nonce       [32]byte
 }
 
+// BurnEvent is emitted on the wrapped-token side when a user bridges back.
+// It has the same layout as LockEvent.
+type BurnEvent LockEvent
+
 func NewBridgeService() *BridgeService {
+	return newBridgeService(realClock{})
+}
+
+// newBridgeService builds the service on clock; tests pass a FakeClock.
+func newBridgeService(clock Clock) *BridgeService {
 	return &BridgeService{
-		clients:   make(map[string]*ethclient.Client),
-		contracts: make(map[string]common.Address),
-		wsUpgrader: websocket.Upgrader{
-			CheckOrigin: func(r *http.Request) bool { return true },
-		},
-		eventChan: make(chan BridgeEvent, 100),
+		clock:      clock,
+		chains:     NewChainRegistry(),
+		listening:  make(map[string][]EventDefinition),
+		eventChan:  make(chan BridgeEvent, 100),
+		egressMon:  NewEgressMonitor(clock),
+		heads:      NewHeadCache(clock),
+		blockTimes: newBlockTimeCache(),
+		explorers:  make(map[string]ExplorerTemplates),
+		hub:        NewHub(clock),
+
+		confirmations: NewConfirmationTracker(),
+		pauses:        NewPauses(),
+		tokens:        NewTokenRegistry(),
+		limits:        NewLimits(),
+		webhooks:      NewWebhooks(),
+		chainChecks:   NewChainChecks(),
+		tokenMeta:     NewTokenMetadataCache(clock),
+		warmup:        NewWarmup(clock),
 	}
 }
 
-func (bs *BridgeService) InitializeClients() error {
-	// Initialize Ethereum client
-	ethRPC := os.Getenv("ETHEREUM_RPC")
-	if ethRPC == "" {
-		ethRPC = "https://mainnet.infura.io/v3/" + os.Getenv("INFURA_API_KEY")
-	}
-	
-	ethClient, err := ethclient.Dial(ethRPC)
+func (bs *BridgeService) InitializeClients(cfg *BridgeConfig) error {
+	events, err := LoadEventRegistry()
 	if err != nil {
-		return fmt.Errorf("failed to connect to Ethereum: %v", err)
+		return err
 	}
-	bs.clients["ethereum"] = ethClient
-	bs.contracts["ethereum"] = common.HexToAddress("0x1234567890123456789012345678901234567890")
+	bs.events = events
 
-	// Initialize Polygon client
-	polygonRPC := os.Getenv("POLYGON_RPC")
-	if polygonRPC == "" {
-		polygonRPC = "https://polygon-rpc.com/"
-	}
-	
-	polygonClient, err := ethclient.Dial(polygonRPC)
+	egress, err := LoadEgressConfig()
 	if err != nil {
-		return fmt.Errorf("failed to connect to Polygon: %v", err)
+		return err
 	}
-	bs.clients["polygon"] = polygonClient
-	bs.contracts["polygon"] = common.HexToAddress("0x2345678901234567890123456789012345678901")
+	bs.egress = egress
+	bs.initCallbacks(cfg.Callbacks)
+	bs.callbackQueue = cfg.CallbackQueue
+	bs.integrity = NewIntegritySampler(cfg.Integrity)
+	bs.retry = cfg.Retry
+	bs.auth = NewAuthenticator(cfg.Auth)
+	bs.websocket = cfg.WebSocket
+	bs.wsUpgrader = websocket.Upgrader{CheckOrigin: originChecker(cfg.WebSocket.AllowedOrigins)}
+	bs.hub.evictAfter = time.Duration(cfg.WebSocket.EvictAfterSeconds) * time.Second
+	bs.chainChecksCfg = cfg.ChainChecks
+
+	for _, chain := range cfg.Chains {
+		client, err := bs.dialChain(chain.Name, chain.Endpoints())
+		if err != nil {
+			return fmt.Errorf("failed to connect to %s: %v", chain.Name, err)
+		}
+		if ok, err := bs.verifyChain(chain, client); err != nil {
+			return err
+		} else if !ok {
+			continue
+		}
 
-	// Initialize BSC client
-	bscRPC := os.Getenv("BSC_RPC")
-	if bscRPC == "" {
-		bscRPC = "https://bsc-dataseed.binance.org/"
-	}
-	
-	bscClient, err := ethclient.Dial(bscRPC)
-	if err != nil {
-		return fmt.Errorf("failed to connect to BSC: %v", err)
+		definitions, err := bs.events.Resolve(chain.ContractVersion, listenedEvents)
+		if err != nil {
+			return fmt.Errorf("invalid event configuration for %s: %v", chain.Name, err)
+		}
+
+		explorer := chain.Explorer
+		if explorer == (ExplorerTemplates{}) {
+			explorer = defaultExplorers[chain.Name]
+		}
+
+		bs.chains.Register(chain, client)
+		bs.listening[chain.Name] = definitions
+		bs.explorers[chain.Name] = explorer
 	}
-	bs.clients["bsc"] = bscClient
-	bs.contracts["bsc"] = common.HexToAddress("0x3456789012345678901234567890123456789012")
 
 	return nil
 }
 
+func (bs *BridgeService) dialChain(chainName string, urls []string) (*FailoverClient, error) {
+	client, err := NewFailoverClient(chainName, urls, bs.clock, func(ctx context.Context, rawURL string) (*ethclient.Client, error) {
+		return bs.egress.DialRPC(ctx, chainName, rawURL)
+	})
+	if err != nil {
+		return nil, err
+	}
+	bs.egressMon.Register(chainName, urls[0], func(ctx context.Context) error {
+		_, err := client.BlockNumber(ctx)
+		return err
+	})
+	return client, nil
+}
+
+const listenRetryDelay = 5 * time.Second
+
+// ListenToChain follows the chain's bridge logs until ctx is cancelled. After
+// a lost subscription or an endpoint switch it resubscribes and backfills
+// from the checkpoint, so nothing emitted in between is missed.
 func (bs *BridgeService) ListenToChain(ctx context.Context, chainName string) {
-	client := bs.clients[chainName]
-	contractAddr := bs.contracts[chainName]
+	contract, ok := bs.chains.GetContract(chainName)
+	if !ok {
+		log.Printf("Not listening to %s: chain is not registered", chainName)
+		return
+	}
+	// Create filter for the bridge events resolved at startup
+	query := bridgeFilterQuery(contract, bs.listening[chainName])
+
+	// The -from-block override only applies to the first backfill.
+	var override *uint64
+	if from, ok := bs.fromBlocks[chainName]; ok {
+		override = &from
+	}
+
+	for {
+		err := bs.listen(ctx, chainName, query, override)
+		if ctx.Err() != nil {
+			return
+		}
+		override = nil
+		if !bs.chains.Has(chainName) {
+			log.Printf("Stopped listening to %s: chain was removed", chainName)
+			return
+		}
+		if errors.Is(err, errEndpointSwitched) {
+			log.Printf("Resubscribing to %s logs on the new endpoint", chainName)
+			continue
+		}
+		log.Printf("Listener for %s stopped, resubscribing in %s: %v", chainName, listenRetryDelay, err)
+		if !Sleep(ctx, bs.clock, listenRetryDelay) {
+			return
+		}
+	}
+}
 
-	// Create filter for Lock events
-	query := ethereum.FilterQuery{
-		Addresses: []common.Address{contractAddr},
-		Topics: [][]common.Hash{
-			{crypto.Keccak256Hash([]byte("Locked(address,address,bytes32,bytes,uint256,bytes32)"))},
-		},
+func (bs *BridgeService) listen(ctx context.Context, chainName string, query ethereum.FilterQuery, override *uint64) error {
+	client, ok := bs.chains.GetClient(chainName)
+	if !ok {
+		return errUnknownChain(chainName)
 	}
+	switched := client.Switched()
 
+	// Subscribe before backfilling so nothing emitted during the backfill
+	// falls between the two; logs seen by both are deduplicated by ID.
 	logs := make(chan types.Log)
 	sub, err := client.SubscribeFilterLogs(ctx, query, logs)
+	if errors.Is(err, rpc.ErrNotificationsUnsupported) {
+		return bs.poll(ctx, chainName, query, override)
+	}
 	if err != nil {
-		log.Printf("Failed to subscribe to %s logs: %v", chainName, err)
-		return
+		rpcErrors.WithLabelValues(chainName, "subscribe_logs").Inc()
+		return fmt.Errorf("failed to subscribe to logs: %v", err)
 	}
 	defer sub.Unsubscribe()
+	subscriptionUp.WithLabelValues(chainName).Set(1)
+	defer subscriptionUp.WithLabelValues(chainName).Set(0)
+
+	if err := bs.backfill(ctx, chainName, query, override); err != nil {
+		rpcErrors.WithLabelValues(chainName, "backfill").Inc()
+		log.Printf("Backfill of %s failed: %v", chainName, err)
+	}
 
 	log.Printf("Listening to %s bridge events...", chainName)
 
 	for {
 		select {
 		case err := <-sub.Err():
-			log.Printf("Error in %s subscription: %v", chainName, err)
-			return
+			rpcErrors.WithLabelValues(chainName, "subscription").Inc()
+			return fmt.Errorf("subscription failed: %v", err)
+		case <-switched:
+			return errEndpointSwitched
 		case vLog := <-logs:
-			bs.processLockEvent(chainName, vLog)
+			if vLog.Removed {
+				bs.processRemovedLog(chainName, vLog)
+				continue
+			}
+			bs.processLog(chainName, vLog)
 		case <-ctx.Done():
-			return
+			return ctx.Err()
 		}
 	}
 }
 
-func (bs *BridgeService) processLockEvent(chainName string, vLog types.Log) {
-	lockEventABI := `[{"anonymous":false,"inputs":[{"indexed":true,"name":"token","type":"address"},{"indexed":true,"name":"sender","type":"address"},{"indexed":false,"name":"targetChain","type":"bytes32"},{"indexed":false,"name":"targetAddr","type":"bytes"},{"indexed":false,"name":"amount","type":"uint256"},{"indexed":false,"name":"nonce","type":"bytes32"}],"name":"Locked","type":"event"}]`
+// processLog routes a bridge contract log to the handler for its event and
+// reports whether it was recorded as a new transfer.
+func (bs *BridgeService) processLog(chainName string, vLog types.Log) bool {
+	if len(vLog.Topics) == 0 {
+		log.Printf("Log %s has no topics", vLog.TxHash.Hex())
+		return false
+	}
 
-	contractABI, err := abi.JSON(strings.NewReader(lockEventABI))
-	if err != nil {
-		log.Printf("Failed to parse ABI: %v", err)
-		return
+	switch bs.eventName(chainName, vLog.Topics[0]) {
+	case "Locked":
+		return bs.processLockEvent(chainName, vLog)
+	case "Burned":
+		return bs.processBurnEvent(chainName, vLog)
+	default:
+		log.Printf("Ignoring %s log %s with unexpected topic %s", chainName, vLog.TxHash.Hex(), vLog.Topics[0].Hex())
+		return false
+	}
+}
+
+func (bs *BridgeService) eventName(chainName string, topic common.Hash) string {
+	for _, def := range bs.listening[chainName] {
+		if def.Topic == topic {
+			return def.Name
+		}
 	}
+	return ""
+}
 
+func (bs *BridgeService) processLockEvent(chainName string, vLog types.Log) bool {
 	var lockEvent LockEvent
-	err = contractABI.UnpackIntoInterface(&lockEvent, "Locked", vLog.Data)
+	if err := bs.unpackTransferLog(chainName, "Locked", vLog, &lockEvent); err != nil {
+		log.Printf("Failed to unpack lock event: %v", err)
+		return false
+	}
+
+	bridgeEvent := bs.transferEvent(chainName, vLog, "lock", StatusPendingConfirmation, lockEvent)
+	if !bs.recordTransferEvent(chainName, vLog, bridgeEvent) {
+		return false
+	}
+	log.Printf("Lock event detected: %s -> %s, Amount: %s", chainName, bridgeEvent.ToChain, bridgeEvent.Amount)
+	return true
+}
+
+func (bs *BridgeService) processBurnEvent(chainName string, vLog types.Log) bool {
+	var burnEvent BurnEvent
+	if err := bs.unpackTransferLog(chainName, "Burned", vLog, &burnEvent); err != nil {
+		log.Printf("Failed to unpack burn event: %v", err)
+		return false
+	}
+
+	bridgeEvent := bs.transferEvent(chainName, vLog, "burn", StatusBurned, LockEvent(burnEvent))
+	if !bs.recordTransferEvent(chainName, vLog, bridgeEvent) {
+		return false
+	}
+	log.Printf("Burn event detected: %s -> %s, Amount: %s", chainName, bridgeEvent.ToChain, bridgeEvent.Amount)
+	return true
+}
+
+func (bs *BridgeService) unpackTransferLog(chainName, eventName string, vLog types.Log, out interface{}) error {
+	chain, _ := bs.chains.GetChain(chainName)
+	contractABI, err := bs.events.ABI(chain.ContractVersion)
 	if err != nil {
-		log.Printf("Failed to unpack event: %v", err)
-		return
+		return err
+	}
+
+	if err := contractABI.UnpackIntoInterface(out, eventName, vLog.Data); err != nil {
+		return err
+	}
+
+	// token and sender are indexed, so they arrive in the topics, not the data
+	var indexed abi.Arguments
+	for _, input := range contractABI.Events[eventName].Inputs {
+		if input.Indexed {
+			indexed = append(indexed, input)
+		}
 	}
+	return abi.ParseTopics(out, indexed, vLog.Topics[1:])
+}
 
-	targetChain := strings.TrimRight(string(lockEvent.TargetChain[:]), "\x00")
+func (bs *BridgeService) transferEvent(chainName string, vLog types.Log, eventType string, status TransferStatus, decoded LockEvent) BridgeEvent {
+	key := EVMTransferKey(chainName, decoded.Nonce)
+	chain, _ := bs.chains.GetChain(chainName)
+	toChain := strings.TrimRight(string(decoded.TargetChain[:]), "\x00")
+	recipient, recipientErr := bs.normalizeRecipient(toChain, decoded.TargetAddr)
+	if recipientErr != nil {
+		recipient = fmt.Sprintf("0x%x", decoded.TargetAddr)
+		status = StatusInvalidRecipient
+	}
 	bridgeEvent := BridgeEvent{
-		ID:          fmt.Sprintf("%s-%s-%d", chainName, vLog.TxHash.Hex(), vLog.Index),
-		Type:        "lock",
+		ID:          lockEventID(chainName, vLog),
+		Type:        eventType,
 		FromChain:   chainName,
-		ToChain:     targetChain,
-		Token:       lockEvent.Token.Hex(),
-		Amount:      lockEvent.Amount.String(),
-		Sender:      lockEvent.Sender.Hex(),
-		Recipient:   string(lockEvent.TargetAddr),
+		ToChain:     toChain,
+		Token:       decoded.Token.Hex(),
+		Amount:      decoded.Amount.String(),
+		Sender:      decoded.Sender.Hex(),
+		Recipient:   recipient,
 		TxHash:      vLog.TxHash.Hex(),
 		BlockNumber: vLog.BlockNumber,
-		Nonce:       fmt.Sprintf("0x%x", lockEvent.Nonce),
-		Status:      "locked",
-		Timestamp:   time.Now(),
+		BlockHash:   vLog.BlockHash.Hex(),
+		Nonce:       key.ID,
+		TransferKey: key.String(),
+		Status:      status,
+		Timestamp:   bs.blockTime(chainName, vLog),
+		ObservedAt:  bs.clock.Now(),
+
+		Confirmation: chain.Finality,
+	}
+	if recipientErr != nil {
+		bridgeEvent.Error = recipientErr.Error()
+	}
+	bridgeEvent.ExplorerLinks = bs.lockExplorerLinks(bridgeEvent)
+	return bridgeEvent
+}
+
+// recordTransferEvent persists a new lock or burn and queues it, returning
+// false if it was a duplicate or couldn't be stored. Locks and burns on one
+// chain come from the same bridge contract and share its nonce counter, so
+// a single TransferKey check covers both.
+func (bs *BridgeService) recordTransferEvent(chainName string, vLog types.Log, bridgeEvent BridgeEvent) bool {
+	existing, err := bs.store.GetByTransferKey(bridgeEvent.TransferKey)
+	switch {
+	case err == nil && existing.Status == StatusReorged:
+		// The reorg freed the nonce: this is the same lock re-included on
+		// the new branch, or another lock the contract gave the nonce to.
+		if existing.ID == bridgeEvent.ID && !bs.TransitionStatus(existing, bridgeEvent.Status) {
+			return false
+		}
+		log.Printf("Recording %s %s for transfer %s, which a reorg dropped as %s", bridgeEvent.Type, bridgeEvent.ID, bridgeEvent.TransferKey, existing.ID)
+	case err == nil && existing.ID == bridgeEvent.ID:
+		// The same log seen again, by the backfill and the subscription.
+		return false
+	case err == nil:
+		bs.duplicates.Add(1)
+		log.Printf("Dropping duplicate %s %s: transfer %s already recorded as %s", bridgeEvent.Type, bridgeEvent.ID, bridgeEvent.TransferKey, existing.ID)
+		bs.saveCheckpoint(chainName, vLog)
+		return false
+	case !errors.Is(err, ErrEventNotFound):
+		log.Printf("Failed to check transfer %s: %v", bridgeEvent.TransferKey, err)
+		return false
+	}
+	if bs.dryRun {
+		log.Printf("Would record %s %s: %s -> %s, Amount: %s", bridgeEvent.Type, bridgeEvent.ID, chainName, bridgeEvent.ToChain, bridgeEvent.Amount)
+		return true
 	}
 
+	bs.enrichToken(&bridgeEvent)
+	if err := bs.store.SaveEvent(bridgeEvent); err != nil {
+		log.Printf("Failed to persist %s event %s: %v", bridgeEvent.Type, bridgeEvent.ID, err)
+		return false
+	}
+	bs.saveCheckpoint(chainName, vLog)
+
+	bridgeEventsObserved.WithLabelValues(chainName, bridgeEvent.Type).Inc()
 	bs.eventChan <- bridgeEvent
-	log.Printf("Lock event detected: %s -> %s, Amount: %s", chainName, targetChain, lockEvent.Amount.String())
+	return true
+}
+
+// lockEventID is derived only from the log's position so that a removed log
+// delivered after a reorg maps back to the event it retracts.
+func lockEventID(chainName string, vLog types.Log) string {
+	return fmt.Sprintf("%s-%s-%d", chainName, vLog.TxHash.Hex(), vLog.Index)
+}
+
+// processRemovedLog handles a log the node retracted in a reorg. A lock that
+// hasn't been handed to the minter is left to the confirmation tracker: the
+// log is often re-included in another block, and the tracker re-checks the
+// receipt before settling, dropping the lock only if it is really gone. Once
+// a mint may have gone out, the transfer needs a human to look at it.
+func (bs *BridgeService) processRemovedLog(chainName string, vLog types.Log) {
+	id := lockEventID(chainName, vLog)
+	event, err := bs.store.GetByID(id)
+	if errors.Is(err, ErrEventNotFound) {
+		log.Printf("Ignoring removed log %s: no matching event", id)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to look up removed log %s: %v", id, err)
+		return
+	}
+
+	switch event.Status {
+	case StatusLocked, StatusPendingConfirmation, StatusBurned:
+		log.Printf("Reorg removed the log of %s %s on %s, waiting for the confirmation tracker to re-check it", event.Type, id, chainName)
+	case StatusReorged, StatusManualReview:
+	default:
+		bs.confirmations.Remove(id)
+		bs.flagForReview(*event, "lock log removed by reorg after mint was initiated")
+	}
+}
+
+func (bs *BridgeService) flagForReview(event BridgeEvent, reason string) {
+	bs.TransitionStatus(&event, StatusManualReview)
+	if err := bs.store.UpdateStatus(event.ID, event.Status); err != nil {
+		log.Printf("Failed to persist status of %s: %v", event.ID, err)
+	}
+	log.Printf("ALERT: %s needs manual review: %s", event.ID, reason)
+	bs.updateTransactionStatus(event)
+
+	event.Type = "alert"
+	event.Error = reason
+	event.Timestamp = bs.clock.Now()
+	bs.eventChan <- event
 }
 
 func (bs *BridgeService) ProcessBridgeEvents(ctx context.Context) {
@@ -190,118 +528,407 @@ func (bs *BridgeService) ProcessBridgeEvents(ctx context.Context) {
 	}
 }
 
+// replayPending re-enqueues locks and burns that were persisted but never
+// settled, e.g. because the process restarted in between. They go back
+// through the confirmation tracker, so one reorged out while we were down is
+// caught.
+func (bs *BridgeService) replayPending() {
+	pending, err := bs.store.ListPending()
+	if err != nil {
+		log.Printf("Failed to load pending events: %v", err)
+		return
+	}
+	if len(pending) > 0 {
+		log.Printf("Replaying %d pending bridge events", len(pending))
+	}
+	for _, event := range pending {
+		// The retry worker resends these; their nonce is already claimed.
+		if event.Status == StatusRetrying || event.Status == StatusFeeCapExceeded {
+			continue
+		}
+		bs.eventChan <- event
+	}
+}
+
 func (bs *BridgeService) handleBridgeEvent(event BridgeEvent) {
 	switch event.Type {
-	case "lock":
-		go bs.initiateMint(event)
-	case "mint":
-		bs.updateTransactionStatus(event.ID, "completed")
+	case "lock", "burn":
+		if event.Status == StatusInvalidRecipient {
+			log.Printf("ALERT: %s %s from %s has an invalid recipient %s and will not be settled: %s", event.Type, event.ID, event.Sender, event.Recipient, event.Error)
+			bs.updateTransactionStatus(event)
+		} else if chain, _ := bs.chains.GetChain(event.FromChain); chain.Finality == finalityInstant {
+			go bs.confirmInstant(event)
+		} else {
+			bs.confirmations.Track(event)
+		}
+	case "mint", "unlock":
+		if !bs.transitionSettlement(&event) {
+			return
+		}
+		if event.Status == StatusCompleted {
+			bs.finalize(&event)
+		} else if err := bs.store.UpdateStatus(event.ID, event.Status); err != nil {
+			log.Printf("Failed to persist status of %s: %v", event.ID, err)
+		}
+		bs.updateTransactionStatus(event)
 	}
 	bs.broadcastEvent(event)
 }
 
+func corridorOf(event BridgeEvent) string {
+	return event.FromChain + ":" + event.ToChain
+}
+
+// finalize records a terminal status and stamps the event with its
+// corridor sequence number.
+func (bs *BridgeService) finalize(event *BridgeEvent) {
+	corridor := corridorOf(*event)
+	seq, err := bs.store.FinalizeStatus(event.ID, event.Status, corridor)
+	if err != nil {
+		log.Printf("Failed to finalize %s: %v", event.ID, err)
+		return
+	}
+	event.Corridor = corridor
+	event.CorridorSeq = seq
+}
+
 func (bs *BridgeService) initiateMint(lockEvent BridgeEvent) {
-	time.Sleep(5 * time.Second)
+	if !bs.TransitionStatus(&lockEvent, StatusMinting) {
+		return
+	}
+	if err := bs.store.UpdateStatus(lockEvent.ID, lockEvent.Status); err != nil {
+		log.Printf("Failed to persist status of %s: %v", lockEvent.ID, err)
+	}
+	bs.updateTransactionStatus(lockEvent)
+	bs.broadcastEvent(lockEvent)
+	bs.settle(lockEvent, "mint")
+}
+
+func (bs *BridgeService) initiateUnlock(burnEvent BridgeEvent) {
+	bs.settle(burnEvent, "unlock")
+}
 
-	targetClient, exists := bs.clients[lockEvent.ToChain]
-	if !exists {
-		log.Printf("No client for target chain: %s", lockEvent.ToChain)
+// settle calls method ("mint" or "unlock") on the target chain's bridge
+// contract for a confirmed lock or burn and reports the outcome.
+func (bs *BridgeService) settle(event BridgeEvent, method string) {
+	if !bs.chains.Has(event.ToChain) {
+		log.Printf("No client for target chain: %s", event.ToChain)
 		return
 	}
 
-	targetContract := bs.contracts[lockEvent.ToChain]
-	mintTxHash := bs.simulateMintTransaction(targetClient, targetContract, lockEvent)
+	mapping, token, amount, ok := bs.payout(event, method)
+	if !ok {
+		return
+	}
 
-	mintEvent := BridgeEvent{
-		ID:        lockEvent.ID,
-		Type:      "mint",
-		FromChain: lockEvent.FromChain,
-		ToChain:   lockEvent.ToChain,
-		Token:     lockEvent.Token,
-		Amount:    lockEvent.Amount,
-		Sender:    lockEvent.Sender,
-		Recipient: lockEvent.Recipient,
-		TxHash:    mintTxHash,
-		Nonce:     lockEvent.Nonce,
-		Status:    "completed",
-		Timestamp: time.Now(),
+	// A second copy of the same event may already be queued behind this one,
+	// so claim the transfer right before sending rather than only at intake.
+	first, err := bs.store.MarkTransferProcessed(event.TransferKey, event.ID)
+	if err != nil {
+		log.Printf("Failed to claim transfer for %s, not calling %s: %v", event.ID, method, err)
+		return
+	}
+	if !first {
+		if claimant, _, err := bs.store.TransferClaimant(event.TransferKey); err == nil && claimant == event.ID {
+			// This event claimed the transfer itself before the relayer
+			// stopped, so a settlement may already be out. It was recorded
+			// before it was sent; the retry worker checks for it, and
+			// replaces it under its nonce, before sending again.
+			retry, found, err := bs.store.GetRetry(event.ID)
+			if err != nil {
+				log.Printf("Failed to load earlier attempts of %s, not resuming %s: %v", event.ID, method, err)
+				return
+			}
+			var interrupted *SettlementRetry
+			if found {
+				interrupted = &retry
+			}
+			log.Printf("Resuming %s of %s: it claimed transfer %s before a restart", method, event.ID, event.TransferKey)
+			bs.retryLater(event, method, interrupted, nil, errors.New("settlement interrupted after the transfer was claimed"))
+			return
+		}
+		bs.duplicates.Add(1)
+		log.Printf("Skipping %s for %s: transfer %s was already processed", method, event.ID, event.TransferKey)
+		return
 	}
 
-	bs.eventChan <- mintEvent
+	bs.attemptSettlement(event, method, mapping, token, amount, nil)
 }
 
-func (bs *BridgeService) simulateMintTransaction(client *ethclient.Client, contract common.Address, event BridgeEvent) string {
-	return fmt.Sprintf("0x%064x", time.Now().UnixNano())
+// payout resolves what event pays out on its target chain. A transfer that
+// can't be paid out is reported as such and ok is false.
+func (bs *BridgeService) payout(event BridgeEvent, method string) (mapping TokenMapping, token common.Address, amount *big.Int, ok bool) {
+	mapping, token, ok = bs.tokens.Destination(event)
+	if !ok {
+		log.Printf("Not calling %s for %s: token %s on %s has no mapping to %s", method, event.ID, event.Token, event.FromChain, event.ToChain)
+		bs.eventChan <- bs.settlementEvent(event, method, "", StatusUnsupportedToken,
+			fmt.Errorf("token %s on %s has no mapping to %s", event.Token, event.FromChain, event.ToChain))
+		return mapping, token, nil, false
+	}
+	amount, err := mapping.payoutAmount(event)
+	if err != nil {
+		log.Printf("Not calling %s for %s: amount %s does not convert to %s on %s: %v", method, event.ID, event.Amount, mapping.Symbol, event.ToChain, err)
+		bs.eventChan <- bs.settlementEvent(event, method, "", StatusUnsupportedAmount, err)
+		return mapping, token, nil, false
+	}
+	return mapping, token, amount, true
 }
 
-func (bs *BridgeService) updateTransactionStatus(id, status string) {
-	payload := map[string]string{"id": id, "status": status}
-	jsonData, _ := json.Marshal(payload)
-	
-	resp, err := http.Post("http://localhost:5000/api/bridge/update-status", 
-		"application/json", strings.NewReader(string(jsonData)))
-	
+// attemptSettlement sends one mint or unlock and waits for it to be mined.
+// A failed attempt is queued for retry; retry is nil on the first attempt.
+func (bs *BridgeService) attemptSettlement(event BridgeEvent, method string, mapping TokenMapping, token common.Address, amount *big.Int, retry *SettlementRetry) {
+	mintsAttempted.WithLabelValues(event.ToChain, method).Inc()
+	if retry == nil {
+		retry = &SettlementRetry{EventID: event.ID, Method: method}
+	}
+	heldNonce := retry.PendingNonce
+	tx, gas, err := bs.sendBridgeCall(event, method, token, amount, retry)
+	// So does a replacement for a stuck transaction that would have to pay
+	// more than the fee ceiling to outbid it.
+	if errors.Is(err, ErrFeeCapExceeded) || errors.Is(err, errBumpCeiling) {
+		log.Printf("Parking %s of %s until fees drop: %v", method, event.ID, err)
+		bs.parkForFees(event, method, retry, err)
+		return
+	}
+	if err != nil {
+		mintsFailed.WithLabelValues(event.ToChain, method).Inc()
+		log.Printf("Failed to %s %s: %v", method, event.ID, err)
+		if heldNonce != nil && isNonceTooLow(err) {
+			// Something was mined with the held nonce. If it was an earlier
+			// attempt the next retry finds its receipt; if not, the retry
+			// needs a nonce of its own.
+			bs.nonceManager(event.ToChain).Done(*heldNonce)
+			retry.PendingNonce, retry.PendingGas = nil, nil
+		}
+		var sent []sentSettlement
+		if tx != nil {
+			sent = []sentSettlement{{tx: tx, gas: gas}}
+		}
+		bs.retryLater(event, method, retry, sent, err)
+		return
+	}
+	mintLatency.WithLabelValues(event.ToChain, method).Observe(Since(bs.clock, event.Timestamp).Seconds())
+	log.Printf("Sent %s of %s for %s on %s: %s", method, mapping.Symbol, event.ID, event.ToChain, tx.Hash().Hex())
+
+	sent, _, err := bs.waitForSettlement(event, method, tx, gas)
+	if errors.Is(err, errNotMined) {
+		// The transaction may still be mined, so its nonce stays held and
+		// the retry replaces it instead of sending the call again beside it.
+		nonce := tx.Nonce()
+		retry.PendingNonce = &nonce
+		retry.PendingGas = sent[len(sent)-1].gas
+	} else {
+		bs.nonceManager(event.ToChain).Done(tx.Nonce())
+		retry.PendingNonce, retry.PendingGas = nil, nil
+	}
 	if err != nil {
-		log.Printf("Failed to update transaction status: %v", err)
+		mintsFailed.WithLabelValues(event.ToChain, method).Inc()
+		log.Printf("Failed to %s %s: %v", method, event.ID, err)
+		bs.retryLater(event, method, retry, sent, err)
 		return
 	}
-	defer resp.Body.Close()
+
+	mintsSucceeded.WithLabelValues(event.ToChain, method).Inc()
+	bs.dropRetry(event.ID)
+	mined := sent[len(sent)-1]
+	settlement := bs.settlementEvent(event, method, mined.tx.Hash().Hex(), StatusCompleted, nil)
+	settlement.Gas = mined.gas
+	settlement.FeeBumps = len(sent) - 1
+	bs.eventChan <- settlement
+}
+
+func (bs *BridgeService) settlementEvent(source BridgeEvent, method, txHash string, status TransferStatus, settleErr error) BridgeEvent {
+	settlement := BridgeEvent{
+		ID:          source.ID,
+		Type:        method,
+		FromChain:   source.FromChain,
+		ToChain:     source.ToChain,
+		Token:       source.Token,
+		Amount:      source.Amount,
+		Sender:      source.Sender,
+		Recipient:   source.Recipient,
+		TxHash:      txHash,
+		Nonce:       source.Nonce,
+		TransferKey: source.TransferKey,
+		Status:      status,
+		Timestamp:   bs.clock.Now(),
+		ObservedAt:  bs.clock.Now(),
+
+		ExplorerLinks: bs.mintExplorerLinks(source, txHash),
+	}
+	if settleErr != nil {
+		settlement.Error = settleErr.Error()
+	}
+	return settlement
 }
 
 func (bs *BridgeService) broadcastEvent(event BridgeEvent) {
+	bs.hub.Broadcast(event)
+	bs.notifyWebhooks(event)
 	log.Printf("Broadcasting event: %s", event.ID)
 }
 
-func (bs *BridgeService) handleWebSocket(w http.ResponseWriter, r *http.Request) {
-	conn, err := bs.wsUpgrader.Upgrade(w, r, nil)
-	if err != nil {
-		log.Printf("WebSocket upgrade failed: %v", err)
-		return
+func (bs *BridgeService) chainNames() []string {
+	return bs.chains.Names()
+}
+
+func (bs *BridgeService) handleBridgeStatus(w http.ResponseWriter, r *http.Request) {
+	status := map[string]interface{}{
+		"status":               "active",
+		"chains":               bs.chainNames(),
+		"uptime":               bs.clock.Now().Format(time.RFC3339),
+		"egress":               bs.egressMon.Results(),
+		"heads":                bs.heads.Status(),
+		"wsClients":            bs.hub.Count(),
+		"wsEvictions":          bs.hub.Evictions(),
+		"rpc":                  bs.rpcStatus(),
+		"paused":               bs.pauses.Snapshot(),
+		"duplicatesDropped":    bs.duplicates.Load(),
+		"awaitingConfirmation": bs.confirmations.Len(),
+		"signerRefusals":       bs.signer.policy.Refusals(),
+		"chainChecks":          bs.chainChecks.Snapshot(),
 	}
-	defer conn.Close()
 
-	log.Println("New WebSocket connection established")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
 
-	for {
-		select {
-		case event := <-bs.eventChan:
-			if err := conn.WriteJSON(event); err != nil {
-				log.Printf("WebSocket write error: %v", err)
-				return
-			}
+func (bs *BridgeService) rpcStatus() map[string][]EndpointStatus {
+	clients := bs.chains.Clients()
+	status := make(map[string][]EndpointStatus, len(clients))
+	for chainName, client := range clients {
+		if reporter, ok := client.(endpointReporter); ok {
+			status[chainName] = reporter.Status()
 		}
 	}
+	return status
 }
 
-func (bs *BridgeService) handleBridgeStatus(w http.ResponseWriter, r *http.Request) {
-	status := map[string]interface{}{
-		"status": "active",
-		"chains": []string{"ethereum", "polygon", "bsc"},
-		"uptime": time.Now().Format(time.RFC3339),
+func (bs *BridgeService) handleChains(w http.ResponseWriter, r *http.Request) {
+	configs := bs.chains.Configs()
+	chains := make([]map[string]interface{}, 0, len(configs))
+	for _, chainName := range bs.chainNames() {
+		chain, ok := configs[chainName]
+		if !ok {
+			continue
+		}
+		chains = append(chains, map[string]interface{}{
+			"name":      chainName,
+			"chainId":   chain.ChainID,
+			"contract":  common.HexToAddress(chain.Contract).Hex(),
+			"explorers": bs.explorers[chainName],
+		})
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(status)
+	json.NewEncoder(w).Encode(chains)
+}
+
+func (bs *BridgeService) handleEvents(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(bs.listening)
 }
 
 func runBridgeService() {
+	cfg, err := LoadConfig(resolveConfigPath())
+	if err != nil {
+		log.Fatal("Failed to load config:", err)
+	}
+
+	signer, err := LoadRelayerSigner()
+	if err != nil {
+		log.Fatal("Failed to load relayer key:", err)
+	}
+
+	store, err := OpenStore()
+	if err != nil {
+		log.Fatal("Failed to open event store:", err)
+	}
+	defer store.Close()
+
 	bridgeService := NewBridgeService()
+	bridgeService.registerServiceMetrics()
+	bridgeService.signer = signer
+	bridgeService.store = store
+	log.Printf("Relayer account: %s", signer.Address().Hex())
+
+	fromBlocks, err := parseFromBlocks(*fromBlockFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	bridgeService.fromBlocks = fromBlocks
 
-	if err := bridgeService.InitializeClients(); err != nil {
+	if err := bridgeService.InitializeClients(cfg); err != nil {
 		log.Fatal("Failed to initialize clients:", err)
 	}
+	if err := bridgeService.loadTokens(cfg.Tokens); err != nil {
+		log.Fatal("Failed to load token mappings:", err)
+	}
+	if err := bridgeService.loadLimits(cfg.Limits); err != nil {
+		log.Fatal("Failed to load transfer limits:", err)
+	}
+	if err := bridgeService.loadWebhooks(cfg.Webhooks); err != nil {
+		log.Fatal("Failed to load webhooks:", err)
+	}
+	if err := bridgeService.loadPauses(); err != nil {
+		log.Fatal("Failed to load pauses:", err)
+	}
+
+	policy, err := NewSignerPolicy(cfg.SignerPolicy, bridgeService.chains.Configs(), bridgeService.events)
+	if err != nil {
+		log.Fatal("Failed to load signer policy:", err)
+	}
+	previous, err := store.RecordSignerPolicy(policy.Fingerprint(), policy.String())
+	if err != nil {
+		log.Fatal("Failed to audit signer policy:", err)
+	}
+	if previous != "" && previous != policy.String() {
+		log.Printf("Signer policy changed since last start: was %s", previous)
+	}
+	signer.SetPolicy(policy)
+	bridgeService.syncNonces()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	bridgeService.egressMon.SelfTest(ctx, bridgeService.egress)
 
-	go bridgeService.ListenToChain(ctx, "ethereum")
-	go bridgeService.ListenToChain(ctx, "polygon")
-	go bridgeService.ListenToChain(ctx, "bsc")
+	for chainName, client := range bridgeService.chains.Clients() {
+		if failover, ok := client.(*FailoverClient); ok {
+			go failover.RunHealthChecks(ctx)
+		}
+		go bridgeService.heads.Run(ctx, chainName, client)
+	}
+
+	for _, chainName := range bridgeService.chainNames() {
+		bridgeService.listeners.Add(1)
+		go func(chainName string) {
+			defer bridgeService.listeners.Done()
+			bridgeService.ListenToChain(ctx, chainName)
+		}(chainName)
+	}
 	go bridgeService.ProcessBridgeEvents(ctx)
+	go bridgeService.TrackConfirmations(ctx)
+	go bridgeService.RunIntegritySampler(ctx)
+	go bridgeService.RunRetries(ctx)
+	go bridgeService.RunWebhooks(ctx)
+	go bridgeService.RunCallbacks(ctx)
+	go bridgeService.replayPending()
+	go bridgeService.warmup.Run(ctx, bridgeService.warmupSteps())
 
 	router := mux.NewRouter()
-	router.HandleFunc("/ws", bridgeService.handleWebSocket)
+	admin := bridgeService.auth.Require(roleAdmin)
+	bridgeService.registerStreamRoutes(router)
 	router.HandleFunc("/status", bridgeService.handleBridgeStatus)
+	router.HandleFunc("/chains", bridgeService.handleChains)
+	router.Handle("/admin/events", admin(http.HandlerFunc(bridgeService.handleEvents)))
+	router.Handle("/admin/integrity", admin(http.HandlerFunc(bridgeService.handleIntegrity)))
+	bridgeService.registerTokenRoutes(router)
+	bridgeService.registerPauseRoutes(router)
+	bridgeService.registerLimitRoutes(router)
+	bridgeService.registerWebhookRoutes(router)
+	router.Handle("/metrics", promhttp.Handler())
+	bridgeService.registerAPIRoutes(router)
 
 	server := &http.Server{
 		Addr:    ":8080",
@@ -316,4 +943,9 @@ func runBridgeService() {
 	}()
 
 	log.Println("Go bridge service started successfully")
-}
\ No newline at end of file
+
+	<-ctx.Done()
+	stop()
+	log.Println("Shutting down bridge service...")
+	bridgeService.shutdown(server)
+}
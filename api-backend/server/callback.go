@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	callbackVersionHeader   = "X-Bridge-Payload-Version"
+	callbackSequenceHeader  = "X-Bridge-Update-Sequence"
+	callbackSignatureHeader = "X-Bridge-Signature"
+	callbackBatchHeader     = "X-Bridge-Batch-Size"
+
+	callbackPollInterval = 5 * time.Second
+)
+
+// CallbackConfig is one consumer of status updates. Version 1 sends the
+// original {"id","status"} body; version 2 sends the whole transfer. With a
+// BearerToken, each request carries it in the Authorization header.
+type CallbackConfig struct {
+	URL            string `json:"url" yaml:"url"`
+	PayloadVersion int    `json:"payloadVersion" yaml:"payloadVersion"`
+	Secret         string `json:"secret" yaml:"secret"`
+	BearerToken    string `json:"bearerToken" yaml:"bearerToken"`
+}
+
+// CallbackQueueConfig bounds the queue of status updates each callback has
+// yet to accept and sets how they are retried. Once MaxPending updates are
+// waiting, the oldest are dropped to make room.
+type CallbackQueueConfig struct {
+	MaxPending int         `json:"maxPending" yaml:"maxPending"`
+	BatchSize  int         `json:"batchSize" yaml:"batchSize"`
+	Retry      RetryConfig `json:"retry" yaml:"retry"`
+}
+
+func (c *CallbackQueueConfig) applyDefaults() {
+	if c.MaxPending == 0 {
+		c.MaxPending = 10000
+	}
+	if c.BatchSize == 0 {
+		c.BatchSize = 50
+	}
+	if c.Retry.InitialBackoffSeconds == 0 {
+		c.Retry.InitialBackoffSeconds = 5
+	}
+	if c.Retry.MaxBackoffSeconds == 0 {
+		c.Retry.MaxBackoffSeconds = 300
+	}
+	if c.Retry.MaxAttempts == 0 {
+		c.Retry.MaxAttempts = 50
+	}
+}
+
+// StatusUpdate is one encoded update waiting in a callback's queue.
+type StatusUpdate struct {
+	Callback    string
+	Sequence    uint64
+	EventID     string
+	Payload     json.RawMessage
+	Attempts    int
+	NextAttempt time.Time
+	CreatedAt   time.Time
+}
+
+type statusCallback struct {
+	CallbackConfig
+	client *http.Client
+}
+
+// statusUpdateV2 is the canonical transfer document sent to version 2
+// consumers.
+type statusUpdateV2 struct {
+	SchemaVersion  int            `json:"schemaVersion"`
+	UpdateSequence uint64         `json:"updateSequence"`
+	ID             string         `json:"id"`
+	Status         TransferStatus `json:"status"`
+	Transfer       BridgeEvent    `json:"transfer"`
+}
+
+func callbackDest(i int) string {
+	if i == 0 {
+		return egressStatusCallback
+	}
+	return fmt.Sprintf("%s_%d", egressStatusCallback, i+1)
+}
+
+func (bs *BridgeService) initCallbacks(callbacks []CallbackConfig) {
+	// Seeding from the clock keeps the sequence increasing across restarts,
+	// so consumers can keep discarding anything older than what they've seen.
+	bs.callbackSeq.Store(uint64(bs.clock.Now().UnixMicro()))
+
+	for i, cfg := range callbacks {
+		dest := callbackDest(i)
+		client := bs.egress.HTTPClient(dest, 10*time.Second)
+		bs.callbacks = append(bs.callbacks, statusCallback{CallbackConfig: cfg, client: client})
+		bs.egressMon.Register(dest, cfg.URL, httpReachable(client, cfg.URL))
+	}
+}
+
+func callbackPayload(version int, event BridgeEvent, sequence uint64) ([]byte, error) {
+	if version == 1 {
+		return json.Marshal(map[string]string{"id": event.ID, "status": string(event.Status)})
+	}
+	return json.Marshal(statusUpdateV2{
+		SchemaVersion:  version,
+		UpdateSequence: sequence,
+		ID:             event.ID,
+		Status:         event.Status,
+		Transfer:       event,
+	})
+}
+
+func signCallback(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// updateTransactionStatus queues event's status for every callback. The
+// queue is persisted, so updates survive a restart or an unreachable backend
+// and go out in order once it recovers.
+func (bs *BridgeService) updateTransactionStatus(event BridgeEvent) {
+	sequence := bs.callbackSeq.Add(1)
+	now := bs.clock.Now()
+
+	for i, callback := range bs.callbacks {
+		body, err := callbackPayload(callback.PayloadVersion, event, sequence)
+		if err != nil {
+			log.Printf("Failed to encode status update for %s: %v", event.ID, err)
+			continue
+		}
+		update := StatusUpdate{
+			Callback:    callback.URL,
+			Sequence:    sequence,
+			EventID:     event.ID,
+			Payload:     body,
+			NextAttempt: now,
+			CreatedAt:   now,
+		}
+		if err := bs.store.SaveStatusUpdate(update); err != nil {
+			log.Printf("Failed to queue status update for %s to %s: %v", event.ID, callback.URL, err)
+			continue
+		}
+		if bs.recordCallbackDepth(i) > bs.callbackQueue.MaxPending {
+			dropped, err := bs.store.TrimStatusUpdates(callback.URL, bs.callbackQueue.MaxPending)
+			if err != nil {
+				log.Printf("Failed to trim status update queue for %s: %v", callback.URL, err)
+			} else {
+				log.Printf("ALERT: status update queue for %s is full, dropped %d oldest updates", callback.URL, dropped)
+				bs.recordCallbackDepth(i)
+			}
+		}
+		go bs.flushCallback(i)
+	}
+}
+
+func (bs *BridgeService) RunCallbacks(ctx context.Context) {
+	Every(ctx, bs.clock, callbackPollInterval, func(ctx context.Context) {
+		for i := range bs.callbacks {
+			go bs.flushCallback(i)
+		}
+	})
+}
+
+// flushCallback sends callback i its queued updates, oldest first, until the
+// queue is empty or an attempt fails. Updates stay queued until the backend
+// accepts them. When more than one is waiting, version 2 callbacks get up to
+// BatchSize of them at a time as a JSON array; version 1 bodies carry no
+// sequence, so they always go one per request.
+func (bs *BridgeService) flushCallback(i int) {
+	callback := bs.callbacks[i]
+	if _, busy := bs.flushing.LoadOrStore(callback.URL, true); busy {
+		return
+	}
+	defer bs.flushing.Delete(callback.URL)
+	defer bs.recordCallbackDepth(i)
+
+	limit := 1
+	if callback.PayloadVersion == 2 {
+		limit = bs.callbackQueue.BatchSize
+	}
+	for {
+		queued, err := bs.store.PendingStatusUpdates(callback.URL, limit)
+		if err != nil {
+			log.Printf("Failed to load queued status updates for %s: %v", callback.URL, err)
+			return
+		}
+		now := bs.clock.Now()
+		due := queued[:0]
+		for _, update := range queued {
+			if update.NextAttempt.After(now) {
+				break
+			}
+			due = append(due, update)
+		}
+		if len(due) == 0 {
+			return
+		}
+		if !bs.sendStatusUpdates(callback, due) {
+			return
+		}
+	}
+}
+
+// sendStatusUpdates makes one attempt at delivering updates and reports
+// whether they left the queue. 2xx responses deliver them; other 4xx
+// responses, except 408 and 429, are permanent and drop them. Anything else
+// is retried with backoff until Retry.MaxAttempts.
+func (bs *BridgeService) sendStatusUpdates(callback statusCallback, updates []StatusUpdate) bool {
+	sequences := make([]uint64, len(updates))
+	for j, update := range updates {
+		sequences[j] = update.Sequence
+	}
+	last := updates[len(updates)-1]
+
+	code, err := bs.postStatusUpdates(callback, updates)
+	retryable := err != nil || code >= 500 || code == http.StatusRequestTimeout || code == http.StatusTooManyRequests
+	switch {
+	case err == nil && code >= 200 && code < 300:
+	case !retryable:
+		log.Printf("Status update for %s rejected by %s: HTTP %d", describeUpdates(updates), callback.URL, code)
+	default:
+		cause := "HTTP " + strconv.Itoa(code)
+		if err != nil {
+			cause = err.Error()
+		}
+		attempts := updates[0].Attempts + 1
+		if attempts < bs.callbackQueue.Retry.MaxAttempts {
+			delay := bs.callbackQueue.Retry.backoff(attempts)
+			log.Printf("Status update for %s to %s failed, retrying in %s: %s", describeUpdates(updates), callback.URL, delay, cause)
+			if err := bs.store.RescheduleStatusUpdates(callback.URL, sequences, attempts, bs.clock.Now().Add(delay)); err != nil {
+				log.Printf("Failed to reschedule status updates for %s: %v", callback.URL, err)
+			}
+			return false
+		}
+		log.Printf("Giving up on status update for %s to %s after %d attempts: %s", describeUpdates(updates), callback.URL, attempts, cause)
+	}
+
+	if err := bs.store.DeleteStatusUpdates(callback.URL, sequences); err != nil {
+		log.Printf("Failed to dequeue status updates for %s up to sequence %d: %v", callback.URL, last.Sequence, err)
+		return false
+	}
+	return true
+}
+
+func (bs *BridgeService) postStatusUpdates(callback statusCallback, updates []StatusUpdate) (int, error) {
+	body := []byte(updates[0].Payload)
+	if len(updates) > 1 {
+		payloads := make([]json.RawMessage, len(updates))
+		for j, update := range updates {
+			payloads[j] = update.Payload
+		}
+		var err error
+		if body, err = json.Marshal(payloads); err != nil {
+			return 0, err
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodPost, callback.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(callbackVersionHeader, strconv.Itoa(callback.PayloadVersion))
+	req.Header.Set(callbackSequenceHeader, strconv.FormatUint(updates[len(updates)-1].Sequence, 10))
+	if len(updates) > 1 {
+		req.Header.Set(callbackBatchHeader, strconv.Itoa(len(updates)))
+	}
+	if callback.Secret != "" {
+		req.Header.Set(callbackSignatureHeader, signCallback(callback.Secret, body))
+	}
+	if callback.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+callback.BearerToken)
+	}
+
+	resp, err := callback.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+func describeUpdates(updates []StatusUpdate) string {
+	if len(updates) == 1 {
+		return updates[0].EventID
+	}
+	return fmt.Sprintf("%d transfers", len(updates))
+}
+
+// recordCallbackDepth publishes and returns how many updates callback i has
+// queued.
+func (bs *BridgeService) recordCallbackDepth(i int) int {
+	depth, err := bs.store.CountStatusUpdates(bs.callbacks[i].URL)
+	if err != nil {
+		log.Printf("Failed to count queued status updates for %s: %v", bs.callbacks[i].URL, err)
+		return 0
+	}
+	callbackQueueDepth.WithLabelValues(callbackDest(i)).Set(float64(depth))
+	return depth
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/AIhangzhou56/YHGS-Bridge/server/statuscallback"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// goldenTransfer sets every field a status update can carry, so a change to
+// any of them shows up in the golden files.
+func goldenTransfer() BridgeEvent {
+	decimals := uint8(6)
+	next := time.Date(2024, 3, 1, 12, 0, 30, 0, time.UTC)
+	return BridgeEvent{
+		ID:            "ethereum-0x8c3a5f0b7e2b64d1c9f1d2a3b4c5d6e7f8091a2b3c4d5e6f708192a3b4c5d6e7-3",
+		Type:          "mint",
+		FromChain:     "ethereum",
+		ToChain:       "polygon",
+		Token:         "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48",
+		TokenSymbol:   "USDC",
+		TokenDecimals: &decimals,
+		Amount:        "2500000000",
+		Sender:        "0x3000000000000000000000000000000000000003",
+		Recipient:     "0x4000000000000000000000000000000000000004",
+		TxHash:        "0x5f0b7e2b64d1c9f1d2a3b4c5d6e7f8091a2b3c4d5e6f708192a3b4c5d6e78c3a",
+		BlockNumber:   19000000,
+		BlockHash:     "0x0b7e2b64d1c9f1d2a3b4c5d6e7f8091a2b3c4d5e6f708192a3b4c5d6e78c3a5f",
+		Confirmation:  finalityDepth,
+		Corridor:      "ethereum:polygon",
+		CorridorSeq:   42,
+		Nonce:         "0x0000000000000000000000000000000000000000000000000000000000000007",
+		TransferKey:   "ethereum:0x0000000000000000000000000000000000000000000000000000000000000007",
+		Status:        StatusRetrying,
+		Error:         "replacement transaction underpriced",
+		Attempts:      2,
+		NextAttemptAt: &next,
+		Gas: &GasParams{
+			GasLimit:             120000,
+			MaxFeePerGas:         "60000000000",
+			MaxPriorityFeePerGas: "2000000000",
+			BaseFee:              "29000000000",
+		},
+		FeeBumps:       1,
+		ReplacedTxHash: "0x64d1c9f1d2a3b4c5d6e7f8091a2b3c4d5e6f708192a3b4c5d6e78c3a5f0b7e2b",
+		Timestamp:      time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+		ObservedAt:     time.Date(2024, 3, 1, 12, 0, 5, 0, time.UTC),
+		ExplorerLinks: &ExplorerLinks{
+			LockTx:    "https://etherscan.io/tx/0x5f0b7e2b64d1c9f1d2a3b4c5d6e7f8091a2b3c4d5e6f708192a3b4c5d6e78c3a",
+			MintTx:    "https://polygonscan.com/tx/0x64d1c9f1d2a3b4c5d6e7f8091a2b3c4d5e6f708192a3b4c5d6e78c3a5f0b7e2b",
+			Sender:    "https://etherscan.io/address/0x3000000000000000000000000000000000000003",
+			Recipient: "https://polygonscan.com/address/0x4000000000000000000000000000000000000004",
+			Token:     "https://etherscan.io/token/0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48",
+		},
+	}
+}
+
+func TestCallbackPayloadGolden(t *testing.T) {
+	const sequence = 1709294430000000
+	for _, version := range []int{1, 2} {
+		t.Run("v"+strconv.Itoa(version), func(t *testing.T) {
+			body, err := callbackPayload(version, goldenTransfer(), sequence)
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join("testdata", "status_update_v"+strconv.Itoa(version)+".json")
+			if *updateGolden {
+				if err := os.WriteFile(path, body, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			golden, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			if !bytes.Equal(body, golden) {
+				t.Errorf("payload v%d changed; consumers depend on it.\n got: %s\nwant: %s", version, body, golden)
+			}
+
+			// The consumer package must accept what the service sends.
+			const secret = "callback-secret"
+			header := http.Header{}
+			header.Set(callbackVersionHeader, strconv.Itoa(version))
+			header.Set(callbackSequenceHeader, strconv.FormatUint(sequence, 10))
+			header.Set(callbackSignatureHeader, signCallback(secret, golden))
+			update, err := statuscallback.NewVerifier(secret).Verify(header, golden)
+			if err != nil {
+				t.Fatalf("consumer rejected the golden payload: %v", err)
+			}
+			if update.ID != goldenTransfer().ID || update.Status != string(StatusRetrying) || update.UpdateSequence != sequence || update.SchemaVersion != version {
+				t.Errorf("consumer decoded %+v", update)
+			}
+			if (version == 2) != (len(update.Transfer) > 0) {
+				t.Errorf("v%d carries transfer %s", version, update.Transfer)
+			}
+		})
+	}
+}
+
+func TestCallbackSignatureRejectsTampering(t *testing.T) {
+	golden, err := os.ReadFile(filepath.Join("testdata", "status_update_v2.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := http.Header{}
+	header.Set(callbackVersionHeader, "2")
+	header.Set(callbackSequenceHeader, "1709294430000000")
+	header.Set(callbackSignatureHeader, signCallback("callback-secret", golden))
+
+	tampered := bytes.Replace(golden, []byte(`"amount":"2500000000"`), []byte(`"amount":"9500000000"`), 1)
+	if bytes.Equal(tampered, golden) {
+		t.Fatal("golden payload has no amount to tamper with")
+	}
+	if _, err := statuscallback.NewVerifier("callback-secret").Verify(header, tampered); err != statuscallback.ErrBadSignature {
+		t.Errorf("tampered payload: err = %v, want a bad signature", err)
+	}
+}
+
+// Queued updates are keyed by callback URL, so a URL listed twice is refused
+// rather than having both entries drain one queue.
+func TestConfigRejectsDuplicateCallbackURL(t *testing.T) {
+	config := func(urls ...string) *BridgeConfig {
+		cfg := &BridgeConfig{Chains: []ChainConfig{{
+			Name:     testSourceChain,
+			RPCURL:   "http://localhost:8545",
+			Contract: testBridges[testSourceChain].Hex(),
+			ChainID:  testChainIDs[testSourceChain],
+		}}}
+		for _, url := range urls {
+			cfg.Callbacks = append(cfg.Callbacks, CallbackConfig{URL: url, PayloadVersion: 2})
+		}
+		return cfg
+	}
+
+	if err := config("http://a.example/status", "http://b.example/status").Validate(); err != nil {
+		t.Fatalf("distinct callback URLs refused: %v", err)
+	}
+	if err := config("http://a.example/status", "http://b.example/status", "http://a.example/status").Validate(); err == nil {
+		t.Error("duplicate callback URL accepted")
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+type registeredChain struct {
+	config   ChainConfig
+	client   ChainClient
+	contract common.Address
+}
+
+// ChainRegistry holds each chain's config, RPC client and bridge contract.
+// Listeners, settlement and HTTP handlers read it concurrently, so chains can
+// be registered and removed while the service runs.
+type ChainRegistry struct {
+	mu     sync.RWMutex
+	chains map[string]registeredChain
+}
+
+func errUnknownChain(name string) error {
+	return fmt.Errorf("chain %s is not registered", name)
+}
+
+func NewChainRegistry() *ChainRegistry {
+	return &ChainRegistry{chains: make(map[string]registeredChain)}
+}
+
+// Register adds chain, or replaces the chain of the same name.
+func (r *ChainRegistry) Register(chain ChainConfig, client ChainClient) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.chains[chain.Name] = registeredChain{
+		config:   chain,
+		client:   client,
+		contract: common.HexToAddress(chain.Contract),
+	}
+}
+
+func (r *ChainRegistry) Remove(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.chains[name]
+	delete(r.chains, name)
+	return ok
+}
+
+func (r *ChainRegistry) get(name string) (registeredChain, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	chain, ok := r.chains[name]
+	return chain, ok
+}
+
+func (r *ChainRegistry) GetChain(name string) (ChainConfig, bool) {
+	chain, ok := r.get(name)
+	return chain.config, ok
+}
+
+func (r *ChainRegistry) GetClient(name string) (ChainClient, bool) {
+	chain, ok := r.get(name)
+	return chain.client, ok
+}
+
+func (r *ChainRegistry) GetContract(name string) (common.Address, bool) {
+	chain, ok := r.get(name)
+	return chain.contract, ok
+}
+
+func (r *ChainRegistry) Has(name string) bool {
+	_, ok := r.get(name)
+	return ok
+}
+
+// Names returns the registered chains in sorted order.
+func (r *ChainRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.chains))
+	for name := range r.chains {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Configs returns a copy of every registered chain's config.
+func (r *ChainRegistry) Configs() map[string]ChainConfig {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	configs := make(map[string]ChainConfig, len(r.chains))
+	for name, chain := range r.chains {
+		configs[name] = chain.config
+	}
+	return configs
+}
+
+// Clients returns a copy of every registered chain's client.
+func (r *ChainRegistry) Clients() map[string]ChainClient {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	clients := make(map[string]ChainClient, len(r.chains))
+	for name, chain := range r.chains {
+		clients[name] = chain.client
+	}
+	return clients
+}
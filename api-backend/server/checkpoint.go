@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var fromBlockFlag = flag.String("from-block", "", "force a backfill start per chain, e.g. ethereum=19000000,polygon=55000000")
+
+const (
+	defaultMaxBlocksPerQuery   = 2000
+	defaultPollIntervalSeconds = 12
+
+	// wholeBlock as a checkpoint's LogIndex means every log in the block has
+	// been handled.
+	wholeBlock = math.MaxInt32
+)
+
+// Checkpoint is the position of the last log a chain listener has handled.
+type Checkpoint struct {
+	Block    uint64 `json:"block"`
+	LogIndex uint   `json:"logIndex"`
+}
+
+// covers reports whether the log at (block, index) was already handled.
+func (c Checkpoint) covers(block uint64, index uint) bool {
+	return block < c.Block || (block == c.Block && index <= c.LogIndex)
+}
+
+func parseFromBlocks(value string) (map[string]uint64, error) {
+	fromBlocks := make(map[string]uint64)
+	if value == "" {
+		return fromBlocks, nil
+	}
+	for _, entry := range strings.Split(value, ",") {
+		chainName, block, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid -from-block entry %q, expected chain=block", entry)
+		}
+		number, err := strconv.ParseUint(block, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -from-block entry %q: %v", entry, err)
+		}
+		fromBlocks[chainName] = number
+	}
+	return fromBlocks, nil
+}
+
+func (bs *BridgeService) saveCheckpoint(chainName string, vLog types.Log) {
+	if bs.manualBackfill {
+		return
+	}
+	checkpoint := Checkpoint{Block: vLog.BlockNumber, LogIndex: vLog.Index}
+	if err := bs.store.SaveCheckpoint(chainName, checkpoint); err != nil {
+		log.Printf("Failed to checkpoint %s: %v", chainName, err)
+	}
+}
+
+// backfill replays bridge logs emitted since the chain's checkpoint (or since
+// override, from -from-block) up to the current head, in chunks small enough
+// for public RPC providers. Without either, there is nothing to catch up on.
+func (bs *BridgeService) backfill(ctx context.Context, chainName string, query ethereum.FilterQuery, override *uint64) error {
+	checkpoint, found, err := bs.store.GetCheckpoint(chainName)
+	if err != nil {
+		return err
+	}
+	// The checkpoint block itself is rescanned; covers() skips the logs in
+	// it that were already handled.
+	from, resume := checkpoint.Block, found
+	if override != nil {
+		from, resume = *override, false
+	} else if !found {
+		log.Printf("No checkpoint for %s, starting from the live head", chainName)
+		return nil
+	}
+
+	client, ok := bs.chains.GetClient(chainName)
+	if !ok {
+		return errUnknownChain(chainName)
+	}
+	head, err := client.BlockNumber(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get %s head: %v", chainName, err)
+	}
+	if from > head {
+		return nil
+	}
+
+	log.Printf("Backfilling %s from block %d to %d", chainName, from, head)
+	if err := bs.scanLogs(ctx, chainName, query, from, head, checkpoint, resume); err != nil {
+		return err
+	}
+	log.Printf("Backfill of %s complete", chainName)
+	return nil
+}
+
+// scanLogs feeds the bridge logs in blocks from..to through processLog,
+// skipping those checkpoint covers when resume is set, and checkpoints the
+// end of each query window.
+func (bs *BridgeService) scanLogs(ctx context.Context, chainName string, query ethereum.FilterQuery, from, to uint64, checkpoint Checkpoint, resume bool) error {
+	return bs.scanRange(ctx, chainName, query, from, to, func(logs []types.Log, end uint64) {
+		for _, vLog := range logs {
+			if resume && checkpoint.covers(vLog.BlockNumber, vLog.Index) {
+				continue
+			}
+			bs.processLog(chainName, vLog)
+		}
+
+		if err := bs.store.SaveCheckpoint(chainName, Checkpoint{Block: end, LogIndex: wholeBlock}); err != nil {
+			log.Printf("Failed to checkpoint %s: %v", chainName, err)
+		}
+	})
+}
+
+// scanRange runs query over blocks from..to in windows of the chain's
+// MaxBlocksPerQuery and hands each window's logs to handle, in order.
+func (bs *BridgeService) scanRange(ctx context.Context, chainName string, query ethereum.FilterQuery, from, to uint64, handle func(logs []types.Log, end uint64)) error {
+	chain, _ := bs.chains.GetChain(chainName)
+	client, ok := bs.chains.GetClient(chainName)
+	if !ok {
+		return errUnknownChain(chainName)
+	}
+	window := chain.MaxBlocksPerQuery
+	for start := from; start <= to; start += window {
+		end := start + window - 1
+		if end > to {
+			end = to
+		}
+
+		query.FromBlock = new(big.Int).SetUint64(start)
+		query.ToBlock = new(big.Int).SetUint64(end)
+		logs, err := client.FilterLogs(ctx, query)
+		if err != nil {
+			return fmt.Errorf("failed to fetch %s logs %d-%d: %v", chainName, start, end, err)
+		}
+		handle(logs, end)
+	}
+	return nil
+}
+
+// poll stands in for the log subscription on endpoints without one. Each
+// round scans from the shared checkpoint to the head, so switching between
+// polling and subscribing neither skips nor repeats logs.
+func (bs *BridgeService) poll(ctx context.Context, chainName string, query ethereum.FilterQuery, override *uint64) error {
+	chain, _ := bs.chains.GetChain(chainName)
+	client, ok := bs.chains.GetClient(chainName)
+	if !ok {
+		return errUnknownChain(chainName)
+	}
+	switched := client.Switched()
+	interval := time.Duration(chain.PollIntervalSeconds) * time.Second
+	log.Printf("%s endpoint does not support subscriptions, polling every %s", chainName, interval)
+
+	if err := bs.backfill(ctx, chainName, query, override); err != nil {
+		return err
+	}
+	// Like a fresh subscription, polling without a checkpoint starts at the
+	// live head.
+	if _, found, err := bs.store.GetCheckpoint(chainName); err != nil {
+		return err
+	} else if !found {
+		head, err := client.BlockNumber(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get %s head: %v", chainName, err)
+		}
+		if err := bs.store.SaveCheckpoint(chainName, Checkpoint{Block: head, LogIndex: wholeBlock}); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-bs.clock.After(interval):
+		case <-switched:
+			return errEndpointSwitched
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		checkpoint, _, err := bs.store.GetCheckpoint(chainName)
+		if err != nil {
+			return err
+		}
+		head, err := client.BlockNumber(ctx)
+		if err != nil {
+			rpcErrors.WithLabelValues(chainName, "block_number").Inc()
+			return fmt.Errorf("failed to get %s head: %v", chainName, err)
+		}
+		if checkpoint.Block > head {
+			continue
+		}
+		if err := bs.scanLogs(ctx, chainName, query, checkpoint.Block, head, checkpoint, true); err != nil {
+			rpcErrors.WithLabelValues(chainName, "filter_logs").Inc()
+			return err
+		}
+	}
+}
+
+func bridgeFilterQuery(contract common.Address, definitions []EventDefinition) ethereum.FilterQuery {
+	return ethereum.FilterQuery{
+		Addresses: []common.Address{contract},
+		Topics:    [][]common.Hash{topicsOf(definitions)},
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFakeClockFiresInDeadlineOrder(t *testing.T) {
+	clock := NewFakeClock(testEpoch)
+	late := clock.After(3 * time.Second)
+	early := clock.After(time.Second)
+	never := clock.After(time.Minute)
+
+	clock.Advance(2 * time.Second)
+	select {
+	case at := <-early:
+		if want := testEpoch.Add(time.Second); !at.Equal(want) {
+			t.Errorf("early timer sent %s, want its deadline %s", at, want)
+		}
+	default:
+		t.Fatal("timer due within the advanced span did not fire")
+	}
+	select {
+	case <-late:
+		t.Fatal("timer fired before its deadline")
+	default:
+	}
+
+	clock.Advance(time.Second)
+	if at := <-late; !at.Equal(testEpoch.Add(3 * time.Second)) {
+		t.Errorf("late timer sent %s", at)
+	}
+	select {
+	case <-never:
+		t.Fatal("timer fired a minute early")
+	default:
+	}
+	if now := clock.Now(); !now.Equal(testEpoch.Add(3 * time.Second)) {
+		t.Errorf("now = %s after advancing 3s", now)
+	}
+}
+
+func TestFakeClockTickerDropsUnreadTicks(t *testing.T) {
+	clock := NewFakeClock(testEpoch)
+	ticker := clock.NewTicker(time.Second)
+
+	clock.Advance(3 * time.Second)
+	if at := <-ticker.C(); !at.Equal(testEpoch.Add(time.Second)) {
+		t.Errorf("first tick at %s, want the first deadline", at)
+	}
+	select {
+	case at := <-ticker.C():
+		t.Errorf("unread ticks were queued, got %s", at)
+	default:
+	}
+
+	clock.Advance(time.Second)
+	if at := <-ticker.C(); !at.Equal(testEpoch.Add(4 * time.Second)) {
+		t.Errorf("tick after catching up at %s, want %s", at, testEpoch.Add(4*time.Second))
+	}
+
+	ticker.Stop()
+	clock.Advance(time.Minute)
+	select {
+	case <-ticker.C():
+		t.Error("stopped ticker ticked")
+	default:
+	}
+}
+
+func TestSleepReturnsWhenCancelled(t *testing.T) {
+	clock := NewFakeClock(testEpoch)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan bool)
+	go func() { done <- Sleep(ctx, clock, time.Hour) }()
+
+	clock.BlockUntil(1)
+	cancel()
+	if <-done {
+		t.Error("Sleep reported a full sleep after cancellation")
+	}
+
+	go func() { done <- SleepUntil(context.Background(), clock, testEpoch.Add(time.Hour)) }()
+	clock.BlockUntil(2)
+	clock.Advance(time.Hour)
+	if !<-done {
+		t.Error("SleepUntil reported cancellation")
+	}
+}
+
+// runLoops starts the event loop and the given background loops on ctx and
+// waits until each has armed its ticker.
+func (tb *testBridge) runLoops(ctx context.Context, loops ...func(context.Context)) {
+	tb.t.Helper()
+	go tb.ProcessBridgeEvents(ctx)
+	for _, loop := range loops {
+		go loop(ctx)
+	}
+	tb.clock.BlockUntil(len(loops))
+}
+
+// advanceUntil moves the clock on one step at a time, giving the loops a
+// moment to run after each, until done reports true.
+func (tb *testBridge) advanceUntil(step time.Duration, done func() bool) {
+	tb.t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			tb.t.Fatalf("not done after advancing to %s", tb.clock.Now())
+		}
+		tb.clock.Advance(step)
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestConfirmationTrackerOnVirtualTime(t *testing.T) {
+	tb := newTestBridge(t)
+	vLog := tb.emit(testSourceChain, tb.lockLog(5, 1, 1000))
+	id := lockEventID(testSourceChain, vLog)
+	tb.drain()
+	tb.mocks[testSourceChain].Mine(testConfirmations)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tb.runLoops(ctx, tb.TrackConfirmations)
+	tb.advanceUntil(confirmationPollInterval, func() bool { return tb.status(id) == StatusCompleted })
+
+	if mints := tb.minted(testTargetChain); len(mints) != 1 {
+		t.Errorf("minted %d times, want once", len(mints))
+	}
+}
+
+func TestRetryWorkerOnVirtualTime(t *testing.T) {
+	tb := newTestBridge(t)
+	vLog := tb.emit(testSourceChain, tb.lockLog(5, 1, 1000))
+	id := lockEventID(testSourceChain, vLog)
+	tb.drain()
+	tb.mocks[testTargetChain].SendErr = errors.New("connection reset")
+	tb.confirm()
+	if status := tb.status(id); status != StatusRetrying {
+		t.Fatalf("status = %s, want retrying", status)
+	}
+	tb.mocks[testTargetChain].SendErr = nil
+	failedAt := tb.clock.Now()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tb.runLoops(ctx, tb.RunRetries)
+	tb.advanceUntil(retryPollInterval, func() bool { return tb.status(id) == StatusCompleted })
+
+	if waited := Since(tb.clock, failedAt); waited < tb.retry.backoff(1) {
+		t.Errorf("retried after %s, before the %s backoff", waited, tb.retry.backoff(1))
+	}
+	if sent := len(tb.mocks[testTargetChain].Sent()); sent != 2 {
+		t.Errorf("sent %d transactions, want the failed one and the retry", sent)
+	}
+}
+
+func TestWebhookSweeperOnVirtualTime(t *testing.T) {
+	tb := newTestBridge(t)
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	tb.webhookCfg.applyDefaults()
+	tb.webhooks.put(Webhook{WebhookConfig: WebhookConfig{Name: "ops", URL: server.URL, Secret: "webhook-secret"}}, server.Client())
+
+	delivery := func() WebhookDelivery {
+		deliveries, err := tb.store.ListWebhookDeliveries("ops", "", 10)
+		if err != nil || len(deliveries) != 1 {
+			t.Fatalf("deliveries = %+v, %v", deliveries, err)
+		}
+		return deliveries[0]
+	}
+	tb.notifyWebhooks(BridgeEvent{ID: "ethereum-0x01-0", Type: "lock", Timestamp: testEpoch})
+	tb.advanceUntil(0, func() bool { return delivery().Attempts == 1 })
+	if d := delivery(); d.Status != deliveryPending || !d.NextAttempt.Equal(testEpoch.Add(tb.webhookCfg.Retry.backoff(1))) {
+		t.Fatalf("after the failed attempt: %s, next attempt %s", d.Status, d.NextAttempt)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tb.runLoops(ctx, tb.RunWebhooks)
+	tb.advanceUntil(webhookPollInterval, func() bool { return delivery().Status == deliveryDelivered })
+
+	if d := delivery(); d.Attempts != 2 || d.ResponseCode != http.StatusOK {
+		t.Errorf("delivered after %d attempts with HTTP %d, want 2 and 200", d.Attempts, d.ResponseCode)
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"gopkg.in/yaml.v3"
+)
+
+var configPath = flag.String("config", "", "path to the bridge config file (YAML or JSON); defaults to $BRIDGE_CONFIG")
+
+type ChainConfig struct {
+	Name            string    `json:"name" yaml:"name"`
+	RPCURL          string    `json:"rpcUrl" yaml:"rpcUrl"`
+	WSURL           string    `json:"wsUrl" yaml:"wsUrl"`
+	RPCURLs         []string  `json:"rpcUrls" yaml:"rpcUrls"`
+	Contract        string    `json:"contract" yaml:"contract"`
+	ContractVersion string    `json:"contractVersion" yaml:"contractVersion"`
+	ChainID         uint64    `json:"chainId" yaml:"chainId"`
+	Confirmations   uint64    `json:"confirmations" yaml:"confirmations"`
+	Finality        string    `json:"finality" yaml:"finality"`
+	AddressFormat   string    `json:"addressFormat" yaml:"addressFormat"`
+	Gas             GasConfig `json:"gas" yaml:"gas"`
+
+	// Used when the endpoint has no log subscriptions; MaxBlocksPerQuery
+	// also sizes backfill windows.
+	PollIntervalSeconds int    `json:"pollIntervalSeconds" yaml:"pollIntervalSeconds"`
+	MaxBlocksPerQuery   uint64 `json:"maxBlocksPerQuery" yaml:"maxBlocksPerQuery"`
+
+	Explorer ExplorerTemplates `json:"explorer" yaml:"explorer"`
+}
+
+type BridgeConfig struct {
+	Chains        []ChainConfig       `json:"chains" yaml:"chains"`
+	SignerPolicy  SignerPolicyConfig  `json:"signerPolicy" yaml:"signerPolicy"`
+	Callbacks     []CallbackConfig    `json:"callbacks" yaml:"callbacks"`
+	CallbackQueue CallbackQueueConfig `json:"callbackQueue" yaml:"callbackQueue"`
+	Integrity     IntegrityConfig     `json:"integrity" yaml:"integrity"`
+	Tokens        []TokenMapping      `json:"tokens" yaml:"tokens"`
+	Limits        []TransferLimit     `json:"limits" yaml:"limits"`
+	WebSocket     WebSocketConfig     `json:"websocket" yaml:"websocket"`
+	Auth          AuthConfig          `json:"auth" yaml:"auth"`
+	Retry         RetryConfig         `json:"retry" yaml:"retry"`
+	Webhooks      WebhooksConfig      `json:"webhooks" yaml:"webhooks"`
+	ChainChecks   ChainChecksConfig   `json:"chainChecks" yaml:"chainChecks"`
+}
+
+const defaultStatusCallbackURL = "http://localhost:5000/api/bridge/update-status"
+
+func resolveConfigPath() string {
+	if *configPath != "" {
+		return *configPath
+	}
+	return os.Getenv("BRIDGE_CONFIG")
+}
+
+// LoadConfig reads a YAML or JSON config file. ${VAR} references are expanded
+// from the environment so RPC API keys can stay out of the file.
+func LoadConfig(path string) (*BridgeConfig, error) {
+	if path == "" {
+		return nil, fmt.Errorf("no bridge config given: pass -config or set BRIDGE_CONFIG")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config %s: %v", path, err)
+	}
+	data = []byte(os.ExpandEnv(string(data)))
+
+	var cfg BridgeConfig
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &cfg)
+	default:
+		err = json.Unmarshal(data, &cfg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %v", path, err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %v", path, err)
+	}
+	return &cfg, nil
+}
+
+func (c *BridgeConfig) Validate() error {
+	if len(c.Chains) == 0 {
+		return fmt.Errorf("no chains configured")
+	}
+
+	seen := make(map[string]bool)
+	for i := range c.Chains {
+		chain := &c.Chains[i]
+		if chain.Name == "" {
+			return fmt.Errorf("chains[%d]: name is required", i)
+		}
+		if seen[chain.Name] {
+			return fmt.Errorf("chains[%d]: duplicate chain name %q", i, chain.Name)
+		}
+		seen[chain.Name] = true
+
+		if chain.RPCURL == "" && chain.WSURL == "" && len(chain.RPCURLs) == 0 {
+			return fmt.Errorf("chain %s: rpcUrl, wsUrl or rpcUrls is required", chain.Name)
+		}
+		if !common.IsHexAddress(chain.Contract) {
+			return fmt.Errorf("chain %s: contract %q is not a valid address", chain.Name, chain.Contract)
+		}
+		if chain.ChainID == 0 {
+			return fmt.Errorf("chain %s: chainId is required", chain.Name)
+		}
+		if chain.ContractVersion == "" {
+			chain.ContractVersion = defaultContractVersion
+		}
+		if chain.PollIntervalSeconds == 0 {
+			chain.PollIntervalSeconds = defaultPollIntervalSeconds
+		}
+		if chain.MaxBlocksPerQuery == 0 {
+			chain.MaxBlocksPerQuery = defaultMaxBlocksPerQuery
+		}
+		switch chain.Finality {
+		case "":
+			chain.Finality = finalityDepth
+		case finalityDepth, finalityInstant:
+		default:
+			return fmt.Errorf("chain %s: finality must be %q or %q", chain.Name, finalityDepth, finalityInstant)
+		}
+		if chain.AddressFormat == "" {
+			chain.AddressFormat = addressFormatEVM
+		}
+		if addressFormats[chain.AddressFormat] == nil {
+			return fmt.Errorf("chain %s: unknown addressFormat %q", chain.Name, chain.AddressFormat)
+		}
+		chain.Gas.applyDefaults()
+		if err := chain.Gas.validate(); err != nil {
+			return fmt.Errorf("chain %s: %v", chain.Name, err)
+		}
+	}
+
+	for i := range c.Tokens {
+		if err := c.Tokens[i].validate(seen); err != nil {
+			return fmt.Errorf("tokens[%d]: %v", i, err)
+		}
+	}
+	for i := range c.Limits {
+		if err := c.Limits[i].validate(seen); err != nil {
+			return fmt.Errorf("limits[%d]: %v", i, err)
+		}
+	}
+
+	c.SignerPolicy.applyDefaults()
+	c.Integrity.applyDefaults()
+	c.WebSocket.applyDefaults()
+	if c.WebSocket.PongTimeoutSeconds <= c.WebSocket.PingIntervalSeconds {
+		return fmt.Errorf("websocket: pongTimeoutSeconds must be longer than pingIntervalSeconds")
+	}
+	c.Retry.applyDefaults()
+	if err := c.Retry.validate("retry"); err != nil {
+		return err
+	}
+	if err := c.Auth.validate(); err != nil {
+		return err
+	}
+	c.ChainChecks.applyDefaults()
+	if err := c.ChainChecks.validate(); err != nil {
+		return err
+	}
+	c.Webhooks.applyDefaults()
+	if err := c.Webhooks.Retry.validate("webhooks.retry"); err != nil {
+		return err
+	}
+	if c.Webhooks.TimeoutSeconds < 1 || c.Webhooks.DisableAfterFailures < 1 {
+		return fmt.Errorf("webhooks: timeoutSeconds and disableAfterFailures must be positive")
+	}
+	webhookNames := make(map[string]bool)
+	for i, endpoint := range c.Webhooks.Endpoints {
+		if err := endpoint.validate(); err != nil {
+			return fmt.Errorf("webhooks.endpoints[%d]: %v", i, err)
+		}
+		if webhookNames[endpoint.Name] {
+			return fmt.Errorf("webhooks.endpoints[%d]: duplicate name %q", i, endpoint.Name)
+		}
+		webhookNames[endpoint.Name] = true
+	}
+
+	if len(c.Callbacks) == 0 {
+		c.Callbacks = []CallbackConfig{{URL: defaultStatusCallbackURL, PayloadVersion: 1}}
+	}
+	// Queued updates are keyed by URL, so two entries for one URL would
+	// share, and drain, a single queue.
+	callbackURLs := make(map[string]bool)
+	for i := range c.Callbacks {
+		callback := &c.Callbacks[i]
+		if callback.URL == "" {
+			return fmt.Errorf("callbacks[%d]: url is required", i)
+		}
+		if callbackURLs[callback.URL] {
+			return fmt.Errorf("callbacks[%d]: duplicate url %q", i, callback.URL)
+		}
+		callbackURLs[callback.URL] = true
+		if callback.PayloadVersion == 0 {
+			callback.PayloadVersion = 1
+		}
+		if callback.PayloadVersion != 1 && callback.PayloadVersion != 2 {
+			return fmt.Errorf("callbacks[%d]: unsupported payloadVersion %d", i, callback.PayloadVersion)
+		}
+	}
+	c.CallbackQueue.applyDefaults()
+	if err := c.CallbackQueue.Retry.validate("callbackQueue.retry"); err != nil {
+		return err
+	}
+	if c.CallbackQueue.MaxPending < 1 || c.CallbackQueue.BatchSize < 1 {
+		return fmt.Errorf("callbackQueue: maxPending and batchSize must be positive")
+	}
+	return nil
+}
+
+// DialURL prefers the websocket endpoint because log subscriptions need one.
+func (c ChainConfig) DialURL() string {
+	if c.WSURL != "" {
+		return c.WSURL
+	}
+	return c.RPCURL
+}
+
+// Endpoints lists the chain's RPC endpoints in order of preference. rpcUrls,
+// when set, replaces wsUrl and rpcUrl.
+func (c ChainConfig) Endpoints() []string {
+	if len(c.RPCURLs) > 0 {
+		return c.RPCURLs
+	}
+	return []string{c.DialURL()}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	confirmationPollInterval = 5 * time.Second
+	instantReceiptTimeout    = 10 * time.Second
+
+	finalityDepth   = "depth"
+	finalityInstant = "instant"
+)
+
+// ConfirmationTracker holds lock and burn events until their source block is buried
+// deep enough to be safe from reorgs.
+type ConfirmationTracker struct {
+	mu      sync.Mutex
+	pending map[string]BridgeEvent
+}
+
+func NewConfirmationTracker() *ConfirmationTracker {
+	return &ConfirmationTracker{pending: make(map[string]BridgeEvent)}
+}
+
+func (ct *ConfirmationTracker) Track(event BridgeEvent) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	ct.pending[event.ID] = event
+}
+
+func (ct *ConfirmationTracker) Remove(id string) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	delete(ct.pending, id)
+}
+
+func (ct *ConfirmationTracker) Snapshot() []BridgeEvent {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	events := make([]BridgeEvent, 0, len(ct.pending))
+	for _, event := range ct.pending {
+		events = append(events, event)
+	}
+	return events
+}
+
+func (ct *ConfirmationTracker) Len() int {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	return len(ct.pending)
+}
+
+func (bs *BridgeService) TrackConfirmations(ctx context.Context) {
+	Every(ctx, bs.clock, confirmationPollInterval, bs.checkConfirmations)
+}
+
+func (bs *BridgeService) checkConfirmations(ctx context.Context) {
+	for _, event := range bs.confirmations.Snapshot() {
+		head, err := bs.currentHead(ctx, event.FromChain)
+		if err != nil {
+			log.Printf("Failed to get %s head for %s: %v", event.FromChain, event.ID, err)
+			continue
+		}
+		chain, _ := bs.chains.GetChain(event.FromChain)
+		if head < event.BlockNumber+chain.Confirmations {
+			continue
+		}
+
+		client, _ := bs.chains.GetClient(event.FromChain)
+		receipt, err := client.TransactionReceipt(ctx, common.HexToHash(event.TxHash))
+		if errors.Is(err, ethereum.NotFound) {
+			bs.confirmations.Remove(event.ID)
+			bs.markReorged(event)
+			continue
+		}
+		if err != nil {
+			rpcErrors.WithLabelValues(event.FromChain, "transaction_receipt").Inc()
+			log.Printf("Failed to re-check receipt for %s: %v", event.ID, err)
+			continue
+		}
+
+		// The log was re-included in a different block; restart the count.
+		if event.BlockHash != "" && receipt.BlockHash.Hex() != event.BlockHash {
+			log.Printf("Re-included %s %s in block %d (was %d), waiting for confirmations again",
+				event.Type, event.ID, receipt.BlockNumber.Uint64(), event.BlockNumber)
+			event.BlockNumber = receipt.BlockNumber.Uint64()
+			event.BlockHash = receipt.BlockHash.Hex()
+			bs.confirmations.Track(event)
+			continue
+		}
+
+		bs.confirmations.Remove(event.ID)
+		bs.promoteConfirmed(event)
+	}
+}
+
+// confirmInstant settles events from chains whose blocks are final once
+// produced. The receipt is still checked so a log from a node that later
+// disowns it isn't acted on; anything unexpected falls back to the depth
+// tracker.
+func (bs *BridgeService) confirmInstant(event BridgeEvent) {
+	ctx, cancel := context.WithTimeout(context.Background(), instantReceiptTimeout)
+	defer cancel()
+
+	client, ok := bs.chains.GetClient(event.FromChain)
+	if !ok {
+		log.Printf("Not confirming %s: %v", event.ID, errUnknownChain(event.FromChain))
+		return
+	}
+	receipt, err := client.TransactionReceipt(ctx, common.HexToHash(event.TxHash))
+	if err != nil || (event.BlockHash != "" && receipt.BlockHash.Hex() != event.BlockHash) {
+		log.Printf("Instant confirmation of %s not possible, falling back to depth tracking: %v", event.ID, err)
+		event.Confirmation = finalityDepth
+		if err := bs.store.SaveEvent(event); err != nil {
+			log.Printf("Failed to persist %s event %s: %v", event.Type, event.ID, err)
+		}
+		bs.confirmations.Track(event)
+		return
+	}
+
+	bs.promoteConfirmed(event)
+}
+
+func (bs *BridgeService) currentHead(ctx context.Context, chainName string) (uint64, error) {
+	if header, fresh := bs.heads.GetHead(chainName); fresh {
+		return header.Number.Uint64(), nil
+	}
+
+	client, ok := bs.chains.GetClient(chainName)
+	if !ok {
+		return 0, fmt.Errorf("no client for chain %s", chainName)
+	}
+	header, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		rpcErrors.WithLabelValues(chainName, "header_by_number").Inc()
+		return 0, err
+	}
+	return header.Number.Uint64(), nil
+}
+
+// promoteConfirmed hands a buried lock to the minter and a buried burn to
+// the unlocker, unless a pause or a transfer limit holds it back.
+func (bs *BridgeService) promoteConfirmed(event BridgeEvent) {
+	if pause, blocked := bs.pauses.Blocks(event); blocked {
+		bs.holdPaused(event, pause)
+		return
+	}
+	if !bs.withinLimits(event) {
+		return
+	}
+	settle, status := bs.initiateMint, StatusConfirmed
+	if event.Type == "burn" {
+		settle, status = bs.initiateUnlock, StatusUnlocking
+	}
+	if !bs.TransitionStatus(&event, status) {
+		return
+	}
+	if err := bs.store.UpdateStatus(event.ID, event.Status); err != nil {
+		log.Printf("Failed to persist status of %s: %v", event.ID, err)
+	}
+	if event.Confirmation == finalityInstant {
+		log.Printf("Confirmed %s %s instantly on %s", event.Type, event.ID, event.FromChain)
+	} else {
+		chain, _ := bs.chains.GetChain(event.FromChain)
+		log.Printf("Confirmed %s %s at depth %d on %s", event.Type, event.ID, chain.Confirmations, event.FromChain)
+	}
+
+	bs.updateTransactionStatus(event)
+	bs.broadcastEvent(event)
+	if !bs.startSettlement(event, settle) {
+		log.Printf("Shutting down, leaving %s for replay", event.ID)
+	}
+}
+
+// markReorged drops a lock or burn whose log is gone. The log may still be
+// re-included later, so unlike a settled transfer it takes no corridor
+// sequence number.
+func (bs *BridgeService) markReorged(event BridgeEvent) {
+	if !bs.TransitionStatus(&event, StatusReorged) {
+		return
+	}
+	if err := bs.store.UpdateStatus(event.ID, event.Status); err != nil {
+		log.Printf("Failed to persist status of %s: %v", event.ID, err)
+	}
+	log.Printf("Reorg dropped %s %s on %s, it will not be settled", event.Type, event.ID, event.FromChain)
+
+	bs.updateTransactionStatus(event)
+	bs.broadcastEvent(event)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// Transfers finalized concurrently get the sequence numbers 1..n of their
+// corridor, each exactly once, and finalizing again returns the same number.
+func TestCorridorSequenceIsGaplessUnderConcurrency(t *testing.T) {
+	const transfers = 300
+	store := newTestStore(t, "")
+	corridor := corridorOf(BridgeEvent{FromChain: testSourceChain, ToChain: testTargetChain})
+	ids := make([]string, transfers)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("ethereum-0x%04x-0", i)
+		nonce := fmt.Sprintf("0x%064x", i)
+		event := BridgeEvent{ID: ids[i], Type: "lock", FromChain: testSourceChain, ToChain: testTargetChain,
+			Nonce: nonce, TransferKey: testSourceChain + ":" + nonce, Status: StatusMinting}
+		if err := store.SaveEvent(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	seqs := make([]uint64, transfers)
+	again := make([]uint64, transfers)
+	var wg sync.WaitGroup
+	for i := range ids {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var err error
+			if seqs[i], err = store.FinalizeStatus(ids[i], StatusCompleted, corridor); err != nil {
+				t.Error(err)
+				return
+			}
+			if again[i], err = store.FinalizeStatus(ids[i], StatusCompleted, corridor); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	owner := make(map[uint64]string, transfers)
+	for i, seq := range seqs {
+		if seq < 1 || seq > transfers {
+			t.Errorf("%s got seq %d, outside 1..%d", ids[i], seq, transfers)
+		}
+		if prev, ok := owner[seq]; ok {
+			t.Errorf("seq %d given to both %s and %s", seq, prev, ids[i])
+		}
+		owner[seq] = ids[i]
+		if again[i] != seq {
+			t.Errorf("%s finalized again got seq %d, want %d", ids[i], again[i], seq)
+		}
+	}
+
+	listed, err := store.ListCorridor(corridor, 1, transfers+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != transfers {
+		t.Fatalf("corridor lists %d transfers, want %d", len(listed), transfers)
+	}
+	for i, event := range listed {
+		if want := uint64(i + 1); event.CorridorSeq != want || event.ID != owner[want] || event.Status != StatusCompleted {
+			t.Fatalf("position %d holds %s with seq %d (%s), want %s with seq %d", i, event.ID, event.CorridorSeq, event.Status, owner[want], want)
+		}
+	}
+}
+
+// A transfer dropped by a reorg never settles, so it takes no place in the
+// corridor and leaves no gap before the next completed transfer.
+func TestReorgedTransferTakesNoCorridorSequence(t *testing.T) {
+	tb := newTestBridge(t)
+	dropped := tb.emit(testSourceChain, tb.lockLog(5, 1, 1000))
+	droppedID := lockEventID(testSourceChain, dropped)
+	tb.drain()
+	tb.reorgOut(testSourceChain, dropped)
+	tb.confirm()
+
+	event, err := tb.store.GetByID(droppedID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Status != StatusReorged || event.CorridorSeq != 0 {
+		t.Fatalf("dropped lock is %s with seq %d, want reorged without one", event.Status, event.CorridorSeq)
+	}
+
+	settled := tb.emit(testSourceChain, tb.lockLog(12, 2, 1000))
+	settledID := lockEventID(testSourceChain, settled)
+	tb.drain()
+	tb.confirm()
+
+	event, err = tb.store.GetByID(settledID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Status != StatusCompleted || event.CorridorSeq != 1 {
+		t.Errorf("next lock is %s with seq %d, want completed with 1", event.Status, event.CorridorSeq)
+	}
+	listed, err := tb.store.ListCorridor(corridorOf(*event), 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 1 || listed[0].ID != settledID {
+		t.Errorf("corridor lists %d transfers, want only %s", len(listed), settledID)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestListenedEventsResolveInEveryABI(t *testing.T) {
+	registry, err := LoadEventRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for version := range registry.versions {
+		definitions, err := registry.Resolve(version, listenedEvents)
+		if err != nil {
+			t.Errorf("ABI %s: %v", version, err)
+			continue
+		}
+		for i, def := range definitions {
+			if want := crypto.Keccak256Hash([]byte(listenedEvents[i])); def.Topic != want {
+				t.Errorf("ABI %s resolved %s to topic %s, want %s", version, listenedEvents[i], def.Topic.Hex(), want.Hex())
+			}
+		}
+	}
+}
+
+func TestTypoedEventSignatureFailsValidation(t *testing.T) {
+	registry, err := LoadEventRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, typo := range []string{
+		"Lockd(address,address,bytes32,bytes,uint256,bytes32)",
+		"Locked(address,address,bytes32,bytes,uint256)",
+		"Locked(address, address, bytes32, bytes, uint256, bytes32)",
+		"Burned(address,address,bytes32,bytes,uint128,bytes32)",
+	} {
+		signatures := append([]string{typo}, listenedEvents...)
+		_, err := registry.Resolve(defaultContractVersion, signatures)
+		if err == nil || !strings.Contains(err.Error(), typo) {
+			t.Errorf("resolving %q: err = %v, want it named", typo, err)
+		}
+	}
+}
+
+func TestResolveUnknownContractVersion(t *testing.T) {
+	registry, err := LoadEventRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := registry.Resolve("v0", listenedEvents); err == nil {
+		t.Error("resolved events against an ABI that does not exist")
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import "testing"
+
+func TestRenderExplorerURL(t *testing.T) {
+	tests := []struct {
+		name        string
+		template    string
+		placeholder string
+		value       string
+		want        string
+	}{
+		{"evm tx", "https://etherscan.io/tx/{tx}", "{tx}", "0xabc123", "https://etherscan.io/tx/0xabc123"},
+		{"solana signature", "https://solscan.io/tx/{tx}", "{tx}", "5VERv8NMvzbJMEkV8xnrLkEaWRtSz9CosKDYjCJjBRnbJLgp8uirBgmQpjKhoR4tjF3ZpRzrFmBV6UjKdiSZkQUW",
+			"https://solscan.io/tx/5VERv8NMvzbJMEkV8xnrLkEaWRtSz9CosKDYjCJjBRnbJLgp8uirBgmQpjKhoR4tjF3ZpRzrFmBV6UjKdiSZkQUW"},
+		{"bech32 address", "https://mintscan.io/cosmos/address/{address}", "{address}", "cosmos1abc", "https://mintscan.io/cosmos/address/cosmos1abc"},
+		{"slash is escaped", "https://x.io/tx/{tx}", "{tx}", "5/sig/0", "https://x.io/tx/5%2Fsig%2F0"},
+		{"query characters are escaped", "https://x.io/tx/{tx}", "{tx}", "a?b#c", "https://x.io/tx/a%3Fb%23c"},
+		{"space is escaped", "https://x.io/address/{address}", "{address}", "a b", "https://x.io/address/a%20b"},
+		{"repeated placeholder", "https://x.io/{tx}#{tx}", "{tx}", "0x1", "https://x.io/0x1#0x1"},
+		{"missing template", "", "{tx}", "0x1", ""},
+		{"missing value", "https://x.io/tx/{tx}", "{tx}", "", ""},
+		{"template without placeholder", "https://x.io/tx/", "{tx}", "0x1", ""},
+		{"other placeholder", "https://x.io/address/{address}", "{tx}", "0x1", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderExplorerURL(tt.template, tt.placeholder, tt.value); got != tt.want {
+				t.Errorf("renderExplorerURL(%q, %q, %q) = %q, want %q", tt.template, tt.placeholder, tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLockAndMintExplorerLinks(t *testing.T) {
+	bs := newBridgeService(NewFakeClock(testEpoch))
+	bs.explorers["ethereum"] = defaultExplorers["ethereum"]
+	bs.explorers["solana"] = ExplorerTemplates{TxURL: "https://solscan.io/tx/{tx}", AddressURL: "https://solscan.io/account/{address}"}
+
+	event := BridgeEvent{
+		FromChain: "ethereum",
+		ToChain:   "solana",
+		TxHash:    "0xabc",
+		Sender:    "0x3000000000000000000000000000000000000003",
+		Recipient: "So1ana+Recipient/1",
+		Token:     "0x1000000000000000000000000000000000000001",
+	}
+	links := bs.lockExplorerLinks(event)
+	want := ExplorerLinks{
+		LockTx:    "https://etherscan.io/tx/0xabc",
+		Sender:    "https://etherscan.io/address/0x3000000000000000000000000000000000000003",
+		Recipient: "https://solscan.io/account/So1ana+Recipient%2F1",
+		Token:     "https://etherscan.io/token/0x1000000000000000000000000000000000000001",
+	}
+	if links == nil || *links != want {
+		t.Fatalf("lock links = %+v, want %+v", links, want)
+	}
+
+	event.ExplorerLinks = links
+	mint := bs.mintExplorerLinks(event, "4sGjMW1sUnHzSxGspuhpqLDx6wiyjNtZ")
+	want.MintTx = "https://solscan.io/tx/4sGjMW1sUnHzSxGspuhpqLDx6wiyjNtZ"
+	if mint == nil || *mint != want {
+		t.Errorf("mint links = %+v, want %+v", mint, want)
+	}
+	if *links == *mint {
+		t.Error("mint links share the lock's")
+	}
+}
+
+func TestExplorerLinksOmittedWithoutTemplates(t *testing.T) {
+	bs := newBridgeService(NewFakeClock(testEpoch))
+	event := BridgeEvent{FromChain: "unknown", ToChain: "other", TxHash: "0xabc", Sender: "0x1"}
+	if links := bs.lockExplorerLinks(event); links != nil {
+		t.Errorf("lock links = %+v, want none", links)
+	}
+	if links := bs.mintExplorerLinks(event, "0xdef"); links != nil {
+		t.Errorf("mint links = %+v, want none", links)
+	}
+}
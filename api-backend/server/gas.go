@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+const (
+	feeModeAuto    = "auto"
+	feeModeEIP1559 = "eip1559"
+	feeModeLegacy  = "legacy"
+
+	defaultGasLimitMarginPercent = 20
+)
+
+// ErrFeeCapExceeded is returned when sending a settlement would cost more
+// per gas than the destination chain's maxFeeGwei allows.
+var ErrFeeCapExceeded = errors.New("gas fee above cap")
+
+// GasConfig sets how settlement transactions on a chain are priced. In auto
+// mode a chain whose latest header carries a base fee gets EIP-1559
+// transactions and any other chain legacy ones. The estimated gas limit is
+// raised by LimitMarginPercent. MaxFeeGwei caps what a transaction may pay
+// per gas, on top of the signer policy's cap; MaxPriorityFeeGwei caps the
+// suggested tip.
+type GasConfig struct {
+	FeeMode            string `json:"feeMode" yaml:"feeMode"`
+	LimitMarginPercent int    `json:"limitMarginPercent" yaml:"limitMarginPercent"`
+	MaxFeeGwei         uint64 `json:"maxFeeGwei" yaml:"maxFeeGwei"`
+	MaxPriorityFeeGwei uint64 `json:"maxPriorityFeeGwei" yaml:"maxPriorityFeeGwei"`
+
+	// A settlement not mined StuckAfterSeconds after it was sent is sent
+	// again with the same nonce and fees raised by BumpPercent, at most
+	// MaxBumps times and never above MaxFeeGwei (or the signer policy's cap).
+	StuckAfterSeconds int `json:"stuckAfterSeconds" yaml:"stuckAfterSeconds"`
+	MaxBumps          int `json:"maxBumps" yaml:"maxBumps"`
+	BumpPercent       int `json:"bumpPercent" yaml:"bumpPercent"`
+}
+
+func (c *GasConfig) applyDefaults() {
+	if c.FeeMode == "" {
+		c.FeeMode = feeModeAuto
+	}
+	if c.LimitMarginPercent == 0 {
+		c.LimitMarginPercent = defaultGasLimitMarginPercent
+	}
+	if c.StuckAfterSeconds == 0 {
+		c.StuckAfterSeconds = 180
+	}
+	if c.MaxBumps == 0 {
+		c.MaxBumps = 3
+	}
+	if c.BumpPercent == 0 {
+		c.BumpPercent = 15
+	}
+}
+
+func (c GasConfig) validate() error {
+	switch c.FeeMode {
+	case feeModeAuto, feeModeEIP1559, feeModeLegacy:
+	default:
+		return fmt.Errorf("gas.feeMode must be %q, %q or %q", feeModeAuto, feeModeEIP1559, feeModeLegacy)
+	}
+	if c.LimitMarginPercent < 0 {
+		return fmt.Errorf("gas.limitMarginPercent must not be negative")
+	}
+	if c.StuckAfterSeconds < 1 || c.MaxBumps < 0 {
+		return fmt.Errorf("gas.stuckAfterSeconds must be positive and gas.maxBumps not negative")
+	}
+	// Nodes only accept a replacement that raises both fees by 10%.
+	if c.BumpPercent < 10 {
+		return fmt.Errorf("gas.bumpPercent must be at least 10")
+	}
+	return nil
+}
+
+// GasParams are the gas settings a settlement transaction was sent with, in
+// wei. Legacy transactions only set GasPrice.
+type GasParams struct {
+	GasLimit             uint64 `json:"gasLimit"`
+	GasPrice             string `json:"gasPrice,omitempty"`
+	MaxFeePerGas         string `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas string `json:"maxPriorityFeePerGas,omitempty"`
+	BaseFee              string `json:"baseFee,omitempty"`
+}
+
+func gwei(n uint64) *big.Int {
+	return new(big.Int).Mul(new(big.Int).SetUint64(n), big.NewInt(params.GWei))
+}
+
+// gasParamsOf reports the gas settings of tx, or nil if no transaction was
+// signed.
+func gasParamsOf(tx *types.Transaction, baseFee *big.Int) *GasParams {
+	if tx == nil {
+		return nil
+	}
+	gas := &GasParams{GasLimit: tx.Gas()}
+	if tx.Type() == types.LegacyTxType {
+		gas.GasPrice = tx.GasPrice().String()
+		return gas
+	}
+	gas.MaxFeePerGas = tx.GasFeeCap().String()
+	gas.MaxPriorityFeePerGas = tx.GasTipCap().String()
+	if baseFee != nil {
+		gas.BaseFee = baseFee.String()
+	}
+	return gas
+}
+
+// buildSettlementTx estimates gas for calldata to contract and prices it
+// for chain, returning the unsigned transaction and the base fee it was
+// priced against (nil for legacy transactions).
+func buildSettlementTx(ctx context.Context, chain ChainConfig, client ChainClient, from, contract common.Address, nonce uint64, calldata []byte) (*types.Transaction, *big.Int, error) {
+	estimate, err := client.EstimateGas(ctx, ethereum.CallMsg{From: from, To: &contract, Data: calldata})
+	if err != nil {
+		rpcErrors.WithLabelValues(chain.Name, "estimate_gas").Inc()
+		return nil, nil, fmt.Errorf("gas estimation failed: %v", err)
+	}
+	gasLimit := estimate + estimate*uint64(chain.Gas.LimitMarginPercent)/100
+
+	var baseFee *big.Int
+	if chain.Gas.FeeMode != feeModeLegacy {
+		head, err := client.HeaderByNumber(ctx, nil)
+		if err != nil {
+			rpcErrors.WithLabelValues(chain.Name, "header").Inc()
+			return nil, nil, fmt.Errorf("failed to fetch base fee: %v", err)
+		}
+		baseFee = head.BaseFee
+		if baseFee == nil && chain.Gas.FeeMode == feeModeEIP1559 {
+			return nil, nil, fmt.Errorf("chain %s has no base fee but gas.feeMode is %s", chain.Name, feeModeEIP1559)
+		}
+	}
+	var maxFee *big.Int
+	if chain.Gas.MaxFeeGwei > 0 {
+		maxFee = gwei(chain.Gas.MaxFeeGwei)
+	}
+
+	if baseFee == nil {
+		gasPrice, err := client.SuggestGasPrice(ctx)
+		if err != nil {
+			rpcErrors.WithLabelValues(chain.Name, "gas_price").Inc()
+			return nil, nil, fmt.Errorf("failed to fetch gas price: %v", err)
+		}
+		if maxFee != nil && gasPrice.Cmp(maxFee) > 0 {
+			return nil, nil, fmt.Errorf("%w: gas price %s exceeds %s on %s", ErrFeeCapExceeded, gasPrice, maxFee, chain.Name)
+		}
+		return types.NewTransaction(nonce, contract, big.NewInt(0), gasLimit, gasPrice, calldata), nil, nil
+	}
+
+	tip, err := client.SuggestGasTipCap(ctx)
+	if err != nil {
+		rpcErrors.WithLabelValues(chain.Name, "gas_tip").Inc()
+		return nil, nil, fmt.Errorf("failed to fetch priority fee: %v", err)
+	}
+	if chain.Gas.MaxPriorityFeeGwei > 0 && tip.Cmp(gwei(chain.Gas.MaxPriorityFeeGwei)) > 0 {
+		tip = gwei(chain.Gas.MaxPriorityFeeGwei)
+	}
+	// Twice the base fee keeps the transaction includable through several
+	// full blocks; the cap only has to clear the current base fee and tip.
+	feeCap := new(big.Int).Add(new(big.Int).Mul(baseFee, big.NewInt(2)), tip)
+	if maxFee != nil && feeCap.Cmp(maxFee) > 0 {
+		if needed := new(big.Int).Add(baseFee, tip); needed.Cmp(maxFee) > 0 {
+			return nil, nil, fmt.Errorf("%w: base fee %s plus tip %s exceeds %s on %s", ErrFeeCapExceeded, baseFee, tip, maxFee, chain.Name)
+		}
+		feeCap = maxFee
+	}
+
+	chainID := new(big.Int).SetUint64(chain.ChainID)
+	return types.NewTx(&types.DynamicFeeTx{
+		ChainID:   chainID,
+		Nonce:     nonce,
+		GasTipCap: tip,
+		GasFeeCap: feeCap,
+		Gas:       gasLimit,
+		To:        &contract,
+		Value:     big.NewInt(0),
+		Data:      calldata,
+	}), baseFee, nil
+}
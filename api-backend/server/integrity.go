@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	maxIntegrityFindings = 100
+
+	// Below this many checks the mismatch rate is too noisy to alert on.
+	integrityMinChecks = 20
+)
+
+// IntegrityConfig bounds the sampler's load on the RPC providers: each round
+// re-fetches at most SampleSize receipts.
+type IntegrityConfig struct {
+	IntervalSeconds   int     `json:"intervalSeconds" yaml:"intervalSeconds"`
+	SampleSize        int     `json:"sampleSize" yaml:"sampleSize"`
+	AlertMismatchRate float64 `json:"alertMismatchRate" yaml:"alertMismatchRate"`
+}
+
+func (c *IntegrityConfig) applyDefaults() {
+	if c.IntervalSeconds == 0 {
+		c.IntervalSeconds = 60
+	}
+	if c.SampleSize == 0 {
+		c.SampleSize = 5
+	}
+	if c.AlertMismatchRate == 0 {
+		c.AlertMismatchRate = 0.01
+	}
+}
+
+type FieldDiff struct {
+	Stored string `json:"stored"`
+	Chain  string `json:"chain"`
+}
+
+type IntegrityFinding struct {
+	EventID   string               `json:"eventId"`
+	Severity  string               `json:"severity"`
+	Reason    string               `json:"reason"`
+	Diff      map[string]FieldDiff `json:"diff,omitempty"`
+	CheckedAt time.Time            `json:"checkedAt"`
+}
+
+// IntegritySampler keeps running totals and the most recent findings.
+type IntegritySampler struct {
+	cfg IntegrityConfig
+
+	mu         sync.Mutex
+	checked    uint64
+	mismatches uint64
+	findings   []IntegrityFinding
+	alerting   bool
+}
+
+func NewIntegritySampler(cfg IntegrityConfig) *IntegritySampler {
+	return &IntegritySampler{cfg: cfg}
+}
+
+func (is *IntegritySampler) record(finding *IntegrityFinding) {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+
+	is.checked++
+	if finding != nil {
+		is.mismatches++
+		log.Printf("CRITICAL: integrity mismatch for %s: %s %v", finding.EventID, finding.Reason, finding.Diff)
+		is.findings = append(is.findings, *finding)
+		if len(is.findings) > maxIntegrityFindings {
+			is.findings = is.findings[len(is.findings)-maxIntegrityFindings:]
+		}
+	}
+
+	rate := is.rateLocked()
+	above := is.checked >= integrityMinChecks && rate > is.cfg.AlertMismatchRate
+	if above && !is.alerting {
+		log.Printf("ALERT: integrity mismatch rate %.2f%% is above %.2f%% (%d of %d sampled)",
+			rate*100, is.cfg.AlertMismatchRate*100, is.mismatches, is.checked)
+	}
+	is.alerting = above
+}
+
+func (is *IntegritySampler) rateLocked() float64 {
+	if is.checked == 0 {
+		return 0
+	}
+	return float64(is.mismatches) / float64(is.checked)
+}
+
+func (bs *BridgeService) RunIntegritySampler(ctx context.Context) {
+	Every(ctx, bs.clock, time.Duration(bs.integrity.cfg.IntervalSeconds)*time.Second, bs.sampleIntegrity)
+}
+
+func (bs *BridgeService) sampleIntegrity(ctx context.Context) {
+	events, err := bs.store.SampleCompleted(bs.integrity.cfg.SampleSize)
+	if err != nil {
+		log.Printf("Integrity sampling failed: %v", err)
+		return
+	}
+
+	for _, event := range events {
+		finding, err := bs.checkIntegrity(ctx, event)
+		if err != nil {
+			// An RPC hiccup says nothing about the stored data; skip it.
+			log.Printf("Integrity check of %s skipped: %v", event.ID, err)
+			continue
+		}
+		bs.integrity.record(finding)
+	}
+}
+
+// checkIntegrity re-decodes the source log of a stored transfer and compares
+// it with what was stored. It returns a nil finding when they agree.
+func (bs *BridgeService) checkIntegrity(ctx context.Context, stored BridgeEvent) (*IntegrityFinding, error) {
+	client, ok := bs.chains.GetClient(stored.FromChain)
+	if !ok {
+		return nil, fmt.Errorf("no client for chain %s", stored.FromChain)
+	}
+	mismatch := func(reason string, diff map[string]FieldDiff) *IntegrityFinding {
+		return &IntegrityFinding{EventID: stored.ID, Severity: "critical", Reason: reason, Diff: diff, CheckedAt: bs.clock.Now()}
+	}
+
+	logIndex, err := logIndexOf(stored.ID)
+	if err != nil {
+		return mismatch(err.Error(), nil), nil
+	}
+
+	receipt, err := client.TransactionReceipt(ctx, common.HexToHash(stored.TxHash))
+	if errors.Is(err, ethereum.NotFound) {
+		return mismatch("source transaction not found on chain", nil), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var source *types.Log
+	for _, vLog := range receipt.Logs {
+		if vLog.Index == logIndex {
+			source = vLog
+		}
+	}
+	if source == nil || len(source.Topics) == 0 {
+		return mismatch(fmt.Sprintf("source log %d not in receipt", logIndex), nil), nil
+	}
+
+	var decoded LockEvent
+	switch bs.eventName(stored.FromChain, source.Topics[0]) {
+	case "Locked":
+		err = bs.unpackTransferLog(stored.FromChain, "Locked", *source, &decoded)
+	case "Burned":
+		err = bs.unpackTransferLog(stored.FromChain, "Burned", *source, (*BurnEvent)(&decoded))
+	default:
+		return mismatch("source log is not a bridge event", nil), nil
+	}
+	if err != nil {
+		return mismatch(fmt.Sprintf("source log does not decode: %v", err), nil), nil
+	}
+	expected := bs.transferEvent(stored.FromChain, *source, stored.Type, stored.Status, decoded)
+
+	diff := make(map[string]FieldDiff)
+	compare := func(field, storedValue, chainValue string) {
+		if storedValue != chainValue {
+			diff[field] = FieldDiff{Stored: storedValue, Chain: chainValue}
+		}
+	}
+	compare("amount", stored.Amount, expected.Amount)
+	compare("token", stored.Token, expected.Token)
+	compare("sender", stored.Sender, expected.Sender)
+	// Transfers recorded before recipients were normalized hold the raw
+	// targetAddr.
+	storedRecipient := stored.Recipient
+	if recipient, err := bs.normalizeRecipient(stored.ToChain, []byte(stored.Recipient)); err == nil {
+		storedRecipient = recipient
+	}
+	compare("recipient", storedRecipient, expected.Recipient)
+	compare("toChain", stored.ToChain, expected.ToChain)
+	compare("nonce", stored.Nonce, expected.Nonce)
+	compare("blockNumber", strconv.FormatUint(stored.BlockNumber, 10), strconv.FormatUint(expected.BlockNumber, 10))
+
+	if len(diff) > 0 {
+		return mismatch("stored fields differ from chain", diff), nil
+	}
+	return nil, nil
+}
+
+// logIndexOf recovers the log index from an ID built by lockEventID.
+func logIndexOf(id string) (uint, error) {
+	i := strings.LastIndex(id, "-")
+	if i < 0 {
+		return 0, fmt.Errorf("event id %q has no log index", id)
+	}
+	index, err := strconv.ParseUint(id[i+1:], 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("event id %q has no log index", id)
+	}
+	return uint(index), nil
+}
+
+func (bs *BridgeService) handleIntegrity(w http.ResponseWriter, r *http.Request) {
+	is := bs.integrity
+	is.mu.Lock()
+	report := map[string]interface{}{
+		"checked":      is.checked,
+		"mismatches":   is.mismatches,
+		"mismatchRate": is.rateLocked(),
+		"alerting":     is.alerting,
+		"findings":     append([]IntegrityFinding(nil), is.findings...),
+	}
+	is.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
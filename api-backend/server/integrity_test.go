@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+)
+
+// settledLocks runs n locks through to completion and returns their IDs.
+func (tb *testBridge) settledLocks(n int) []string {
+	tb.t.Helper()
+	ids := make([]string, n)
+	for i := range ids {
+		vLog := tb.emit(testSourceChain, tb.lockLog(uint64(5+i), byte(i+1), int64(1000*(i+1))))
+		ids[i] = lockEventID(testSourceChain, vLog)
+	}
+	tb.drain()
+	tb.confirm()
+	for _, id := range ids {
+		if status := tb.status(id); status != StatusCompleted {
+			tb.t.Fatalf("%s is %s, want completed", id, status)
+		}
+	}
+	return ids
+}
+
+// corrupt rewrites the stored copy of a transfer behind the service's back.
+func (tb *testBridge) corrupt(id string, edit func(*BridgeEvent)) {
+	tb.t.Helper()
+	event, err := tb.store.GetByID(id)
+	if err != nil {
+		tb.t.Fatal(err)
+	}
+	edit(event)
+	payload, err := json.Marshal(event)
+	if err != nil {
+		tb.t.Fatal(err)
+	}
+	if _, err := tb.db.db.Exec(`UPDATE bridge_events SET payload = ? WHERE id = ?`, string(payload), id); err != nil {
+		tb.t.Fatal(err)
+	}
+}
+
+func TestIntegritySamplerPassesIntactRecords(t *testing.T) {
+	tb := newTestBridge(t)
+	ids := tb.settledLocks(4)
+	tb.integrity.cfg.SampleSize = len(ids)
+
+	tb.sampleIntegrity(context.Background())
+
+	if tb.integrity.checked != uint64(len(ids)) || tb.integrity.mismatches != 0 {
+		t.Errorf("checked %d with %d mismatches (%+v), want %d clean", tb.integrity.checked, tb.integrity.mismatches, tb.integrity.findings, len(ids))
+	}
+}
+
+func TestIntegritySamplerFlagsCorruptedRecord(t *testing.T) {
+	tb := newTestBridge(t)
+	ids := tb.settledLocks(6)
+	corrupted := ids[3]
+	tb.corrupt(corrupted, func(event *BridgeEvent) { event.Amount = "999999" })
+	tb.integrity.cfg.SampleSize = 2
+
+	// Samples are random; the odds of missing one record in six for 50
+	// rounds of two are about 1 in 10^9.
+	for round := 0; round < 50 && tb.integrity.mismatches == 0; round++ {
+		tb.sampleIntegrity(context.Background())
+	}
+
+	if tb.integrity.mismatches == 0 {
+		t.Fatalf("corrupted record was not flagged in %d checks", tb.integrity.checked)
+	}
+	for _, finding := range tb.integrity.findings {
+		if finding.EventID != corrupted {
+			t.Errorf("flagged intact record %s: %+v", finding.EventID, finding)
+		}
+	}
+	finding := tb.integrity.findings[0]
+	if finding.Severity != "critical" || len(finding.Diff) != 1 || finding.Diff["amount"] != (FieldDiff{Stored: "999999", Chain: "4000"}) {
+		t.Errorf("finding = %+v, want only the amount to differ", finding)
+	}
+
+	rec := httptest.NewRecorder()
+	tb.handleIntegrity(rec, httptest.NewRequest("GET", "/admin/integrity", nil))
+	var report struct {
+		Mismatches uint64             `json:"mismatches"`
+		Findings   []IntegrityFinding `json:"findings"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Mismatches != tb.integrity.mismatches || len(report.Findings) == 0 || report.Findings[0].EventID != corrupted {
+		t.Errorf("report = %+v", report)
+	}
+}
+
+func TestIntegrityCheckFlagsRewrittenFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		edit   func(*BridgeEvent)
+		field  string
+		reason string
+	}{
+		{"recipient", func(e *BridgeEvent) { e.Recipient = testSender.Hex() }, "recipient", ""},
+		{"destination", func(e *BridgeEvent) { e.ToChain = "bsc" }, "toChain", ""},
+		{"nonce", func(e *BridgeEvent) { e.Nonce = "0x02" }, "nonce", ""},
+		{"token", func(e *BridgeEvent) { e.Token = testWrapped.Hex() }, "token", ""},
+		{"transaction", func(e *BridgeEvent) { e.TxHash = "0xabcdef00" }, "", "source transaction not found on chain"},
+		{"log index", func(e *BridgeEvent) { e.ID = e.ID[:len(e.ID)-1] + "7" }, "", "source log 7 not in receipt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tb := newTestBridge(t)
+			id := tb.settledLocks(1)[0]
+			event, err := tb.store.GetByID(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.edit(event)
+
+			finding, err := tb.checkIntegrity(context.Background(), *event)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if finding == nil {
+				t.Fatal("rewritten record passed")
+			}
+			if tt.field != "" {
+				if _, ok := finding.Diff[tt.field]; !ok || len(finding.Diff) != 1 {
+					t.Errorf("diff = %+v, want only %s", finding.Diff, tt.field)
+				}
+			} else if finding.Reason != tt.reason {
+				t.Errorf("reason = %q, want %q", finding.Reason, tt.reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,432 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gorilla/mux"
+)
+
+// limitWindow is the rolling period a DailyCap applies to.
+const limitWindow = 24 * time.Hour
+
+const (
+	limitRuleMin      = "min"
+	limitRuleMax      = "max"
+	limitRuleDailyCap = "daily_cap"
+)
+
+// TransferLimit bounds transfers of Token from FromChain to ToChain. Amounts
+// are decimal strings in whole tokens, so a limit reads the same whatever the
+// token's decimals; an empty field sets no bound. DailyCap caps the volume
+// admitted over any 24 hours.
+type TransferLimit struct {
+	FromChain string `json:"fromChain" yaml:"fromChain"`
+	Token     string `json:"token" yaml:"token"`
+	ToChain   string `json:"toChain" yaml:"toChain"`
+	Min       string `json:"min,omitempty" yaml:"min"`
+	Max       string `json:"max,omitempty" yaml:"max"`
+	DailyCap  string `json:"dailyCap,omitempty" yaml:"dailyCap"`
+}
+
+type limitBounds struct {
+	min, max, dailyCap *big.Rat
+}
+
+// validate checks l against the configured chains and checksums its token in
+// place.
+func (l *TransferLimit) validate(chains map[string]bool) error {
+	if !chains[l.FromChain] {
+		return fmt.Errorf("unknown source chain %q", l.FromChain)
+	}
+	if !chains[l.ToChain] {
+		return fmt.Errorf("unknown target chain %q", l.ToChain)
+	}
+	if !common.IsHexAddress(l.Token) {
+		return fmt.Errorf("token %q is not a valid address", l.Token)
+	}
+	l.Token = common.HexToAddress(l.Token).Hex()
+	if l.Min == "" && l.Max == "" && l.DailyCap == "" {
+		return errors.New("set at least one of min, max and dailyCap")
+	}
+	_, err := l.bounds()
+	return err
+}
+
+func (l TransferLimit) bounds() (limitBounds, error) {
+	var b limitBounds
+	for _, field := range []struct {
+		name  string
+		value string
+		dst   **big.Rat
+	}{{"min", l.Min, &b.min}, {"max", l.Max, &b.max}, {"dailyCap", l.DailyCap, &b.dailyCap}} {
+		if field.value == "" {
+			continue
+		}
+		r, err := ParseWhole(field.value)
+		if err != nil {
+			return limitBounds{}, fmt.Errorf("%s: %v", field.name, err)
+		}
+		*field.dst = r
+	}
+	if b.min != nil && b.max != nil && b.min.Cmp(b.max) > 0 {
+		return limitBounds{}, fmt.Errorf("min %s is above max %s", l.Min, l.Max)
+	}
+	return b, nil
+}
+
+// LimitUsage is one transfer counted toward its token's daily volume.
+// Amount is in whole tokens.
+type LimitUsage struct {
+	EventID   string
+	FromChain string
+	Token     string
+	ToChain   string
+	Amount    *big.Rat
+	At        time.Time
+}
+
+func (u LimitUsage) key() tokenKey {
+	return tokenKey{u.FromChain, u.Token, u.ToChain}
+}
+
+// LimitViolation names the rule a transfer broke.
+type LimitViolation struct {
+	Rule   string
+	Detail string
+}
+
+func (v *LimitViolation) Error() string {
+	return v.Rule + " limit: " + v.Detail
+}
+
+// LimitStatus is a limit together with the volume counted against it over
+// the current window.
+type LimitStatus struct {
+	TransferLimit
+	Used string `json:"used"`
+}
+
+// Limits enforces transfer limits. Checking a transfer and counting it happen
+// under one lock, so two settlements can't both squeeze under a cap.
+type Limits struct {
+	mu      sync.Mutex
+	limits  map[tokenKey]TransferLimit
+	bounds  map[tokenKey]limitBounds
+	usage   map[tokenKey][]LimitUsage
+	counted map[string]bool
+}
+
+func NewLimits() *Limits {
+	return &Limits{
+		limits:  make(map[tokenKey]TransferLimit),
+		bounds:  make(map[tokenKey]limitBounds),
+		usage:   make(map[tokenKey][]LimitUsage),
+		counted: make(map[string]bool),
+	}
+}
+
+// Put adds or replaces a validated limit.
+func (l *Limits) Put(limit TransferLimit) {
+	b, _ := limit.bounds()
+	key := tokenKey{limit.FromChain, limit.Token, limit.ToChain}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limits[key] = limit
+	l.bounds[key] = b
+}
+
+func (l *Limits) Remove(fromChain, token, toChain string) bool {
+	key := tokenKey{fromChain, token, toChain}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, ok := l.limits[key]
+	delete(l.limits, key)
+	delete(l.bounds, key)
+	return ok
+}
+
+// Admit decides whether a transfer may settle and, if so, counts it. A
+// transfer that was already counted, for instance one an admin released, is
+// admitted without being counted twice. counted reports whether usage is new
+// and needs persisting.
+func (l *Limits) Admit(usage LimitUsage) (counted bool, violation *LimitViolation) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.counted[usage.EventID] {
+		return false, nil
+	}
+
+	key := usage.key()
+	if b, ok := l.bounds[key]; ok {
+		if b.min != nil && usage.Amount.Cmp(b.min) < 0 {
+			return false, &LimitViolation{limitRuleMin, fmt.Sprintf("amount %s is below the minimum of %s", FormatWhole(usage.Amount), FormatWhole(b.min))}
+		}
+		if b.max != nil && usage.Amount.Cmp(b.max) > 0 {
+			return false, &LimitViolation{limitRuleMax, fmt.Sprintf("amount %s is above the maximum of %s", FormatWhole(usage.Amount), FormatWhole(b.max))}
+		}
+		if b.dailyCap != nil {
+			used := l.usedLocked(key, usage.At)
+			if total := new(big.Rat).Add(used, usage.Amount); total.Cmp(b.dailyCap) > 0 {
+				return false, &LimitViolation{limitRuleDailyCap, fmt.Sprintf("amount %s on top of %s in the last 24h exceeds the cap of %s",
+					FormatWhole(usage.Amount), FormatWhole(used), FormatWhole(b.dailyCap))}
+			}
+		}
+	}
+	l.addLocked(usage)
+	return true, nil
+}
+
+// Count records usage without checking it, for transfers an admin releases
+// past their limit. It reports whether usage is new.
+func (l *Limits) Count(usage LimitUsage) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.counted[usage.EventID] {
+		return false
+	}
+	l.addLocked(usage)
+	return true
+}
+
+func (l *Limits) addLocked(usage LimitUsage) {
+	key := usage.key()
+	l.counted[usage.EventID] = true
+	l.usage[key] = append(l.usage[key], usage)
+}
+
+// usedLocked sums the usage of key inside the window ending at now, dropping
+// entries that have aged out.
+func (l *Limits) usedLocked(key tokenKey, now time.Time) *big.Rat {
+	cutoff := now.Add(-limitWindow)
+	used := new(big.Rat)
+	kept := l.usage[key][:0]
+	for _, usage := range l.usage[key] {
+		if !usage.At.After(cutoff) {
+			delete(l.counted, usage.EventID)
+			continue
+		}
+		used.Add(used, usage.Amount)
+		kept = append(kept, usage)
+	}
+	l.usage[key] = kept
+	return used
+}
+
+func (l *Limits) List(now time.Time) []LimitStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	statuses := make([]LimitStatus, 0, len(l.limits))
+	for key, limit := range l.limits {
+		statuses = append(statuses, LimitStatus{TransferLimit: limit, Used: FormatWhole(l.usedLocked(key, now))})
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		a, b := statuses[i], statuses[j]
+		if a.FromChain != b.FromChain {
+			return a.FromChain < b.FromChain
+		}
+		if a.Token != b.Token {
+			return a.Token < b.Token
+		}
+		return a.ToChain < b.ToChain
+	})
+	return statuses
+}
+
+// loadLimits writes the configured limits to the store and loads them, and
+// the usage of the current window, from it, so neither limits set through the
+// admin API nor the window reset on restart.
+func (bs *BridgeService) loadLimits(configured []TransferLimit) error {
+	for _, limit := range configured {
+		if err := bs.store.SaveTransferLimit(limit); err != nil {
+			return err
+		}
+	}
+
+	stored, err := bs.store.ListTransferLimits()
+	if err != nil {
+		return err
+	}
+	chains := bs.chainSet()
+	for _, limit := range stored {
+		if err := limit.validate(chains); err != nil {
+			log.Printf("Ignoring stored limit on %s/%s -> %s: %v", limit.FromChain, limit.Token, limit.ToChain, err)
+			continue
+		}
+		bs.limits.Put(limit)
+	}
+
+	usage, err := bs.store.LimitUsageSince(bs.clock.Now().Add(-limitWindow))
+	if err != nil {
+		return err
+	}
+	for _, u := range usage {
+		bs.limits.Count(u)
+	}
+	log.Printf("Loaded %d transfer limits and %d transfers in the current window", len(stored), len(usage))
+	return nil
+}
+
+// limitUsage expresses event as usage against its limit. It is false for
+// transfers without a token mapping or a valid amount, which settlement
+// rejects anyway.
+func (bs *BridgeService) limitUsage(event BridgeEvent) (LimitUsage, bool) {
+	mapping, _, ok := bs.tokens.Destination(event)
+	if !ok {
+		return LimitUsage{}, false
+	}
+	decimals, _ := mapping.amountDecimals(event.Type)
+	value, ok := new(big.Int).SetString(event.Amount, 10)
+	if !ok {
+		return LimitUsage{}, false
+	}
+	amount, err := NewAmount(value, decimals)
+	if err != nil {
+		return LimitUsage{}, false
+	}
+	return LimitUsage{
+		EventID:   event.ID,
+		FromChain: event.FromChain,
+		Token:     common.HexToAddress(event.Token).Hex(),
+		ToChain:   event.ToChain,
+		Amount:    amount.Whole(),
+		At:        bs.clock.Now(),
+	}, true
+}
+
+// withinLimits checks a confirmed transfer against its limit and counts it.
+// One over its limit is held with status "limit_exceeded" until an admin
+// releases it.
+func (bs *BridgeService) withinLimits(event BridgeEvent) bool {
+	usage, ok := bs.limitUsage(event)
+	if !ok {
+		return true
+	}
+	counted, violation := bs.limits.Admit(usage)
+	if violation != nil {
+		bs.holdOverLimit(event, violation)
+		return false
+	}
+	if counted {
+		bs.saveLimitUsage(usage)
+	}
+	return true
+}
+
+func (bs *BridgeService) saveLimitUsage(usage LimitUsage) {
+	if err := bs.store.SaveLimitUsage(usage); err != nil {
+		log.Printf("Failed to persist limit usage of %s: %v", usage.EventID, err)
+	}
+	if err := bs.store.PruneLimitUsage(bs.clock.Now().Add(-limitWindow)); err != nil {
+		log.Printf("Failed to prune limit usage: %v", err)
+	}
+}
+
+func (bs *BridgeService) holdOverLimit(event BridgeEvent, violation *LimitViolation) {
+	if !bs.TransitionStatus(&event, StatusLimitExceeded) {
+		return
+	}
+	event.LimitRule = violation.Rule
+	event.Error = violation.Error()
+	if err := bs.store.SaveEvent(event); err != nil {
+		log.Printf("Failed to persist %s event %s: %v", event.Type, event.ID, err)
+	}
+	limitViolations.WithLabelValues(event.FromChain, event.ToChain, violation.Rule).Inc()
+	log.Printf("Holding %s %s for review: %v", event.Type, event.ID, violation)
+
+	bs.updateTransactionStatus(event)
+	bs.broadcastEvent(event)
+}
+
+func (bs *BridgeService) handleListLimits(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"limits": bs.limits.List(bs.clock.Now())})
+}
+
+func (bs *BridgeService) handlePutLimit(w http.ResponseWriter, r *http.Request) {
+	var limit TransferLimit
+	if err := json.NewDecoder(r.Body).Decode(&limit); err != nil {
+		writeError(w, http.StatusBadRequest, "malformed limit")
+		return
+	}
+	if err := limit.validate(bs.chainSet()); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := bs.store.SaveTransferLimit(limit); err != nil {
+		log.Printf("Failed to save transfer limit: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to save limit")
+		return
+	}
+	bs.limits.Put(limit)
+	log.Printf("AUDIT: %s set limit on %s %s -> %s: min %q, max %q, daily cap %q",
+		requestActor(r), limit.FromChain, limit.Token, limit.ToChain, limit.Min, limit.Max, limit.DailyCap)
+	writeJSON(w, http.StatusOK, limit)
+}
+
+func (bs *BridgeService) handleDeleteLimit(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if !common.IsHexAddress(vars["token"]) {
+		writeError(w, http.StatusBadRequest, "malformed token")
+		return
+	}
+	token := common.HexToAddress(vars["token"]).Hex()
+
+	removed, err := bs.store.DeleteTransferLimit(vars["fromChain"], token, vars["toChain"])
+	if err != nil {
+		log.Printf("Failed to delete transfer limit: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to delete limit")
+		return
+	}
+	if !bs.limits.Remove(vars["fromChain"], token, vars["toChain"]) && !removed {
+		writeError(w, http.StatusNotFound, "limit not found")
+		return
+	}
+	log.Printf("AUDIT: %s removed limit on %s %s -> %s", requestActor(r), vars["fromChain"], token, vars["toChain"])
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleReleaseTransfer settles a transfer held over its limit. The transfer
+// still counts toward the daily volume.
+func (bs *BridgeService) handleReleaseTransfer(w http.ResponseWriter, r *http.Request) {
+	event, err := bs.store.GetByID(mux.Vars(r)["id"])
+	if errors.Is(err, ErrEventNotFound) {
+		writeError(w, http.StatusNotFound, "transaction not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to load transaction: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to load transaction")
+		return
+	}
+	if event.Status != StatusLimitExceeded {
+		writeError(w, http.StatusConflict, "transaction is not held by a limit")
+		return
+	}
+
+	if usage, ok := bs.limitUsage(*event); ok && bs.limits.Count(usage) {
+		bs.saveLimitUsage(usage)
+	}
+	log.Printf("AUDIT: %s released %s %s past its %s limit", requestActor(r), event.Type, event.ID, event.LimitRule)
+	event.Error = ""
+	if err := bs.store.SaveEvent(*event); err != nil {
+		log.Printf("Failed to persist %s event %s: %v", event.Type, event.ID, err)
+	}
+	go bs.promoteConfirmed(*event)
+	writeJSON(w, http.StatusAccepted, event)
+}
+
+func (bs *BridgeService) registerLimitRoutes(router *mux.Router) {
+	admin := bs.auth.Require(roleAdmin)
+	router.Handle("/admin/limits", admin(http.HandlerFunc(bs.handleListLimits))).Methods(http.MethodGet)
+	router.Handle("/admin/limits", admin(http.HandlerFunc(bs.handlePutLimit))).Methods(http.MethodPost)
+	router.Handle("/admin/limits/{fromChain}/{token}/{toChain}", admin(http.HandlerFunc(bs.handleDeleteLimit))).Methods(http.MethodDelete)
+	router.Handle("/admin/transactions/{id}/release", admin(http.HandlerFunc(bs.handleReleaseTransfer))).Methods(http.MethodPost)
+}
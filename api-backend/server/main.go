@@ -0,0 +1,15 @@
+package main
+
+import (
+	"flag"
+	"os"
+)
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "backfill" {
+		runBackfillCommand(os.Args[2:])
+		return
+	}
+	flag.Parse()
+	runBridgeService()
+}
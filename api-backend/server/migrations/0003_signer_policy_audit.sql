@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS signer_policy_audit (
+    fingerprint TEXT NOT NULL,
+    policy      TEXT NOT NULL,
+    loaded_at   BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_signer_policy_audit_loaded ON signer_policy_audit (loaded_at);
@@ -0,0 +1,5 @@
+CREATE INDEX IF NOT EXISTS idx_bridge_events_sender ON bridge_events (sender);
+
+CREATE INDEX IF NOT EXISTS idx_bridge_events_tx_hash ON bridge_events (tx_hash);
+
+CREATE INDEX IF NOT EXISTS idx_bridge_events_created ON bridge_events (created_at, id);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS corridor_sequences (
+    corridor TEXT PRIMARY KEY,
+    last_seq BIGINT NOT NULL
+);
+
+ALTER TABLE bridge_events ADD COLUMN corridor TEXT;
+
+ALTER TABLE bridge_events ADD COLUMN corridor_seq BIGINT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_bridge_events_corridor_seq ON bridge_events (corridor, corridor_seq);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS token_mappings (
+    source_chain TEXT NOT NULL,
+    source_token TEXT NOT NULL,
+    target_chain TEXT NOT NULL,
+    target_token TEXT NOT NULL,
+    decimals     INTEGER NOT NULL,
+    symbol       TEXT NOT NULL,
+    updated_at   BIGINT NOT NULL,
+    PRIMARY KEY (source_chain, source_token, target_chain)
+);
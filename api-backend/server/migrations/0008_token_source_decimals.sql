@@ -0,0 +1,3 @@
+ALTER TABLE token_mappings ADD COLUMN source_decimals INTEGER NOT NULL DEFAULT 0;
+
+UPDATE token_mappings SET source_decimals = decimals;
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS pauses (
+    scope     TEXT PRIMARY KEY,
+    reason    TEXT NOT NULL,
+    actor     TEXT NOT NULL,
+    paused_at BIGINT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS pause_audit (
+    scope       TEXT NOT NULL,
+    action      TEXT NOT NULL,
+    actor       TEXT NOT NULL,
+    reason      TEXT NOT NULL,
+    recorded_at BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_pause_audit_recorded ON pause_audit (recorded_at);
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS transfer_limits (
+    from_chain TEXT NOT NULL,
+    token      TEXT NOT NULL,
+    to_chain   TEXT NOT NULL,
+    min_amount TEXT NOT NULL,
+    max_amount TEXT NOT NULL,
+    daily_cap  TEXT NOT NULL,
+    updated_at BIGINT NOT NULL,
+    PRIMARY KEY (from_chain, token, to_chain)
+);
+
+CREATE TABLE IF NOT EXISTS limit_usage (
+    event_id    TEXT PRIMARY KEY,
+    from_chain  TEXT NOT NULL,
+    token       TEXT NOT NULL,
+    to_chain    TEXT NOT NULL,
+    amount      TEXT NOT NULL,
+    recorded_at BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_limit_usage_recorded ON limit_usage (recorded_at);
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS webhooks (
+    name                 TEXT PRIMARY KEY,
+    url                  TEXT NOT NULL,
+    secret               TEXT NOT NULL,
+    events               TEXT NOT NULL,
+    disabled             INTEGER NOT NULL DEFAULT 0,
+    consecutive_failures INTEGER NOT NULL DEFAULT 0,
+    updated_at           BIGINT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id            TEXT PRIMARY KEY,
+    webhook       TEXT NOT NULL,
+    event_id      TEXT NOT NULL,
+    event_type    TEXT NOT NULL,
+    payload       TEXT NOT NULL,
+    status        TEXT NOT NULL,
+    attempts      INTEGER NOT NULL,
+    response_code INTEGER NOT NULL,
+    last_error    TEXT NOT NULL,
+    next_attempt  BIGINT NOT NULL,
+    created_at    BIGINT NOT NULL,
+    updated_at    BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries (status, next_attempt);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON webhook_deliveries (webhook, created_at);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS status_updates (
+    callback     TEXT NOT NULL,
+    sequence     BIGINT NOT NULL,
+    event_id     TEXT NOT NULL,
+    payload      TEXT NOT NULL,
+    attempts     INTEGER NOT NULL,
+    next_attempt BIGINT NOT NULL,
+    created_at   BIGINT NOT NULL,
+    PRIMARY KEY (callback, sequence)
+);
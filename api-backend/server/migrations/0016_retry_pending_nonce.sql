@@ -0,0 +1 @@
+ALTER TABLE settlement_retries ADD COLUMN pending_nonce BIGINT;
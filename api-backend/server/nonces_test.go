@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// fakePending stands in for the node's pending nonce.
+type fakePending struct {
+	mu    sync.Mutex
+	nonce uint64
+	reads int
+}
+
+func (p *fakePending) set(nonce uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.nonce = nonce
+}
+
+func (p *fakePending) read(ctx context.Context) (uint64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.reads++
+	return p.nonce, nil
+}
+
+func allocate(t *testing.T, m *NonceManager, want uint64) {
+	t.Helper()
+	nonce, err := m.Allocate(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nonce != want {
+		t.Errorf("allocated %d, want %d", nonce, want)
+	}
+}
+
+func TestNonceManagerConcurrentAllocate(t *testing.T) {
+	const workers, perWorker = 8, 50
+	pending := &fakePending{nonce: 7}
+	m := NewNonceManager(testTargetChain, pending.read)
+
+	nonces := make(chan uint64, workers*perWorker)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				nonce, err := m.Allocate(context.Background())
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				nonces <- nonce
+			}
+		}()
+	}
+	wg.Wait()
+	close(nonces)
+
+	var got []uint64
+	for nonce := range nonces {
+		got = append(got, nonce)
+	}
+	sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+	for i, nonce := range got {
+		if nonce != 7+uint64(i) {
+			t.Fatalf("allocated %v, want each of 7..%d once", got, 7+workers*perWorker-1)
+		}
+	}
+	if pending.reads != 1 {
+		t.Errorf("read the node's nonce %d times, want once on first use", pending.reads)
+	}
+}
+
+// A nonce given back is handed out again before any new one, and giving back
+// the last one handed out just steps back.
+func TestNonceManagerReleaseReusesGap(t *testing.T) {
+	m := NewNonceManager(testTargetChain, (&fakePending{nonce: 10}).read)
+	for want := uint64(10); want < 14; want++ {
+		allocate(t, m, want)
+	}
+
+	m.Release(11)
+	m.Release(12)
+	allocate(t, m, 11)
+	allocate(t, m, 12)
+	allocate(t, m, 14)
+
+	m.Release(14)
+	allocate(t, m, 14)
+
+	// Releasing a nonce that was never handed out, or twice, changes nothing.
+	m.Release(20)
+	m.Release(12)
+	m.Release(12)
+	allocate(t, m, 12)
+	allocate(t, m, 15)
+}
+
+// When the node's nonce has moved past the manager's, e.g. because another
+// sender used the account, Resync jumps ahead, forgets what was mined and
+// frees nonces no tracked transaction holds.
+func TestNonceManagerResyncWhenNodeMovesAhead(t *testing.T) {
+	pending := &fakePending{nonce: 5}
+	m := NewNonceManager(testTargetChain, pending.read)
+	for want := uint64(5); want < 8; want++ {
+		allocate(t, m, want)
+	}
+
+	pending.set(12)
+	if err := m.Resync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	allocate(t, m, 12)
+	allocate(t, m, 13)
+
+	// 13 was sent but 12 never reached the pool: 12 is a gap to fill again.
+	pending.set(12)
+	m.Done(12)
+	if err := m.Resync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	allocate(t, m, 12)
+	allocate(t, m, 14)
+}
+
+// A held nonce is never handed out, and holding one past the next nonce
+// leaves the ones in between free.
+func TestNonceManagerHold(t *testing.T) {
+	pending := &fakePending{nonce: 3}
+	m := NewNonceManager(testTargetChain, pending.read)
+	m.Hold(4)
+	allocate(t, m, 3)
+	allocate(t, m, 5)
+
+	m.Hold(8)
+	allocate(t, m, 6)
+	allocate(t, m, 7)
+	allocate(t, m, 9)
+
+	m.Done(4)
+	pending.set(4)
+	if err := m.Resync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	allocate(t, m, 4)
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+var ErrPolicyViolation = errors.New("signer policy violation")
+
+var defaultAllowedMethods = []string{"mint", "release", "unlock", "setProcessedBitmap", "checkpoint"}
+
+const (
+	defaultMaxGasLimit     = 500000
+	defaultMaxGasPriceGwei = 500
+)
+
+type SignerPolicyConfig struct {
+	AllowedMethods  []string `json:"allowedMethods" yaml:"allowedMethods"`
+	MaxGasLimit     uint64   `json:"maxGasLimit" yaml:"maxGasLimit"`
+	MaxGasPriceGwei uint64   `json:"maxGasPriceGwei" yaml:"maxGasPriceGwei"`
+}
+
+func (c *SignerPolicyConfig) applyDefaults() {
+	if len(c.AllowedMethods) == 0 {
+		c.AllowedMethods = defaultAllowedMethods
+	}
+	if c.MaxGasLimit == 0 {
+		c.MaxGasLimit = defaultMaxGasLimit
+	}
+	if c.MaxGasPriceGwei == 0 {
+		c.MaxGasPriceGwei = defaultMaxGasPriceGwei
+	}
+}
+
+type chainPolicy struct {
+	name      string
+	contract  common.Address
+	selectors map[[4]byte]string
+}
+
+// SignerPolicy is checked before every signature, independently of the mint
+// pipeline, so a bug or compromise upstream can't get the relayer key to sign
+// anything but an expected bridge call.
+type SignerPolicy struct {
+	chains      map[uint64]chainPolicy
+	maxGasLimit uint64
+	maxGasPrice *big.Int
+	refusals    atomic.Uint64
+}
+
+// NewSignerPolicy allows calls to each chain's bridge contract whose selector
+// matches one of the configured method names in that chain's ABI version.
+// Methods a version doesn't define are simply not callable there.
+func NewSignerPolicy(cfg SignerPolicyConfig, chains map[string]ChainConfig, registry *EventRegistry) (*SignerPolicy, error) {
+	policy := &SignerPolicy{
+		chains:      make(map[uint64]chainPolicy),
+		maxGasLimit: cfg.MaxGasLimit,
+		maxGasPrice: new(big.Int).Mul(new(big.Int).SetUint64(cfg.MaxGasPriceGwei), big.NewInt(params.GWei)),
+	}
+
+	for name, chain := range chains {
+		contractABI, err := registry.ABI(chain.ContractVersion)
+		if err != nil {
+			return nil, err
+		}
+
+		selectors := make(map[[4]byte]string)
+		for _, methodName := range cfg.AllowedMethods {
+			method, ok := contractABI.Methods[methodName]
+			if !ok {
+				continue
+			}
+			var selector [4]byte
+			copy(selector[:], method.ID)
+			selectors[selector] = methodName
+		}
+
+		policy.chains[chain.ChainID] = chainPolicy{
+			name:      name,
+			contract:  common.HexToAddress(chain.Contract),
+			selectors: selectors,
+		}
+	}
+
+	log.Printf("Signer policy loaded (fingerprint %s): %s", policy.Fingerprint(), policy)
+	return policy, nil
+}
+
+func (p *SignerPolicy) Check(tx *types.Transaction, chainID *big.Int) error {
+	if err := p.check(tx, chainID); err != nil {
+		p.refusals.Add(1)
+		log.Printf("ALERT: signer refused transaction on chain %s: %v (to=%v value=%s gas=%d gasPrice=%s calldata=0x%x)",
+			chainID, err, tx.To(), tx.Value(), tx.Gas(), tx.GasFeeCap(), tx.Data())
+		return err
+	}
+	return nil
+}
+
+func (p *SignerPolicy) check(tx *types.Transaction, chainID *big.Int) error {
+	if !chainID.IsUint64() {
+		return fmt.Errorf("%w: unknown chain id %s", ErrPolicyViolation, chainID)
+	}
+	chain, ok := p.chains[chainID.Uint64()]
+	if !ok {
+		return fmt.Errorf("%w: chain id %s has no registered bridge contract", ErrPolicyViolation, chainID)
+	}
+
+	if tx.To() == nil {
+		return fmt.Errorf("%w: contract creation is not allowed", ErrPolicyViolation)
+	}
+	if *tx.To() != chain.contract {
+		return fmt.Errorf("%w: %s is not the %s bridge contract", ErrPolicyViolation, tx.To().Hex(), chain.name)
+	}
+
+	if len(tx.Data()) < 4 {
+		return fmt.Errorf("%w: calldata has no method selector", ErrPolicyViolation)
+	}
+	var selector [4]byte
+	copy(selector[:], tx.Data()[:4])
+	if _, ok := chain.selectors[selector]; !ok {
+		return fmt.Errorf("%w: selector 0x%x is not allowed on %s", ErrPolicyViolation, selector, chain.name)
+	}
+
+	if tx.Value().Sign() != 0 {
+		return fmt.Errorf("%w: value must be zero, got %s", ErrPolicyViolation, tx.Value())
+	}
+	if tx.Gas() > p.maxGasLimit {
+		return fmt.Errorf("%w: gas limit %d exceeds cap %d", ErrPolicyViolation, tx.Gas(), p.maxGasLimit)
+	}
+	if tx.GasFeeCap().Cmp(p.maxGasPrice) > 0 {
+		return fmt.Errorf("%w: gas price %s exceeds cap %s", ErrPolicyViolation, tx.GasFeeCap(), p.maxGasPrice)
+	}
+	return nil
+}
+
+func (p *SignerPolicy) Refusals() uint64 {
+	return p.refusals.Load()
+}
+
+// String lists the effective policy in a stable order so the startup log line
+// and its fingerprint only change when the policy does.
+func (p *SignerPolicy) String() string {
+	chainIDs := make([]uint64, 0, len(p.chains))
+	for chainID := range p.chains {
+		chainIDs = append(chainIDs, chainID)
+	}
+	sort.Slice(chainIDs, func(i, j int) bool { return chainIDs[i] < chainIDs[j] })
+
+	parts := make([]string, 0, len(chainIDs)+2)
+	for _, chainID := range chainIDs {
+		chain := p.chains[chainID]
+		methods := make([]string, 0, len(chain.selectors))
+		for _, name := range chain.selectors {
+			methods = append(methods, name)
+		}
+		sort.Strings(methods)
+		parts = append(parts, fmt.Sprintf("%s(%d)=%s[%s]", chain.name, chainID, chain.contract.Hex(), strings.Join(methods, ",")))
+	}
+	parts = append(parts, fmt.Sprintf("maxGas=%d", p.maxGasLimit), fmt.Sprintf("maxGasPrice=%s", p.maxGasPrice))
+	return strings.Join(parts, " ")
+}
+
+func (p *SignerPolicy) Fingerprint() string {
+	sum := sha256.Sum256([]byte(p.String()))
+	return fmt.Sprintf("%x", sum[:6])
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	retryPollInterval   = 15 * time.Second
+	retryReceiptTimeout = 10 * time.Second
+)
+
+// RetryConfig controls how failed mints and unlocks are sent again. Attempt
+// n+1 follows attempt n after InitialBackoffSeconds * 2^(n-1), capped at
+// MaxBackoffSeconds. MaxAttempts counts the first attempt; a transfer that
+// exhausts it is left with status "failed".
+type RetryConfig struct {
+	MaxAttempts           int `json:"maxAttempts" yaml:"maxAttempts"`
+	InitialBackoffSeconds int `json:"initialBackoffSeconds" yaml:"initialBackoffSeconds"`
+	MaxBackoffSeconds     int `json:"maxBackoffSeconds" yaml:"maxBackoffSeconds"`
+}
+
+func (c *RetryConfig) applyDefaults() {
+	if c.MaxAttempts == 0 {
+		c.MaxAttempts = 8
+	}
+	if c.InitialBackoffSeconds == 0 {
+		c.InitialBackoffSeconds = 30
+	}
+	if c.MaxBackoffSeconds == 0 {
+		c.MaxBackoffSeconds = 3600
+	}
+}
+
+func (c RetryConfig) validate(section string) error {
+	if c.MaxAttempts < 1 || c.InitialBackoffSeconds < 1 || c.MaxBackoffSeconds < c.InitialBackoffSeconds {
+		return fmt.Errorf("%s: maxAttempts and initialBackoffSeconds must be positive and maxBackoffSeconds at least initialBackoffSeconds", section)
+	}
+	return nil
+}
+
+func (c RetryConfig) backoff(attempts int) time.Duration {
+	delay := time.Duration(c.InitialBackoffSeconds) * time.Second
+	limit := time.Duration(c.MaxBackoffSeconds) * time.Second
+	for i := 1; i < attempts && delay < limit; i++ {
+		delay *= 2
+	}
+	if delay > limit {
+		delay = limit
+	}
+	return delay
+}
+
+// SettlementRetry is a mint or unlock waiting to be sent again. TxHashes are
+// the transactions earlier attempts signed; any of them may still have been
+// mined even though the attempt reported an error.
+//
+// PendingNonce is set when the last attempt gave up waiting on a transaction
+// that is neither mined nor dropped. Its relayer nonce stays held and the
+// next attempt is sent with the same nonce, so it can only replace that
+// transaction, never be mined alongside it. PendingGas is what that
+// transaction paid; the replacement has to outbid it.
+type SettlementRetry struct {
+	EventID      string
+	Method       string
+	Attempts     int
+	NextAttempt  time.Time
+	LastError    string
+	TxHashes     []string
+	PendingNonce *uint64
+	PendingGas   *GasParams
+}
+
+func (bs *BridgeService) RunRetries(ctx context.Context) {
+	Every(ctx, bs.clock, retryPollInterval, bs.runDueRetries)
+}
+
+func (bs *BridgeService) runDueRetries(ctx context.Context) {
+	due, err := bs.store.DueRetries(bs.clock.Now())
+	if err != nil {
+		log.Printf("Failed to load due retries: %v", err)
+		return
+	}
+	for _, retry := range due {
+		if _, busy := bs.retrying.LoadOrStore(retry.EventID, true); busy {
+			continue
+		}
+		retry := retry
+		started := bs.startSettlement(BridgeEvent{ID: retry.EventID}, func(BridgeEvent) {
+			defer bs.retrying.Delete(retry.EventID)
+			bs.retrySettlement(retry)
+		})
+		if !started {
+			bs.retrying.Delete(retry.EventID)
+			return
+		}
+	}
+}
+
+// retrySettlement sends a failed settlement again, unless the transfer has
+// moved on, its transfer was claimed by another event, or an earlier attempt
+// turns out to have landed after all.
+func (bs *BridgeService) retrySettlement(retry SettlementRetry) {
+	event, err := bs.store.GetByID(retry.EventID)
+	if err == nil && (event.Status == StatusMinting || event.Status == StatusUnlocking) {
+		// The first attempt is still out, or was interrupted and is resumed
+		// once the transfer is confirmed again; either way it keeps its record.
+		return
+	}
+	if errors.Is(err, ErrEventNotFound) || (err == nil && event.Status != StatusRetrying && event.Status != StatusFeeCapExceeded) {
+		if event != nil {
+			bs.releaseHeldNonce(event.ToChain, retry)
+		}
+		bs.dropRetry(retry.EventID)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to load %s for retry: %v", retry.EventID, err)
+		return
+	}
+	if _, blocked := bs.pauses.Blocks(*event); blocked {
+		return
+	}
+
+	claimant, found, err := bs.store.TransferClaimant(event.TransferKey)
+	if err != nil {
+		log.Printf("Failed to check transfer of %s, not retrying yet: %v", event.ID, err)
+		return
+	}
+	if found && claimant != event.ID {
+		bs.duplicates.Add(1)
+		log.Printf("Dropping retry of %s: transfer %s was processed by %s", event.ID, event.TransferKey, claimant)
+		bs.releaseHeldNonce(event.ToChain, retry)
+		bs.dropRetry(event.ID)
+		return
+	}
+	if !found {
+		if first, err := bs.store.MarkTransferProcessed(event.TransferKey, event.ID); err != nil || !first {
+			log.Printf("Failed to claim transfer for %s, not retrying yet: %v", event.ID, err)
+			return
+		}
+	}
+
+	landed, err := bs.earlierAttemptLanded(event.ToChain, retry.TxHashes)
+	if err != nil {
+		log.Printf("Failed to check earlier attempts of %s, not retrying yet: %v", event.ID, err)
+		return
+	}
+	if landed != "" {
+		log.Printf("Earlier %s of %s was mined in %s, not sending again", retry.Method, event.ID, landed)
+		bs.releaseHeldNonce(event.ToChain, retry)
+		bs.dropRetry(event.ID)
+		mintsSucceeded.WithLabelValues(event.ToChain, retry.Method).Inc()
+		bs.eventChan <- bs.settlementEvent(*event, retry.Method, landed, StatusCompleted, nil)
+		return
+	}
+
+	mapping, token, amount, ok := bs.payout(*event, retry.Method)
+	if !ok {
+		bs.releaseHeldNonce(event.ToChain, retry)
+		bs.dropRetry(event.ID)
+		return
+	}
+	log.Printf("Retrying %s of %s (attempt %d of %d)", retry.Method, event.ID, retry.Attempts+1, bs.retry.MaxAttempts)
+	bs.attemptSettlement(*event, retry.Method, mapping, token, amount, &retry)
+}
+
+// earlierAttemptLanded returns the hash of whichever earlier attempt was
+// mined successfully, or "" if none was. It errs on the side of not resending
+// when a receipt can't be fetched.
+func (bs *BridgeService) earlierAttemptLanded(chainName string, txHashes []string) (string, error) {
+	client, ok := bs.chains.GetClient(chainName)
+	if !ok {
+		return "", fmt.Errorf("no client for chain %s", chainName)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), retryReceiptTimeout)
+	defer cancel()
+
+	for _, hash := range txHashes {
+		receipt, err := client.TransactionReceipt(ctx, common.HexToHash(hash))
+		if errors.Is(err, ethereum.NotFound) {
+			continue
+		}
+		if err != nil {
+			rpcErrors.WithLabelValues(chainName, "transaction_receipt").Inc()
+			return "", err
+		}
+		if receipt.Status == types.ReceiptStatusSuccessful {
+			return hash, nil
+		}
+	}
+	return "", nil
+}
+
+// retryLater records a failed attempt and schedules the next one, or gives
+// up once the attempts are exhausted.
+func (bs *BridgeService) retryLater(event BridgeEvent, method string, retry *SettlementRetry, sent []sentSettlement, cause error) {
+	if retry == nil {
+		retry = &SettlementRetry{EventID: event.ID, Method: method}
+	}
+	retry.Attempts++
+	retry.LastError = cause.Error()
+	txHash := ""
+	var gas *GasParams
+	for _, attempt := range sent {
+		txHash = attempt.tx.Hash().Hex()
+		gas = attempt.gas
+		if !slices.Contains(retry.TxHashes, txHash) {
+			retry.TxHashes = append(retry.TxHashes, txHash)
+		}
+	}
+
+	if retry.Attempts >= bs.retry.MaxAttempts {
+		bs.releaseHeldNonce(event.ToChain, *retry)
+		bs.dropRetry(event.ID)
+		mintsDeadLettered.WithLabelValues(event.ToChain, method).Inc()
+		log.Printf("Giving up on %s of %s after %d attempts: %v", method, event.ID, retry.Attempts, cause)
+		bs.eventChan <- bs.settlementEvent(event, method, txHash, StatusFailed, cause)
+		return
+	}
+
+	delay := bs.retry.backoff(retry.Attempts)
+	retry.NextAttempt = bs.clock.Now().Add(delay)
+	if err := bs.store.SaveRetry(*retry); err != nil {
+		log.Printf("Failed to schedule retry of %s, it will not be retried: %v", event.ID, err)
+	}
+	log.Printf("Will retry %s of %s in %s (attempt %d of %d failed)", method, event.ID, delay, retry.Attempts, bs.retry.MaxAttempts)
+
+	settlement := bs.settlementEvent(event, method, txHash, StatusRetrying, cause)
+	settlement.Attempts = retry.Attempts
+	settlement.NextAttemptAt = &retry.NextAttempt
+	settlement.Gas = gas
+	bs.eventChan <- settlement
+}
+
+// parkForFees schedules a settlement that wasn't sent because fees were
+// above the destination chain's cap. Nothing was signed, so the wait doesn't
+// use up an attempt; the retry worker tries again after the initial backoff.
+func (bs *BridgeService) parkForFees(event BridgeEvent, method string, retry *SettlementRetry, cause error) {
+	if retry == nil {
+		retry = &SettlementRetry{EventID: event.ID, Method: method}
+	}
+	retry.LastError = cause.Error()
+	retry.NextAttempt = bs.clock.Now().Add(bs.retry.backoff(1))
+	if err := bs.store.SaveRetry(*retry); err != nil {
+		log.Printf("Failed to park %s of %s, it will not be retried: %v", method, event.ID, err)
+	}
+	feeCapDeferrals.WithLabelValues(event.ToChain, method).Inc()
+
+	settlement := bs.settlementEvent(event, method, "", StatusFeeCapExceeded, cause)
+	settlement.Attempts = retry.Attempts
+	settlement.NextAttemptAt = &retry.NextAttempt
+	bs.eventChan <- settlement
+}
+
+// releaseHeldNonce stops holding the nonce of a retry's unmined transaction
+// once the retry is over; the node accounts for it from then on.
+func (bs *BridgeService) releaseHeldNonce(chainName string, retry SettlementRetry) {
+	if retry.PendingNonce != nil {
+		bs.nonceManager(chainName).Done(*retry.PendingNonce)
+	}
+}
+
+func (bs *BridgeService) dropRetry(eventID string) {
+	if err := bs.store.DeleteRetry(eventID); err != nil {
+		log.Printf("Failed to remove retry of %s: %v", eventID, err)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	httpShutdownTimeout = 10 * time.Second
+
+	// settlementDrainTimeout bounds how long shutdown waits for mints and
+	// unlocks already in flight to be mined.
+	settlementDrainTimeout = 30 * time.Second
+)
+
+// startSettlement runs settle in a tracked goroutine. Once shutdown has
+// begun nothing new is started; the event keeps its status and is replayed
+// on the next start.
+func (bs *BridgeService) startSettlement(event BridgeEvent, settle func(BridgeEvent)) bool {
+	bs.settleMu.Lock()
+	defer bs.settleMu.Unlock()
+
+	if bs.stopping {
+		return false
+	}
+	bs.settlements.Add(1)
+	go func() {
+		defer bs.settlements.Done()
+		settle(event)
+	}()
+	return true
+}
+
+// shutdown runs after the root context is cancelled. It stops client
+// traffic, lets listeners exit and in-flight settlements finish, then saves
+// whatever is still queued in eventChan.
+func (bs *BridgeService) shutdown(server *http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("HTTP server shutdown: %v", err)
+	}
+	bs.hub.CloseAll()
+
+	bs.listeners.Wait()
+
+	bs.settleMu.Lock()
+	bs.stopping = true
+	bs.settleMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		bs.settlements.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-bs.clock.After(settlementDrainTimeout):
+		log.Printf("Gave up waiting for in-flight settlements after %s", settlementDrainTimeout)
+	}
+
+	bs.drainEvents()
+	log.Println("Bridge service stopped")
+}
+
+// drainEvents persists events ProcessBridgeEvents never got to. Locks and
+// burns are already stored at intake and only need their row to exist for
+// replay; settlement outcomes would otherwise be lost.
+func (bs *BridgeService) drainEvents() {
+	for {
+		select {
+		case event := <-bs.eventChan:
+			switch event.Type {
+			case "lock", "burn":
+				if err := bs.store.SaveEvent(event); err != nil {
+					log.Printf("Failed to persist drained event %s: %v", event.ID, err)
+				}
+			case "mint", "unlock":
+				if !bs.transitionSettlement(&event) {
+					continue
+				}
+				if event.Status == StatusCompleted {
+					bs.finalize(&event)
+				} else if err := bs.store.UpdateStatus(event.ID, event.Status); err != nil {
+					log.Printf("Failed to persist drained status of %s: %v", event.ID, err)
+				}
+			}
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const sseKeepaliveInterval = 15 * time.Second
+
+// handleSSE streams the hub's events as Server-Sent Events, for clients
+// behind proxies that don't pass websockets. It takes the same filter query
+// parameters as /ws. Each event's id is its hub sequence number, so a client
+// reconnecting with Last-Event-ID is first sent the kept events it missed.
+func (bs *BridgeService) handleSSE(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+	filter, err := bs.filterFromQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var after uint64
+	resuming := false
+	if lastID := r.Header.Get("Last-Event-ID"); lastID != "" {
+		if after, err = strconv.ParseUint(lastID, 10, 64); err != nil {
+			writeError(w, http.StatusBadRequest, "malformed Last-Event-ID")
+			return
+		}
+		resuming = true
+	}
+
+	var client *hubClient
+	if resuming {
+		var missed bool
+		client, missed = bs.hub.Resume(filter, after)
+		if missed {
+			log.Printf("SSE client resuming after %d missed events that are no longer kept", after)
+		}
+	} else {
+		client = bs.hub.Register()
+		if filter != nil {
+			client.filter.Store(filter)
+		}
+	}
+	defer bs.hub.Unregister(client)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	sseConnections.Inc()
+	log.Println("New SSE connection established")
+
+	ticker := bs.clock.NewTicker(sseKeepaliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case frame, ok := <-client.send:
+			if !ok {
+				return
+			}
+			event, ok := frame.(sequencedEvent)
+			if !ok {
+				continue
+			}
+			data, err := json.Marshal(event.Event)
+			if err != nil {
+				log.Printf("Failed to encode event %s for SSE: %v", event.Event.ID, err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\ndata: %s\n\n", event.Seq, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-ticker.C():
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,169 @@
+// Package statuscallback is for consumers of the bridge service's status
+// update callback. It checks the HMAC signature on each delivery and drops
+// updates that arrive after a newer one for the same transfer, which retries
+// can cause.
+package statuscallback
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	VersionHeader   = "X-Bridge-Payload-Version"
+	SequenceHeader  = "X-Bridge-Update-Sequence"
+	SignatureHeader = "X-Bridge-Signature"
+	BatchHeader     = "X-Bridge-Batch-Size"
+)
+
+var (
+	ErrBadSignature = errors.New("status update signature mismatch")
+	ErrStale        = errors.New("status update is older than one already applied")
+)
+
+// Update is a verified delivery. Transfer is only set for payload version 2.
+type Update struct {
+	SchemaVersion  int             `json:"schemaVersion"`
+	UpdateSequence uint64          `json:"updateSequence"`
+	ID             string          `json:"id"`
+	Status         string          `json:"status"`
+	Transfer       json.RawMessage `json:"transfer,omitempty"`
+}
+
+type Verifier struct {
+	secret []byte
+
+	mu   sync.Mutex
+	last map[string]uint64
+}
+
+// NewVerifier checks signatures with secret. An empty secret skips the
+// signature check and only enforces ordering.
+func NewVerifier(secret string) *Verifier {
+	return &Verifier{secret: []byte(secret), last: make(map[string]uint64)}
+}
+
+// Verify authenticates a delivery and records its sequence. It returns
+// ErrStale for a delivery that should be acknowledged but not applied.
+// Batched deliveries need VerifyBatch.
+func (v *Verifier) Verify(header http.Header, body []byte) (*Update, error) {
+	if header.Get(BatchHeader) != "" {
+		return nil, fmt.Errorf("status update is a batch")
+	}
+	if len(v.secret) > 0 {
+		if err := v.checkSignature(header.Get(SignatureHeader), body); err != nil {
+			return nil, err
+		}
+	}
+
+	version, err := strconv.Atoi(header.Get(VersionHeader))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s header: %v", VersionHeader, err)
+	}
+	sequence, err := strconv.ParseUint(header.Get(SequenceHeader), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s header: %v", SequenceHeader, err)
+	}
+
+	var update Update
+	if err := json.Unmarshal(body, &update); err != nil {
+		return nil, fmt.Errorf("invalid status update body: %v", err)
+	}
+	switch version {
+	case 1:
+		update.SchemaVersion = 1
+		update.UpdateSequence = sequence
+	case 2:
+		if update.SchemaVersion != 2 || update.UpdateSequence != sequence {
+			return nil, fmt.Errorf("status update body does not match its headers")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported payload version %d", version)
+	}
+	if update.ID == "" {
+		return nil, fmt.Errorf("status update has no id")
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if sequence <= v.last[update.ID] {
+		return &update, ErrStale
+	}
+	v.last[update.ID] = sequence
+	return &update, nil
+}
+
+// VerifyBatch authenticates a delivery that may hold several version 2
+// updates and returns the ones to apply, in order. Stale updates in the
+// batch are left out. A delivery that isn't a batch is passed to Verify.
+func (v *Verifier) VerifyBatch(header http.Header, body []byte) ([]*Update, error) {
+	if header.Get(BatchHeader) == "" {
+		update, err := v.Verify(header, body)
+		if err == ErrStale {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return []*Update{update}, nil
+	}
+	if len(v.secret) > 0 {
+		if err := v.checkSignature(header.Get(SignatureHeader), body); err != nil {
+			return nil, err
+		}
+	}
+	if header.Get(VersionHeader) != "2" {
+		return nil, fmt.Errorf("batched status updates need payload version 2")
+	}
+	sequence, err := strconv.ParseUint(header.Get(SequenceHeader), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s header: %v", SequenceHeader, err)
+	}
+
+	var batch []Update
+	if err := json.Unmarshal(body, &batch); err != nil {
+		return nil, fmt.Errorf("invalid status update batch: %v", err)
+	}
+	if size, err := strconv.Atoi(header.Get(BatchHeader)); err != nil || size != len(batch) {
+		return nil, fmt.Errorf("status update batch does not match its headers")
+	}
+	for i, update := range batch {
+		if update.SchemaVersion != 2 || update.ID == "" || update.UpdateSequence > sequence {
+			return nil, fmt.Errorf("status update %d in batch is malformed", i)
+		}
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	var fresh []*Update
+	for i := range batch {
+		update := &batch[i]
+		if update.UpdateSequence <= v.last[update.ID] {
+			continue
+		}
+		v.last[update.ID] = update.UpdateSequence
+		fresh = append(fresh, update)
+	}
+	return fresh, nil
+}
+
+func (v *Verifier) checkSignature(signature string, body []byte) error {
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil || !strings.HasPrefix(signature, "sha256=") {
+		return ErrBadSignature
+	}
+	mac := hmac.New(sha256.New, v.secret)
+	mac.Write(body)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return ErrBadSignature
+	}
+	return nil
+}
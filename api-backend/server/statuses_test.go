@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var allStatuses = []TransferStatus{
+	StatusLocked, StatusPendingConfirmation, StatusBurned, StatusConfirmed, StatusMinting, StatusUnlocking,
+	StatusRetrying, StatusCompleted, StatusFailed, StatusPaused, StatusLimitExceeded, StatusReorged,
+	StatusManualReview, StatusUnsupportedToken, StatusUnsupportedAmount, StatusInvalidRecipient, StatusFeeCapExceeded,
+}
+
+// Every pair of statuses is allowed exactly when the map lists it, it stays
+// put or it goes to manual review.
+func TestStatusTransitionsFollowTheMap(t *testing.T) {
+	known := make(map[TransferStatus]bool, len(allStatuses))
+	for _, status := range allStatuses {
+		known[status] = true
+	}
+	for from, targets := range statusTransitions {
+		if !known[from] {
+			t.Errorf("transitions listed from unknown status %q", from)
+		}
+		for _, to := range targets {
+			if !known[to] {
+				t.Errorf("%s may move to unknown status %q", from, to)
+			}
+		}
+	}
+
+	for _, from := range allStatuses {
+		listed := make(map[TransferStatus]bool)
+		for _, to := range statusTransitions[from] {
+			listed[to] = true
+		}
+		for _, to := range allStatuses {
+			want := listed[to] || from == to || to == StatusManualReview
+			if got := canTransition(from, to); got != want {
+				t.Errorf("%s -> %s allowed = %v, want %v", from, to, got, want)
+			}
+		}
+	}
+}
+
+func TestStatusTransitions(t *testing.T) {
+	tests := []struct {
+		from, to TransferStatus
+		allowed  bool
+	}{
+		{StatusPendingConfirmation, StatusConfirmed, true},
+		{StatusConfirmed, StatusMinting, true},
+		{StatusMinting, StatusCompleted, true},
+		{StatusBurned, StatusUnlocking, true},
+		{StatusUnlocking, StatusCompleted, true},
+		{StatusMinting, StatusRetrying, true},
+		{StatusRetrying, StatusCompleted, true},
+		{StatusRetrying, StatusFailed, true},
+		{StatusFeeCapExceeded, StatusRetrying, true},
+		{StatusPendingConfirmation, StatusReorged, true},
+		{StatusReorged, StatusPendingConfirmation, true},
+		{StatusReorged, StatusBurned, true},
+		{StatusPaused, StatusConfirmed, true},
+		{StatusLimitExceeded, StatusUnlocking, true},
+		{StatusMinting, StatusConfirmed, true},
+		{StatusCompleted, StatusManualReview, true},
+		{StatusFailed, StatusManualReview, true},
+		{StatusCompleted, StatusCompleted, true},
+
+		{StatusCompleted, StatusMinting, false},
+		{StatusCompleted, StatusConfirmed, false},
+		{StatusCompleted, StatusRetrying, false},
+		{StatusCompleted, StatusFailed, false},
+		{StatusFailed, StatusRetrying, false},
+		{StatusFailed, StatusCompleted, false},
+		{StatusPendingConfirmation, StatusMinting, false},
+		{StatusPendingConfirmation, StatusCompleted, false},
+		{StatusConfirmed, StatusCompleted, false},
+		{StatusBurned, StatusMinting, false},
+		{StatusRetrying, StatusMinting, false},
+		{StatusRetrying, StatusPendingConfirmation, false},
+		{StatusMinting, StatusPendingConfirmation, false},
+		{StatusMinting, StatusReorged, false},
+		{StatusReorged, StatusConfirmed, false},
+		{StatusReorged, StatusCompleted, false},
+		{StatusUnsupportedToken, StatusConfirmed, false},
+		{StatusUnsupportedAmount, StatusMinting, false},
+		{StatusInvalidRecipient, StatusConfirmed, false},
+		{StatusManualReview, StatusCompleted, false},
+		{StatusPaused, StatusCompleted, false},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.from)+"->"+string(tt.to), func(t *testing.T) {
+			bs := &BridgeService{}
+			event := BridgeEvent{ID: "ethereum-0x01-0", Type: "lock", Status: tt.from}
+			counter := invalidTransitions.WithLabelValues(string(tt.from), string(tt.to))
+			before := promtestutil.ToFloat64(counter)
+
+			if got := bs.TransitionStatus(&event, tt.to); got != tt.allowed {
+				t.Fatalf("allowed = %v, want %v", got, tt.allowed)
+			}
+			refusals := promtestutil.ToFloat64(counter) - before
+			if tt.allowed {
+				if event.Status != tt.to || refusals != 0 {
+					t.Errorf("status %s with %v refusals counted, want %s and none", event.Status, refusals, tt.to)
+				}
+				return
+			}
+			if event.Status != tt.from || refusals != 1 {
+				t.Errorf("status %s with %v refusals counted, want %s left as it was and one", event.Status, refusals, tt.from)
+			}
+		})
+	}
+}
+
+// Terminal statuses have no way out but manual review.
+func TestTerminalStatusesOnlyGoToManualReview(t *testing.T) {
+	for _, from := range []TransferStatus{StatusCompleted, StatusFailed, StatusUnsupportedToken, StatusUnsupportedAmount, StatusInvalidRecipient, StatusManualReview} {
+		if len(statusTransitions[from]) != 0 {
+			t.Errorf("terminal status %s lists transitions %v", from, statusTransitions[from])
+		}
+		for _, to := range allStatuses {
+			if to != from && to != StatusManualReview && canTransition(from, to) {
+				t.Errorf("terminal status %s may move to %s", from, to)
+			}
+		}
+	}
+}
+
+// The transactions API filters by any status the lifecycle has, or by
+// "pending", and refuses anything else rather than returning an empty page.
+func TestListTransactionsRejectsUnknownStatus(t *testing.T) {
+	tb := newTestBridge(t)
+	tb.auth = newTestAuthenticator()
+	tb.warmup.Run(context.Background(), nil)
+	router := mux.NewRouter()
+	tb.registerAPIRoutes(router)
+
+	list := func(status string) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/transactions?status="+status, nil)
+		req.Header.Set("Authorization", "Bearer "+testReadKey)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	for _, status := range append(allStatuses, "pending") {
+		if code := list(string(status)); code != http.StatusOK {
+			t.Errorf("status=%s: HTTP %d, want 200", status, code)
+		}
+	}
+	for _, status := range []string{"complete", "COMPLETED", "settled"} {
+		if code := list(status); code != http.StatusBadRequest {
+			t.Errorf("status=%s: HTTP %d, want 400", status, code)
+		}
+	}
+}
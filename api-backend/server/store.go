@@ -0,0 +1,1084 @@
+package main
+
+import (
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+var ErrEventNotFound = errors.New("bridge event not found")
+
+// pendingStatuses are the statuses of transfers that haven't settled yet.
+var pendingStatuses = []TransferStatus{
+	StatusLocked, StatusPendingConfirmation, StatusConfirmed, StatusMinting, StatusPaused, StatusBurned, StatusUnlocking, StatusRetrying,
+	StatusFeeCapExceeded,
+}
+
+// EventFilter selects events for ListEvents. Empty fields don't filter.
+type EventFilter struct {
+	Sender    string
+	FromChain string
+	Statuses  []TransferStatus
+	Cursor    *EventCursor
+	Limit     int
+}
+
+// EventCursor is the position of the last event on a page; the next page
+// starts right after it in (created_at, id) descending order.
+type EventCursor struct {
+	CreatedAt int64  `json:"c"`
+	ID        string `json:"i"`
+}
+
+type BridgeStore interface {
+	SaveEvent(event BridgeEvent) error
+	UpdateStatus(id string, status TransferStatus) error
+	FinalizeStatus(id string, status TransferStatus, corridor string) (uint64, error)
+	ListCorridor(corridor string, minSeq uint64, limit int) ([]BridgeEvent, error)
+	GetByID(id string) (*BridgeEvent, error)
+	GetByTransferKey(key string) (*BridgeEvent, error)
+	GetByTxHash(txHash string) ([]BridgeEvent, error)
+	ListEvents(filter EventFilter) ([]BridgeEvent, *EventCursor, error)
+	ListPending() ([]BridgeEvent, error)
+	MarkTransferProcessed(key, eventID string) (bool, error)
+	RecordSignerPolicy(fingerprint, policy string) (previous string, err error)
+	GetCheckpoint(chain string) (Checkpoint, bool, error)
+	SampleCompleted(limit int) ([]BridgeEvent, error)
+	SaveCheckpoint(chain string, checkpoint Checkpoint) error
+	GetTokenMetadata(chain, token string) (TokenMetadata, bool, error)
+	SaveTokenMetadata(chain, token string, meta TokenMetadata) error
+	ListTokenMappings() ([]TokenMapping, error)
+	SaveTokenMapping(mapping TokenMapping) error
+	DeleteTokenMapping(sourceChain, sourceToken, targetChain string) (bool, error)
+	TransferClaimant(key string) (eventID string, found bool, err error)
+	SaveRetry(retry SettlementRetry) error
+	DueRetries(now time.Time) ([]SettlementRetry, error)
+	GetRetry(eventID string) (SettlementRetry, bool, error)
+	HeldNonces() (map[string][]uint64, error)
+	DeleteRetry(eventID string) error
+	ListPauses() ([]PauseState, error)
+	RecordPause(state PauseState, action string) error
+	ListTransferLimits() ([]TransferLimit, error)
+	SaveTransferLimit(limit TransferLimit) error
+	DeleteTransferLimit(fromChain, token, toChain string) (bool, error)
+	SaveLimitUsage(usage LimitUsage) error
+	LimitUsageSince(since time.Time) ([]LimitUsage, error)
+	PruneLimitUsage(before time.Time) error
+	ListWebhooks() ([]Webhook, error)
+	SaveWebhook(cfg WebhookConfig) error
+	DeleteWebhook(name string) (bool, error)
+	SetWebhookHealth(name string, failures int, disabled bool) error
+	SaveWebhookDelivery(delivery WebhookDelivery) error
+	DueWebhookDeliveries(now time.Time) ([]WebhookDelivery, error)
+	ListWebhookDeliveries(webhook, status string, limit int) ([]WebhookDelivery, error)
+	SaveStatusUpdate(update StatusUpdate) error
+	PendingStatusUpdates(callback string, limit int) ([]StatusUpdate, error)
+	RescheduleStatusUpdates(callback string, sequences []uint64, attempts int, next time.Time) error
+	DeleteStatusUpdates(callback string, sequences []uint64) error
+	TrimStatusUpdates(callback string, keep int) (int, error)
+	CountStatusUpdates(callback string) (int, error)
+	Close() error
+}
+
+// SQLStore keeps one row per transfer, keyed by the lock's event ID. The full
+// BridgeEvent is stored as JSON alongside the columns we filter on.
+type SQLStore struct {
+	db       *sql.DB
+	postgres bool
+	clock    Clock
+}
+
+// OpenStore connects to Postgres when BRIDGE_DATABASE_URL is set and to a
+// local SQLite file (BRIDGE_SQLITE_PATH, default bridge-events.db) otherwise.
+func OpenStore() (*SQLStore, error) {
+	if dsn := os.Getenv("BRIDGE_DATABASE_URL"); dsn != "" {
+		return NewSQLStore("postgres", dsn)
+	}
+
+	path := os.Getenv("BRIDGE_SQLITE_PATH")
+	if path == "" {
+		path = "bridge-events.db"
+	}
+	return NewSQLStore("sqlite", "file:"+path+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
+}
+
+func NewSQLStore(driver, dsn string) (*SQLStore, error) {
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s store: %v", driver, err)
+	}
+	if driver == "sqlite" {
+		// SQLite allows a single writer; serializing avoids SQLITE_BUSY.
+		db.SetMaxOpenConns(1)
+	}
+
+	store := &SQLStore{db: db, postgres: driver == "postgres", clock: realClock{}}
+	if err := store.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+func (s *SQLStore) migrate() error {
+	if _, err := s.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at BIGINT NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %v", err)
+	}
+
+	entries, err := migrations.ReadDir("migrations")
+	if err != nil {
+		return fmt.Errorf("failed to read migrations: %v", err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	for _, entry := range entries {
+		name := entry.Name()
+		version, err := strconv.Atoi(strings.SplitN(name, "_", 2)[0])
+		if err != nil {
+			return fmt.Errorf("migration %s has no numeric version prefix", name)
+		}
+
+		var applied int
+		if err := s.db.QueryRow(s.rebind(`SELECT COUNT(*) FROM schema_migrations WHERE version = ?`), version).Scan(&applied); err != nil {
+			return fmt.Errorf("failed to check migration %s: %v", name, err)
+		}
+		if applied > 0 {
+			continue
+		}
+
+		body, err := migrations.ReadFile("migrations/" + name)
+		if err != nil {
+			return fmt.Errorf("failed to read migration %s: %v", name, err)
+		}
+
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		for _, statement := range strings.Split(string(body), ";") {
+			if strings.TrimSpace(statement) == "" {
+				continue
+			}
+			if _, err := tx.Exec(statement); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("migration %s failed: %v", name, err)
+			}
+		}
+		if _, err := tx.Exec(s.rebind(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`), version, s.clock.Now().Unix()); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record migration %s: %v", name, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit migration %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// rebind turns ? placeholders into Postgres' $N form.
+func (s *SQLStore) rebind(query string) string {
+	if !s.postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (s *SQLStore) SaveEvent(event BridgeEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	now := s.clock.Now().Unix()
+
+	_, err = s.db.Exec(s.rebind(`INSERT INTO bridge_events
+		(id, event_type, from_chain, to_chain, nonce, transfer_key, tx_hash, sender, block_number, status, payload, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET status = excluded.status, payload = excluded.payload, updated_at = excluded.updated_at`),
+		event.ID, event.Type, event.FromChain, event.ToChain, event.Nonce, event.TransferKey, event.TxHash, event.Sender,
+		event.BlockNumber, event.Status, string(payload), now, now)
+	if err != nil {
+		return fmt.Errorf("failed to save event %s: %v", event.ID, err)
+	}
+	return nil
+}
+
+func (s *SQLStore) UpdateStatus(id string, status TransferStatus) error {
+	result, err := s.db.Exec(s.rebind(`UPDATE bridge_events SET status = ?, updated_at = ? WHERE id = ?`),
+		status, s.clock.Now().Unix(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update status of %s: %v", id, err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrEventNotFound
+	}
+	return nil
+}
+
+// FinalizeStatus moves a transfer to a terminal status and gives it the next
+// sequence number in its corridor, all in one transaction so a number is
+// never handed out twice or skipped. Finalizing again returns the number
+// already assigned.
+func (s *SQLStore) FinalizeStatus(id string, status TransferStatus, corridor string) (uint64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var payload string
+	var assigned sql.NullInt64
+	err = tx.QueryRow(s.rebind(`SELECT payload, corridor_seq FROM bridge_events WHERE id = ?`), id).Scan(&payload, &assigned)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrEventNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to load %s: %v", id, err)
+	}
+	if assigned.Valid {
+		return uint64(assigned.Int64), tx.Commit()
+	}
+
+	if _, err := tx.Exec(s.rebind(`INSERT INTO corridor_sequences (corridor, last_seq) VALUES (?, 0) ON CONFLICT (corridor) DO NOTHING`), corridor); err != nil {
+		return 0, fmt.Errorf("failed to create corridor %s: %v", corridor, err)
+	}
+	var seq uint64
+	if err := tx.QueryRow(s.rebind(`UPDATE corridor_sequences SET last_seq = last_seq + 1 WHERE corridor = ? RETURNING last_seq`), corridor).Scan(&seq); err != nil {
+		return 0, fmt.Errorf("failed to allocate sequence in %s: %v", corridor, err)
+	}
+
+	event, err := decodeEvent(payload, status)
+	if err != nil {
+		return 0, err
+	}
+	event.Corridor = corridor
+	event.CorridorSeq = seq
+	updated, err := json.Marshal(event)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(s.rebind(`UPDATE bridge_events SET status = ?, payload = ?, corridor = ?, corridor_seq = ?, updated_at = ? WHERE id = ?`),
+		status, string(updated), corridor, seq, s.clock.Now().Unix(), id); err != nil {
+		return 0, fmt.Errorf("failed to finalize %s: %v", id, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit finalization of %s: %v", id, err)
+	}
+	return seq, nil
+}
+
+func (s *SQLStore) ListCorridor(corridor string, minSeq uint64, limit int) ([]BridgeEvent, error) {
+	rows, err := s.db.Query(s.rebind(`SELECT payload, status FROM bridge_events
+		WHERE corridor = ? AND corridor_seq >= ? ORDER BY corridor_seq LIMIT ?`), corridor, minSeq, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list corridor %s: %v", corridor, err)
+	}
+	return scanEvents(rows)
+}
+
+func (s *SQLStore) GetByID(id string) (*BridgeEvent, error) {
+	return s.queryOne(`SELECT payload, status FROM bridge_events WHERE id = ?`, id)
+}
+
+// GetByTransferKey looks a transfer up by its TransferKey string, which
+// unlike the chain-specific nonce is unique across adapters. A transfer
+// recorded again after a reorg has several rows; the live one comes first.
+func (s *SQLStore) GetByTransferKey(key string) (*BridgeEvent, error) {
+	return s.queryOne(`SELECT payload, status FROM bridge_events WHERE transfer_key = ?
+		ORDER BY CASE WHEN status = ? THEN 1 ELSE 0 END, created_at DESC, id DESC LIMIT 1`, key, StatusReorged)
+}
+
+// SampleCompleted returns up to limit random completed transfers.
+func (s *SQLStore) SampleCompleted(limit int) ([]BridgeEvent, error) {
+	rows, err := s.db.Query(s.rebind(`SELECT payload, status FROM bridge_events WHERE status = ? ORDER BY RANDOM() LIMIT ?`),
+		StatusCompleted, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sample completed events: %v", err)
+	}
+	return scanEvents(rows)
+}
+
+func (s *SQLStore) GetByTxHash(txHash string) ([]BridgeEvent, error) {
+	rows, err := s.db.Query(s.rebind(`SELECT payload, status FROM bridge_events WHERE tx_hash = ? ORDER BY id`), txHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events for tx %s: %v", txHash, err)
+	}
+	return scanEvents(rows)
+}
+
+func (s *SQLStore) ListEvents(filter EventFilter) ([]BridgeEvent, *EventCursor, error) {
+	var where []string
+	var args []interface{}
+	if filter.Sender != "" {
+		where = append(where, "sender = ?")
+		args = append(args, filter.Sender)
+	}
+	if filter.FromChain != "" {
+		where = append(where, "from_chain = ?")
+		args = append(args, filter.FromChain)
+	}
+	if len(filter.Statuses) > 0 {
+		where = append(where, "status IN (?"+strings.Repeat(", ?", len(filter.Statuses)-1)+")")
+		for _, status := range filter.Statuses {
+			args = append(args, status)
+		}
+	}
+	if filter.Cursor != nil {
+		where = append(where, "(created_at < ? OR (created_at = ? AND id < ?))")
+		args = append(args, filter.Cursor.CreatedAt, filter.Cursor.CreatedAt, filter.Cursor.ID)
+	}
+
+	query := `SELECT payload, status, created_at FROM bridge_events`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY created_at DESC, id DESC LIMIT ?"
+	args = append(args, filter.Limit)
+
+	rows, err := s.db.Query(s.rebind(query), args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list events: %v", err)
+	}
+	defer rows.Close()
+
+	var events []BridgeEvent
+	var last EventCursor
+	for rows.Next() {
+		var payload string
+		var status TransferStatus
+		if err := rows.Scan(&payload, &status, &last.CreatedAt); err != nil {
+			return nil, nil, err
+		}
+		event, err := decodeEvent(payload, status)
+		if err != nil {
+			return nil, nil, err
+		}
+		events = append(events, *event)
+		last.ID = event.ID
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if len(events) < filter.Limit {
+		return events, nil, nil
+	}
+	return events, &last, nil
+}
+
+func (s *SQLStore) ListPending() ([]BridgeEvent, error) {
+	// "locked" covers rows written before confirmation tracking existed.
+	args := make([]interface{}, len(pendingStatuses))
+	for i, status := range pendingStatuses {
+		args[i] = status
+	}
+	rows, err := s.db.Query(s.rebind(`SELECT payload, status FROM bridge_events
+		WHERE status IN (?`+strings.Repeat(", ?", len(pendingStatuses)-1)+`) ORDER BY block_number, id`), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pending events: %v", err)
+	}
+	return scanEvents(rows)
+}
+
+func scanEvents(rows *sql.Rows) ([]BridgeEvent, error) {
+	defer rows.Close()
+
+	var events []BridgeEvent
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, *event)
+	}
+	return events, rows.Err()
+}
+
+// MarkTransferProcessed records that a mint for the transfer with the given
+// TransferKey string is being sent. It returns false if the transfer was
+// already marked, in which case the caller must not mint again.
+func (s *SQLStore) MarkTransferProcessed(key, eventID string) (bool, error) {
+	parsed, err := ParseTransferKey(key)
+	if err != nil {
+		return false, err
+	}
+	result, err := s.db.Exec(s.rebind(`INSERT INTO processed_nonces (from_chain, nonce, transfer_key, event_id, processed_at)
+		VALUES (?, ?, ?, ?, ?) ON CONFLICT (transfer_key) DO NOTHING`),
+		parsed.Chain, parsed.ID, key, eventID, s.clock.Now().Unix())
+	if err != nil {
+		return false, fmt.Errorf("failed to mark transfer %s processed: %v", key, err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
+// TransferClaimant returns the event that claimed the transfer key, if any.
+func (s *SQLStore) TransferClaimant(key string) (string, bool, error) {
+	var eventID string
+	err := s.db.QueryRow(s.rebind(`SELECT event_id FROM processed_nonces WHERE transfer_key = ?`), key).Scan(&eventID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to look up transfer %s: %v", key, err)
+	}
+	return eventID, true, nil
+}
+
+func (s *SQLStore) SaveRetry(r SettlementRetry) error {
+	var pendingNonce sql.NullInt64
+	if r.PendingNonce != nil {
+		pendingNonce = sql.NullInt64{Int64: int64(*r.PendingNonce), Valid: true}
+	}
+	pendingGas := ""
+	if r.PendingGas != nil {
+		encoded, err := json.Marshal(r.PendingGas)
+		if err != nil {
+			return fmt.Errorf("failed to encode gas of %s: %v", r.EventID, err)
+		}
+		pendingGas = string(encoded)
+	}
+	_, err := s.db.Exec(s.rebind(`INSERT INTO settlement_retries (event_id, method, attempts, next_attempt, last_error, tx_hashes, pending_nonce, pending_gas)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (event_id) DO UPDATE SET
+			attempts = excluded.attempts, next_attempt = excluded.next_attempt,
+			last_error = excluded.last_error, tx_hashes = excluded.tx_hashes,
+			pending_nonce = excluded.pending_nonce, pending_gas = excluded.pending_gas`),
+		r.EventID, r.Method, r.Attempts, r.NextAttempt.Unix(), r.LastError, strings.Join(r.TxHashes, ","), pendingNonce, pendingGas)
+	if err != nil {
+		return fmt.Errorf("failed to save retry of %s: %v", r.EventID, err)
+	}
+	return nil
+}
+
+const retryColumns = `event_id, method, attempts, next_attempt, last_error, tx_hashes, pending_nonce, pending_gas`
+
+func (s *SQLStore) DueRetries(now time.Time) ([]SettlementRetry, error) {
+	rows, err := s.db.Query(s.rebind(`SELECT `+retryColumns+`
+		FROM settlement_retries WHERE next_attempt <= ? ORDER BY next_attempt`), now.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due retries: %v", err)
+	}
+	defer rows.Close()
+
+	var retries []SettlementRetry
+	for rows.Next() {
+		r, err := scanRetry(rows)
+		if err != nil {
+			return nil, err
+		}
+		retries = append(retries, r)
+	}
+	return retries, rows.Err()
+}
+
+// GetRetry returns the retry recorded for an event, if any.
+func (s *SQLStore) GetRetry(eventID string) (SettlementRetry, bool, error) {
+	r, err := scanRetry(s.db.QueryRow(s.rebind(`SELECT `+retryColumns+`
+		FROM settlement_retries WHERE event_id = ?`), eventID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return SettlementRetry{}, false, nil
+	}
+	if err != nil {
+		return SettlementRetry{}, false, err
+	}
+	return r, true, nil
+}
+
+func scanRetry(row rowScanner) (SettlementRetry, error) {
+	var r SettlementRetry
+	var next int64
+	var txHashes, pendingGas string
+	var pendingNonce sql.NullInt64
+	if err := row.Scan(&r.EventID, &r.Method, &r.Attempts, &next, &r.LastError, &txHashes, &pendingNonce, &pendingGas); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return r, err
+		}
+		return r, fmt.Errorf("failed to read retry: %v", err)
+	}
+	r.NextAttempt = time.Unix(next, 0)
+	if txHashes != "" {
+		r.TxHashes = strings.Split(txHashes, ",")
+	}
+	if pendingNonce.Valid {
+		nonce := uint64(pendingNonce.Int64)
+		r.PendingNonce = &nonce
+	}
+	if pendingGas != "" {
+		r.PendingGas = new(GasParams)
+		if err := json.Unmarshal([]byte(pendingGas), r.PendingGas); err != nil {
+			return r, fmt.Errorf("failed to decode gas of retry %s: %v", r.EventID, err)
+		}
+	}
+	return r, nil
+}
+
+// HeldNonces returns the relayer nonces retries hold for transactions that
+// may still be mined, by destination chain.
+func (s *SQLStore) HeldNonces() (map[string][]uint64, error) {
+	rows, err := s.db.Query(`SELECT e.to_chain, r.pending_nonce FROM settlement_retries r
+		JOIN bridge_events e ON e.id = r.event_id WHERE r.pending_nonce IS NOT NULL`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list held nonces: %v", err)
+	}
+	defer rows.Close()
+
+	held := make(map[string][]uint64)
+	for rows.Next() {
+		var chain string
+		var nonce int64
+		if err := rows.Scan(&chain, &nonce); err != nil {
+			return nil, fmt.Errorf("failed to read held nonce: %v", err)
+		}
+		held[chain] = append(held[chain], uint64(nonce))
+	}
+	return held, rows.Err()
+}
+
+func (s *SQLStore) DeleteRetry(eventID string) error {
+	if _, err := s.db.Exec(s.rebind(`DELETE FROM settlement_retries WHERE event_id = ?`), eventID); err != nil {
+		return fmt.Errorf("failed to delete retry of %s: %v", eventID, err)
+	}
+	return nil
+}
+
+// RecordSignerPolicy appends the policy to the audit table when it differs
+// from the last one recorded and returns that previous policy ("" if none).
+func (s *SQLStore) RecordSignerPolicy(fingerprint, policy string) (string, error) {
+	var lastFingerprint, lastPolicy string
+	err := s.db.QueryRow(`SELECT fingerprint, policy FROM signer_policy_audit ORDER BY loaded_at DESC LIMIT 1`).
+		Scan(&lastFingerprint, &lastPolicy)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("failed to read signer policy audit: %v", err)
+	}
+	if lastFingerprint == fingerprint {
+		return lastPolicy, nil
+	}
+
+	if _, err := s.db.Exec(s.rebind(`INSERT INTO signer_policy_audit (fingerprint, policy, loaded_at) VALUES (?, ?, ?)`),
+		fingerprint, policy, s.clock.Now().Unix()); err != nil {
+		return "", fmt.Errorf("failed to record signer policy: %v", err)
+	}
+	return lastPolicy, nil
+}
+
+func (s *SQLStore) GetCheckpoint(chain string) (Checkpoint, bool, error) {
+	var checkpoint Checkpoint
+	err := s.db.QueryRow(s.rebind(`SELECT block_number, log_index FROM chain_checkpoints WHERE chain = ?`), chain).
+		Scan(&checkpoint.Block, &checkpoint.LogIndex)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Checkpoint{}, false, nil
+	}
+	if err != nil {
+		return Checkpoint{}, false, fmt.Errorf("failed to read checkpoint for %s: %v", chain, err)
+	}
+	return checkpoint, true, nil
+}
+
+// SaveCheckpoint only ever moves a chain's checkpoint forward, so the live
+// listener and a backfill running side by side can't rewind each other.
+func (s *SQLStore) SaveCheckpoint(chain string, checkpoint Checkpoint) error {
+	_, err := s.db.Exec(s.rebind(`INSERT INTO chain_checkpoints (chain, block_number, log_index, updated_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (chain) DO UPDATE SET block_number = excluded.block_number, log_index = excluded.log_index, updated_at = excluded.updated_at
+		WHERE excluded.block_number > chain_checkpoints.block_number
+			OR (excluded.block_number = chain_checkpoints.block_number AND excluded.log_index > chain_checkpoints.log_index)`),
+		chain, checkpoint.Block, checkpoint.LogIndex, s.clock.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("failed to save checkpoint for %s: %v", chain, err)
+	}
+	return nil
+}
+
+func (s *SQLStore) GetTokenMetadata(chain, token string) (TokenMetadata, bool, error) {
+	var meta TokenMetadata
+	var decimals sql.NullInt64
+	err := s.db.QueryRow(s.rebind(`SELECT symbol, name, decimals FROM token_metadata WHERE chain = ? AND token = ?`), chain, token).
+		Scan(&meta.Symbol, &meta.Name, &decimals)
+	if errors.Is(err, sql.ErrNoRows) {
+		return TokenMetadata{}, false, nil
+	}
+	if err != nil {
+		return TokenMetadata{}, false, fmt.Errorf("failed to read token metadata: %v", err)
+	}
+	if decimals.Valid {
+		value := uint8(decimals.Int64)
+		meta.Decimals = &value
+	}
+	return meta, true, nil
+}
+
+func (s *SQLStore) SaveTokenMetadata(chain, token string, meta TokenMetadata) error {
+	var decimals sql.NullInt64
+	if meta.Decimals != nil {
+		decimals = sql.NullInt64{Int64: int64(*meta.Decimals), Valid: true}
+	}
+	_, err := s.db.Exec(s.rebind(`INSERT INTO token_metadata (chain, token, symbol, name, decimals, fetched_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (chain, token) DO UPDATE SET
+			symbol = excluded.symbol, name = excluded.name, decimals = excluded.decimals, fetched_at = excluded.fetched_at`),
+		chain, token, meta.Symbol, meta.Name, decimals, s.clock.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("failed to save token metadata: %v", err)
+	}
+	return nil
+}
+
+func (s *SQLStore) ListTokenMappings() ([]TokenMapping, error) {
+	rows, err := s.db.Query(`SELECT source_chain, source_token, source_decimals, target_chain, target_token, decimals, symbol FROM token_mappings`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list token mappings: %v", err)
+	}
+	defer rows.Close()
+
+	var mappings []TokenMapping
+	for rows.Next() {
+		var m TokenMapping
+		if err := rows.Scan(&m.SourceChain, &m.SourceToken, &m.SourceDecimals, &m.TargetChain, &m.TargetToken, &m.Decimals, &m.Symbol); err != nil {
+			return nil, fmt.Errorf("failed to read token mapping: %v", err)
+		}
+		mappings = append(mappings, m)
+	}
+	return mappings, rows.Err()
+}
+
+func (s *SQLStore) SaveTokenMapping(m TokenMapping) error {
+	_, err := s.db.Exec(s.rebind(`INSERT INTO token_mappings (source_chain, source_token, source_decimals, target_chain, target_token, decimals, symbol, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (source_chain, source_token, target_chain) DO UPDATE SET
+			source_decimals = excluded.source_decimals, target_token = excluded.target_token, decimals = excluded.decimals,
+			symbol = excluded.symbol, updated_at = excluded.updated_at`),
+		m.SourceChain, m.SourceToken, m.SourceDecimals, m.TargetChain, m.TargetToken, m.Decimals, m.Symbol, s.clock.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("failed to save token mapping: %v", err)
+	}
+	return nil
+}
+
+func (s *SQLStore) DeleteTokenMapping(sourceChain, sourceToken, targetChain string) (bool, error) {
+	result, err := s.db.Exec(s.rebind(`DELETE FROM token_mappings WHERE source_chain = ? AND source_token = ? AND target_chain = ?`),
+		sourceChain, sourceToken, targetChain)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete token mapping: %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete token mapping: %v", err)
+	}
+	return n > 0, nil
+}
+
+func (s *SQLStore) ListPauses() ([]PauseState, error) {
+	rows, err := s.db.Query(`SELECT scope, reason, actor, paused_at FROM pauses`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pauses: %v", err)
+	}
+	defer rows.Close()
+
+	var states []PauseState
+	for rows.Next() {
+		var state PauseState
+		var since int64
+		if err := rows.Scan(&state.Scope, &state.Reason, &state.Actor, &since); err != nil {
+			return nil, fmt.Errorf("failed to read pause: %v", err)
+		}
+		state.Since = time.Unix(since, 0)
+		states = append(states, state)
+	}
+	return states, rows.Err()
+}
+
+// RecordPause applies a pause or resume of state.Scope and appends it to the
+// audit trail in one transaction.
+func (s *SQLStore) RecordPause(state PauseState, action string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if action == "pause" {
+		_, err = tx.Exec(s.rebind(`INSERT INTO pauses (scope, reason, actor, paused_at) VALUES (?, ?, ?, ?)
+			ON CONFLICT (scope) DO UPDATE SET reason = excluded.reason, actor = excluded.actor, paused_at = excluded.paused_at`),
+			state.Scope, state.Reason, state.Actor, state.Since.Unix())
+	} else {
+		_, err = tx.Exec(s.rebind(`DELETE FROM pauses WHERE scope = ?`), state.Scope)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to %s %s: %v", action, state.Scope, err)
+	}
+
+	if _, err := tx.Exec(s.rebind(`INSERT INTO pause_audit (scope, action, actor, reason, recorded_at) VALUES (?, ?, ?, ?, ?)`),
+		state.Scope, action, state.Actor, state.Reason, s.clock.Now().Unix()); err != nil {
+		return fmt.Errorf("failed to audit %s of %s: %v", action, state.Scope, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit %s of %s: %v", action, state.Scope, err)
+	}
+	return nil
+}
+
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLStore) queryOne(query string, args ...interface{}) (*BridgeEvent, error) {
+	event, err := scanEvent(s.db.QueryRow(s.rebind(query), args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEventNotFound
+	}
+	return event, err
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanEvent(row rowScanner) (*BridgeEvent, error) {
+	var payload string
+	var status TransferStatus
+	if err := row.Scan(&payload, &status); err != nil {
+		return nil, err
+	}
+	return decodeEvent(payload, status)
+}
+
+// The status column is authoritative; the payload's copy goes stale once
+// UpdateStatus has run.
+func decodeEvent(payload string, status TransferStatus) (*BridgeEvent, error) {
+	var event BridgeEvent
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		return nil, fmt.Errorf("corrupt stored event: %v", err)
+	}
+	event.Status = status
+	if event.TransferKey == "" {
+		// Recorded before transfer keys; only EVM chains existed then.
+		event.TransferKey = TransferKey{Chain: event.FromChain, ID: event.Nonce}.String()
+	}
+	return &event, nil
+}
+
+func (s *SQLStore) ListTransferLimits() ([]TransferLimit, error) {
+	rows, err := s.db.Query(`SELECT from_chain, token, to_chain, min_amount, max_amount, daily_cap FROM transfer_limits`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list transfer limits: %v", err)
+	}
+	defer rows.Close()
+
+	var limits []TransferLimit
+	for rows.Next() {
+		var l TransferLimit
+		if err := rows.Scan(&l.FromChain, &l.Token, &l.ToChain, &l.Min, &l.Max, &l.DailyCap); err != nil {
+			return nil, fmt.Errorf("failed to read transfer limit: %v", err)
+		}
+		limits = append(limits, l)
+	}
+	return limits, rows.Err()
+}
+
+func (s *SQLStore) SaveTransferLimit(l TransferLimit) error {
+	_, err := s.db.Exec(s.rebind(`INSERT INTO transfer_limits (from_chain, token, to_chain, min_amount, max_amount, daily_cap, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (from_chain, token, to_chain) DO UPDATE SET
+			min_amount = excluded.min_amount, max_amount = excluded.max_amount, daily_cap = excluded.daily_cap, updated_at = excluded.updated_at`),
+		l.FromChain, l.Token, l.ToChain, l.Min, l.Max, l.DailyCap, s.clock.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("failed to save transfer limit: %v", err)
+	}
+	return nil
+}
+
+func (s *SQLStore) DeleteTransferLimit(fromChain, token, toChain string) (bool, error) {
+	result, err := s.db.Exec(s.rebind(`DELETE FROM transfer_limits WHERE from_chain = ? AND token = ? AND to_chain = ?`),
+		fromChain, token, toChain)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete transfer limit: %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete transfer limit: %v", err)
+	}
+	return n > 0, nil
+}
+
+// SaveLimitUsage records a transfer counted toward its daily volume. Counting
+// the same event again is a no-op.
+func (s *SQLStore) SaveLimitUsage(u LimitUsage) error {
+	_, err := s.db.Exec(s.rebind(`INSERT INTO limit_usage (event_id, from_chain, token, to_chain, amount, recorded_at)
+		VALUES (?, ?, ?, ?, ?, ?) ON CONFLICT (event_id) DO NOTHING`),
+		u.EventID, u.FromChain, u.Token, u.ToChain, FormatWhole(u.Amount), u.At.Unix())
+	if err != nil {
+		return fmt.Errorf("failed to save limit usage of %s: %v", u.EventID, err)
+	}
+	return nil
+}
+
+func (s *SQLStore) LimitUsageSince(since time.Time) ([]LimitUsage, error) {
+	rows, err := s.db.Query(s.rebind(`SELECT event_id, from_chain, token, to_chain, amount, recorded_at FROM limit_usage WHERE recorded_at > ?`),
+		since.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list limit usage: %v", err)
+	}
+	defer rows.Close()
+
+	var usage []LimitUsage
+	for rows.Next() {
+		var u LimitUsage
+		var amount string
+		var at int64
+		if err := rows.Scan(&u.EventID, &u.FromChain, &u.Token, &u.ToChain, &amount, &at); err != nil {
+			return nil, fmt.Errorf("failed to read limit usage: %v", err)
+		}
+		if u.Amount, err = ParseWhole(amount); err != nil {
+			return nil, fmt.Errorf("limit usage of %s: %v", u.EventID, err)
+		}
+		u.At = time.Unix(at, 0)
+		usage = append(usage, u)
+	}
+	return usage, rows.Err()
+}
+
+func (s *SQLStore) PruneLimitUsage(before time.Time) error {
+	if _, err := s.db.Exec(s.rebind(`DELETE FROM limit_usage WHERE recorded_at <= ?`), before.Unix()); err != nil {
+		return fmt.Errorf("failed to prune limit usage: %v", err)
+	}
+	return nil
+}
+
+func (s *SQLStore) ListWebhooks() ([]Webhook, error) {
+	rows, err := s.db.Query(`SELECT name, url, secret, events, disabled, consecutive_failures FROM webhooks`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %v", err)
+	}
+	defer rows.Close()
+
+	var hooks []Webhook
+	for rows.Next() {
+		var h Webhook
+		var events string
+		var disabled int
+		if err := rows.Scan(&h.Name, &h.URL, &h.Secret, &events, &disabled, &h.ConsecutiveFailures); err != nil {
+			return nil, fmt.Errorf("failed to read webhook: %v", err)
+		}
+		if events != "" {
+			h.Events = strings.Split(events, ",")
+		}
+		h.Disabled = disabled != 0
+		hooks = append(hooks, h)
+	}
+	return hooks, rows.Err()
+}
+
+// SaveWebhook adds or updates an endpoint, keeping the health of an existing
+// one.
+func (s *SQLStore) SaveWebhook(cfg WebhookConfig) error {
+	_, err := s.db.Exec(s.rebind(`INSERT INTO webhooks (name, url, secret, events, disabled, consecutive_failures, updated_at)
+		VALUES (?, ?, ?, ?, 0, 0, ?)
+		ON CONFLICT (name) DO UPDATE SET url = excluded.url, secret = excluded.secret, events = excluded.events, updated_at = excluded.updated_at`),
+		cfg.Name, cfg.URL, cfg.Secret, strings.Join(cfg.Events, ","), s.clock.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("failed to save webhook %s: %v", cfg.Name, err)
+	}
+	return nil
+}
+
+func (s *SQLStore) DeleteWebhook(name string) (bool, error) {
+	result, err := s.db.Exec(s.rebind(`DELETE FROM webhooks WHERE name = ?`), name)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete webhook %s: %v", name, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete webhook %s: %v", name, err)
+	}
+	return n > 0, nil
+}
+
+func (s *SQLStore) SetWebhookHealth(name string, failures int, disabled bool) error {
+	flag := 0
+	if disabled {
+		flag = 1
+	}
+	_, err := s.db.Exec(s.rebind(`UPDATE webhooks SET consecutive_failures = ?, disabled = ?, updated_at = ? WHERE name = ?`),
+		failures, flag, s.clock.Now().Unix(), name)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook %s: %v", name, err)
+	}
+	return nil
+}
+
+func (s *SQLStore) SaveWebhookDelivery(d WebhookDelivery) error {
+	_, err := s.db.Exec(s.rebind(`INSERT INTO webhook_deliveries
+		(id, webhook, event_id, event_type, payload, status, attempts, response_code, last_error, next_attempt, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			status = excluded.status, attempts = excluded.attempts, response_code = excluded.response_code,
+			last_error = excluded.last_error, next_attempt = excluded.next_attempt, updated_at = excluded.updated_at`),
+		d.ID, d.Webhook, d.EventID, d.EventType, string(d.Payload), d.Status, d.Attempts, d.ResponseCode, d.LastError,
+		d.NextAttempt.Unix(), d.CreatedAt.Unix(), d.UpdatedAt.Unix())
+	if err != nil {
+		return fmt.Errorf("failed to save webhook delivery %s: %v", d.ID, err)
+	}
+	return nil
+}
+
+const webhookDeliveryColumns = `id, webhook, event_id, event_type, payload, status, attempts, response_code, last_error, next_attempt, created_at, updated_at`
+
+func scanWebhookDeliveries(rows *sql.Rows) ([]WebhookDelivery, error) {
+	defer rows.Close()
+	var deliveries []WebhookDelivery
+	for rows.Next() {
+		var d WebhookDelivery
+		var payload string
+		var next, created, updated int64
+		if err := rows.Scan(&d.ID, &d.Webhook, &d.EventID, &d.EventType, &payload, &d.Status, &d.Attempts,
+			&d.ResponseCode, &d.LastError, &next, &created, &updated); err != nil {
+			return nil, fmt.Errorf("failed to read webhook delivery: %v", err)
+		}
+		d.Payload = json.RawMessage(payload)
+		d.NextAttempt, d.CreatedAt, d.UpdatedAt = time.Unix(next, 0), time.Unix(created, 0), time.Unix(updated, 0)
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+func (s *SQLStore) DueWebhookDeliveries(now time.Time) ([]WebhookDelivery, error) {
+	rows, err := s.db.Query(s.rebind(`SELECT `+webhookDeliveryColumns+` FROM webhook_deliveries
+		WHERE status = ? AND next_attempt <= ? ORDER BY next_attempt`), deliveryPending, now.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due webhook deliveries: %v", err)
+	}
+	return scanWebhookDeliveries(rows)
+}
+
+// ListWebhookDeliveries returns webhook's most recent deliveries, newest
+// first. An empty status matches every delivery.
+func (s *SQLStore) ListWebhookDeliveries(webhook, status string, limit int) ([]WebhookDelivery, error) {
+	query := `SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries WHERE webhook = ?`
+	args := []interface{}{webhook}
+	if status != "" {
+		query += ` AND status = ?`
+		args = append(args, status)
+	}
+	query += ` ORDER BY created_at DESC, id LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := s.db.Query(s.rebind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deliveries of webhook %s: %v", webhook, err)
+	}
+	return scanWebhookDeliveries(rows)
+}
+
+func (s *SQLStore) SaveStatusUpdate(u StatusUpdate) error {
+	_, err := s.db.Exec(s.rebind(`INSERT INTO status_updates (callback, sequence, event_id, payload, attempts, next_attempt, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`),
+		u.Callback, int64(u.Sequence), u.EventID, string(u.Payload), u.Attempts, u.NextAttempt.Unix(), u.CreatedAt.Unix())
+	if err != nil {
+		return fmt.Errorf("failed to queue status update %d: %v", u.Sequence, err)
+	}
+	return nil
+}
+
+// PendingStatusUpdates returns the oldest limit updates queued for callback,
+// in sequence order.
+func (s *SQLStore) PendingStatusUpdates(callback string, limit int) ([]StatusUpdate, error) {
+	rows, err := s.db.Query(s.rebind(`SELECT sequence, event_id, payload, attempts, next_attempt, created_at
+		FROM status_updates WHERE callback = ? ORDER BY sequence LIMIT ?`), callback, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list status updates for %s: %v", callback, err)
+	}
+	defer rows.Close()
+
+	var updates []StatusUpdate
+	for rows.Next() {
+		u := StatusUpdate{Callback: callback}
+		var sequence, next, created int64
+		var payload string
+		if err := rows.Scan(&sequence, &u.EventID, &payload, &u.Attempts, &next, &created); err != nil {
+			return nil, fmt.Errorf("failed to read status update: %v", err)
+		}
+		u.Sequence = uint64(sequence)
+		u.Payload = json.RawMessage(payload)
+		u.NextAttempt, u.CreatedAt = time.Unix(next, 0), time.Unix(created, 0)
+		updates = append(updates, u)
+	}
+	return updates, rows.Err()
+}
+
+func sequenceArgs(callback string, sequences []uint64) (string, []interface{}) {
+	args := []interface{}{callback}
+	for _, sequence := range sequences {
+		args = append(args, int64(sequence))
+	}
+	return "callback = ? AND sequence IN (?" + strings.Repeat(", ?", len(sequences)-1) + ")", args
+}
+
+func (s *SQLStore) RescheduleStatusUpdates(callback string, sequences []uint64, attempts int, next time.Time) error {
+	where, args := sequenceArgs(callback, sequences)
+	args = append([]interface{}{attempts, next.Unix()}, args...)
+	if _, err := s.db.Exec(s.rebind(`UPDATE status_updates SET attempts = ?, next_attempt = ? WHERE `+where), args...); err != nil {
+		return fmt.Errorf("failed to reschedule status updates for %s: %v", callback, err)
+	}
+	return nil
+}
+
+func (s *SQLStore) DeleteStatusUpdates(callback string, sequences []uint64) error {
+	where, args := sequenceArgs(callback, sequences)
+	if _, err := s.db.Exec(s.rebind(`DELETE FROM status_updates WHERE `+where), args...); err != nil {
+		return fmt.Errorf("failed to delete status updates for %s: %v", callback, err)
+	}
+	return nil
+}
+
+// TrimStatusUpdates drops all but the newest keep updates queued for
+// callback and returns how many it dropped.
+func (s *SQLStore) TrimStatusUpdates(callback string, keep int) (int, error) {
+	result, err := s.db.Exec(s.rebind(`DELETE FROM status_updates WHERE callback = ? AND sequence NOT IN
+		(SELECT sequence FROM status_updates WHERE callback = ? ORDER BY sequence DESC LIMIT ?)`), callback, callback, keep)
+	if err != nil {
+		return 0, fmt.Errorf("failed to trim status updates for %s: %v", callback, err)
+	}
+	dropped, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to trim status updates for %s: %v", callback, err)
+	}
+	return int(dropped), nil
+}
+
+func (s *SQLStore) CountStatusUpdates(callback string) (int, error) {
+	var count int
+	if err := s.db.QueryRow(s.rebind(`SELECT COUNT(*) FROM status_updates WHERE callback = ?`), callback).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count status updates for %s: %v", callback, err)
+	}
+	return count, nil
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A relayer killed between recording a lock and settling it picks the lock
+// up again from the database on the next start.
+func TestPendingLockSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bridge.db")
+	before := newTestBridgeWithStore(t, newTestStore(t, path))
+	vLog := before.lockLog(5, 1, 1000)
+	lock := before.emit(testSourceChain, vLog)
+	before.drain()
+	id := lockEventID(testSourceChain, lock)
+	// Crash: nothing is flushed or shut down cleanly.
+	before.db.Close()
+
+	after := newTestBridgeWithStore(t, newTestStore(t, path))
+	// The chain itself is unaffected by the crash.
+	after.mocks[testSourceChain].AddLogs(vLog)
+	if status := after.status(id); status != StatusPendingConfirmation {
+		t.Fatalf("%s restarted as %s", id, status)
+	}
+	after.replayPending()
+	if !after.drain() {
+		t.Fatal("pending lock was not replayed")
+	}
+	after.confirm()
+
+	if status := after.status(id); status != StatusCompleted {
+		t.Errorf("%s is %s after restart, want completed", id, status)
+	}
+	if mints := after.minted(testTargetChain); len(mints) != 1 {
+		t.Errorf("minted %d times after restart, want once", len(mints))
+	}
+}
+
+// Transfers waiting on the retry worker are resumed by it, not replayed.
+func TestRetryingTransferSurvivesRestartWithoutReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bridge.db")
+	store := newTestStore(t, path)
+	event := BridgeEvent{ID: "ethereum-0x01-0", Type: "lock", FromChain: testSourceChain, ToChain: testTargetChain,
+		Nonce: "0x01", TransferKey: "ethereum:0x01", Status: StatusRetrying}
+	if err := store.SaveEvent(event); err != nil {
+		t.Fatal(err)
+	}
+	retry := SettlementRetry{EventID: event.ID, Method: "mint", Attempts: 2, NextAttempt: testEpoch, LastError: "nonce too low", TxHashes: []string{"0xaa", "0xbb"}}
+	if err := store.SaveRetry(retry); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	tb := newTestBridgeWithStore(t, newTestStore(t, path))
+	tb.replayPending()
+	if tb.drain() {
+		t.Error("replayed a transfer the retry worker owns")
+	}
+	due, err := tb.store.DueRetries(testEpoch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(due) != 1 || due[0].Attempts != 2 || strings.Join(due[0].TxHashes, ",") != "0xaa,0xbb" {
+		t.Errorf("retries after restart = %+v", due)
+	}
+}
+
+func TestMigrationsApplyOnceAndRecordEveryVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bridge.db")
+	for i := 0; i < 3; i++ {
+		store := newTestStore(t, path)
+		store.Close()
+	}
+
+	db := openRawSQLite(t, path)
+	rows, err := db.Query(`SELECT version FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var applied []int
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			t.Fatal(err)
+		}
+		applied = append(applied, version)
+	}
+
+	want := migrationVersions(t)
+	if len(applied) != len(want) {
+		t.Fatalf("applied %v, want %v", applied, want)
+	}
+	for i := range want {
+		if applied[i] != want[i] {
+			t.Fatalf("applied %v, want %v", applied, want)
+		}
+	}
+}
+
+// A database last migrated by an older release is upgraded in place, and the
+// backfilling migrations carry its rows over.
+func TestMigrationUpgradesExistingDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bridge.db")
+	db := openRawSQLite(t, path)
+	applyMigrationsThrough(t, db, 7)
+
+	const nonce = "0x0000000000000000000000000000000000000000000000000000000000000001"
+	if _, err := db.Exec(`INSERT INTO token_mappings (source_chain, source_token, target_chain, target_token, decimals, symbol, updated_at)
+		VALUES ('ethereum', ?, 'polygon', ?, 6, 'USDC', 0)`, testToken.Hex(), testWrapped.Hex()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO bridge_events (id, event_type, from_chain, to_chain, nonce, tx_hash, sender, block_number, status, payload, created_at, updated_at)
+		VALUES ('old', 'lock', 'ethereum', 'polygon', ?, '0xaa', ?, 5, 'pending_confirmation', ?, 0, 0)`,
+		nonce, testSender.Hex(), `{"id":"old","type":"lock","fromChain":"ethereum","toChain":"polygon","nonce":"`+nonce+`"}`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO processed_nonces (from_chain, nonce, event_id, processed_at) VALUES ('ethereum', ?, 'old', 0)`, nonce); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	store := newTestStore(t, path)
+	key := "ethereum:" + nonce
+
+	mappings, err := store.ListTokenMappings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mappings) != 1 {
+		t.Fatalf("%d mappings after upgrade, want 1", len(mappings))
+	}
+	if m := mappings[0]; m.SourceDecimals == nil || *m.SourceDecimals != 6 || m.Decimals == nil || *m.Decimals != 6 {
+		t.Errorf("mapping after upgrade = %+v, want source decimals copied from decimals", m)
+	}
+	event, err := store.GetByTransferKey(key)
+	if err != nil || event.ID != "old" || event.TransferKey != key {
+		t.Errorf("event by transfer key = %+v, %v", event, err)
+	}
+	claimant, found, err := store.TransferClaimant(key)
+	if err != nil || !found || claimant != "old" {
+		t.Errorf("claimant = %q (found %v, err %v), want old", claimant, found, err)
+	}
+	if first, err := store.MarkTransferProcessed(key, "new"); err != nil || first {
+		t.Errorf("claimed a transfer claimed before the upgrade: %v, %v", first, err)
+	}
+}
+
+func openRawSQLite(t *testing.T, path string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func migrationVersions(t *testing.T) []int {
+	t.Helper()
+	entries, err := migrations.ReadDir("migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	versions := make([]int, 0, len(entries))
+	for _, entry := range entries {
+		version, err := strconv.Atoi(strings.SplitN(entry.Name(), "_", 2)[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		versions = append(versions, version)
+	}
+	return versions
+}
+
+// applyMigrationsThrough brings db to the schema of an older release, the
+// way migrate would have at the time.
+func applyMigrationsThrough(t *testing.T, db *sql.DB, last int) {
+	t.Helper()
+	if _, err := db.Exec(`CREATE TABLE schema_migrations (version INTEGER PRIMARY KEY, applied_at BIGINT NOT NULL)`); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := migrations.ReadDir("migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, version := range migrationVersions(t) {
+		if version > last {
+			return
+		}
+		body, err := migrations.ReadFile("migrations/" + entries[i].Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, statement := range strings.Split(string(body), ";") {
+			if strings.TrimSpace(statement) == "" {
+				continue
+			}
+			if _, err := db.Exec(statement); err != nil {
+				t.Fatalf("migration %s: %v", entries[i].Name(), err)
+			}
+		}
+		if _, err := db.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`, version, time.Now().Unix()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const receiptPollInterval = 3 * time.Second
+
+var errBumpCeiling = errors.New("fee is already at the ceiling")
+
+// errNotMined means waitForSettlement stopped waiting on a transaction that
+// may still be mined.
+var errNotMined = errors.New("not mined")
+
+// sentSettlement is one transaction sent for a settlement, the original or a
+// fee bump replacing it.
+type sentSettlement struct {
+	tx  *types.Transaction
+	gas *GasParams
+}
+
+// waitForSettlement waits for tx, or a replacement of it, to be mined. If
+// nothing is mined within the chain's gas.stuckAfterSeconds the transaction
+// is sent again with the same nonce and a higher fee, up to gas.maxBumps
+// times. Once one of them is mined the others can only fail with "nonce too
+// low", so whichever has a receipt settles the transfer. It returns every
+// transaction sent, the mined one last if there is one.
+func (bs *BridgeService) waitForSettlement(event BridgeEvent, method string, tx *types.Transaction, gas *GasParams) ([]sentSettlement, *types.Receipt, error) {
+	sent := []sentSettlement{{tx: tx, gas: gas}}
+	chain, ok := bs.chains.GetChain(event.ToChain)
+	if !ok {
+		return sent, nil, errUnknownChain(event.ToChain)
+	}
+	client, _ := bs.chains.GetClient(event.ToChain)
+	stuckAfter := time.Duration(chain.Gas.StuckAfterSeconds) * time.Second
+
+	ctx := context.Background()
+	lastSent := bs.clock.Now()
+	bumps := 0
+	for {
+		for i := len(sent) - 1; i >= 0; i-- {
+			receipt, err := client.TransactionReceipt(ctx, sent[i].tx.Hash())
+			if errors.Is(err, ethereum.NotFound) {
+				continue
+			}
+			if err != nil {
+				rpcErrors.WithLabelValues(event.ToChain, "transaction_receipt").Inc()
+				continue
+			}
+			mined := sent[i]
+			sent = append(append(sent[:i:i], sent[i+1:]...), mined)
+			if receipt.Status != types.ReceiptStatusSuccessful {
+				return sent, receipt, fmt.Errorf("transaction %s reverted", mined.tx.Hash().Hex())
+			}
+			return sent, receipt, nil
+		}
+
+		if Since(bs.clock, lastSent) >= stuckAfter {
+			if bumps >= chain.Gas.MaxBumps {
+				return sent, nil, fmt.Errorf("transaction %s %w after %d fee bumps", sent[len(sent)-1].tx.Hash().Hex(), errNotMined, bumps)
+			}
+			bumps++
+			lastSent = bs.clock.Now()
+			replacement, err := bs.bumpFee(ctx, chain, client, sent[len(sent)-1].tx)
+			switch {
+			case err == nil:
+				sent = append(sent, replacement)
+				bs.reportBump(event, method, sent, bumps)
+			case isNonceTooLow(err):
+				// One of the sent transactions was mined in the meantime;
+				// its receipt is picked up on the next poll.
+				log.Printf("Not bumping %s of %s: nonce already used, an earlier transaction was mined", method, event.ID)
+			case errors.Is(err, errBumpCeiling):
+				log.Printf("Not bumping %s of %s on %s: %v", method, event.ID, event.ToChain, err)
+				bumps = chain.Gas.MaxBumps
+			default:
+				log.Printf("Failed to bump fee of %s of %s on %s: %v", method, event.ID, event.ToChain, err)
+			}
+		}
+
+		if !Sleep(ctx, bs.clock, receiptPollInterval) {
+			return sent, nil, ctx.Err()
+		}
+	}
+}
+
+// bumpFee re-signs tx with the same nonce and its fees raised by
+// gas.bumpPercent, or to what the chain currently suggests if that is more,
+// and sends it. The new fee may not exceed gas.maxFeeGwei.
+func (bs *BridgeService) bumpFee(ctx context.Context, chain ChainConfig, client ChainClient, tx *types.Transaction) (sentSettlement, error) {
+	ctx, cancel := context.WithTimeout(ctx, mintSendTimeout)
+	defer cancel()
+
+	replacement, baseFee, err := bs.raiseFee(ctx, chain, client, tx)
+	if err != nil {
+		return sentSettlement{}, err
+	}
+	signed, err := bs.signer.SignTx(replacement, new(big.Int).SetUint64(chain.ChainID))
+	if err != nil {
+		return sentSettlement{}, fmt.Errorf("failed to sign replacement: %v", err)
+	}
+	if err := client.SendTransaction(ctx, signed); err != nil {
+		rpcErrors.WithLabelValues(chain.Name, "send_transaction").Inc()
+		return sentSettlement{}, err
+	}
+	return sentSettlement{tx: signed, gas: gasParamsOf(signed, baseFee)}, nil
+}
+
+// raiseFee returns an unsigned copy of tx priced to replace it, and the base
+// fee it was priced against (nil for legacy transactions).
+func (bs *BridgeService) raiseFee(ctx context.Context, chain ChainConfig, client ChainClient, tx *types.Transaction) (*types.Transaction, *big.Int, error) {
+	ceiling := gwei(defaultMaxGasPriceGwei)
+	if chain.Gas.MaxFeeGwei > 0 {
+		ceiling = gwei(chain.Gas.MaxFeeGwei)
+	} else if bs.signer.policy != nil {
+		ceiling = bs.signer.policy.maxGasPrice
+	}
+	bump := func(fee *big.Int) *big.Int {
+		raised := new(big.Int).Mul(fee, big.NewInt(int64(100+chain.Gas.BumpPercent)))
+		return raised.Div(raised, big.NewInt(100))
+	}
+
+	var replacement *types.Transaction
+	var baseFee *big.Int
+	if tx.Type() == types.LegacyTxType {
+		gasPrice := bump(tx.GasPrice())
+		if suggested, err := client.SuggestGasPrice(ctx); err == nil && suggested.Cmp(gasPrice) > 0 {
+			gasPrice = suggested
+		}
+		if gasPrice.Cmp(ceiling) > 0 {
+			if bump(tx.GasPrice()).Cmp(ceiling) > 0 {
+				return nil, nil, fmt.Errorf("%w: gas price %s, ceiling %s", errBumpCeiling, tx.GasPrice(), ceiling)
+			}
+			gasPrice = ceiling
+		}
+		replacement = types.NewTransaction(tx.Nonce(), *tx.To(), tx.Value(), tx.Gas(), gasPrice, tx.Data())
+	} else {
+		tip := bump(tx.GasTipCap())
+		if suggested, err := client.SuggestGasTipCap(ctx); err == nil && suggested.Cmp(tip) > 0 {
+			tip = suggested
+		}
+		feeCap := bump(tx.GasFeeCap())
+		if head, err := client.HeaderByNumber(ctx, nil); err == nil && head.BaseFee != nil {
+			baseFee = head.BaseFee
+			if current := new(big.Int).Add(new(big.Int).Mul(baseFee, big.NewInt(2)), tip); current.Cmp(feeCap) > 0 {
+				feeCap = current
+			}
+		}
+		if feeCap.Cmp(ceiling) > 0 {
+			if bump(tx.GasFeeCap()).Cmp(ceiling) > 0 {
+				return nil, nil, fmt.Errorf("%w: max fee %s, ceiling %s", errBumpCeiling, tx.GasFeeCap(), ceiling)
+			}
+			feeCap = ceiling
+		}
+		if tip.Cmp(feeCap) > 0 {
+			tip = feeCap
+		}
+		replacement = types.NewTx(&types.DynamicFeeTx{
+			ChainID:   tx.ChainId(),
+			Nonce:     tx.Nonce(),
+			GasTipCap: tip,
+			GasFeeCap: feeCap,
+			Gas:       tx.Gas(),
+			To:        tx.To(),
+			Value:     tx.Value(),
+			Data:      tx.Data(),
+		})
+	}
+	return replacement, baseFee, nil
+}
+
+// outbid reprices tx, built afresh for a nonce an unmined transaction sent
+// with gas still holds, so that the node accepts it as that transaction's
+// replacement.
+func (bs *BridgeService) outbid(ctx context.Context, chain ChainConfig, client ChainClient, tx *types.Transaction, gas GasParams) (*types.Transaction, *big.Int, error) {
+	var previous *types.Transaction
+	if gas.GasPrice != "" {
+		gasPrice, ok := new(big.Int).SetString(gas.GasPrice, 10)
+		if !ok {
+			return nil, nil, fmt.Errorf("invalid gas price %q of the transaction to replace", gas.GasPrice)
+		}
+		previous = types.NewTransaction(tx.Nonce(), *tx.To(), tx.Value(), tx.Gas(), gasPrice, tx.Data())
+	} else {
+		feeCap, okCap := new(big.Int).SetString(gas.MaxFeePerGas, 10)
+		tip, okTip := new(big.Int).SetString(gas.MaxPriorityFeePerGas, 10)
+		if !okCap || !okTip {
+			return nil, nil, fmt.Errorf("invalid fees %q/%q of the transaction to replace", gas.MaxFeePerGas, gas.MaxPriorityFeePerGas)
+		}
+		previous = types.NewTx(&types.DynamicFeeTx{
+			ChainID:   new(big.Int).SetUint64(chain.ChainID),
+			Nonce:     tx.Nonce(),
+			GasTipCap: tip,
+			GasFeeCap: feeCap,
+			Gas:       tx.Gas(),
+			To:        tx.To(),
+			Value:     tx.Value(),
+			Data:      tx.Data(),
+		})
+	}
+	return bs.raiseFee(ctx, chain, client, previous)
+}
+
+func isNonceTooLow(err error) bool {
+	return strings.Contains(strings.ToLower(err.Error()), "nonce too low")
+}
+
+// reportBump tells websocket, SSE and webhook subscribers that a settlement
+// was sent again with a higher fee, so they can show it being sped up.
+func (bs *BridgeService) reportBump(event BridgeEvent, method string, sent []sentSettlement, bumps int) {
+	latest := sent[len(sent)-1]
+	previous := sent[len(sent)-2]
+	feeBumps.WithLabelValues(event.ToChain, method).Inc()
+	log.Printf("Bumped fee of %s of %s on %s (bump %d): %s replaces %s", method, event.ID, event.ToChain, bumps,
+		latest.tx.Hash().Hex(), previous.tx.Hash().Hex())
+
+	update := bs.settlementEvent(event, method, latest.tx.Hash().Hex(), event.Status, nil)
+	update.FeeBumps = bumps
+	update.ReplacedTxHash = previous.tx.Hash().Hex()
+	update.Gas = latest.gas
+	bs.broadcastEvent(update)
+}
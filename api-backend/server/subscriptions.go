@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var subscribableTypes = map[string]bool{
+	"lock": true, "burn": true, "mint": true, "unlock": true, "alert": true,
+}
+
+// SubscriptionFilter narrows what a websocket client receives. Empty fields
+// match everything; Chains matches either side of a transfer.
+type SubscriptionFilter struct {
+	Chains []string `json:"chains,omitempty"`
+	Sender string   `json:"sender,omitempty"`
+	Types  []string `json:"types,omitempty"`
+}
+
+func (f *SubscriptionFilter) Matches(event BridgeEvent) bool {
+	if len(f.Chains) > 0 && !contains(f.Chains, event.FromChain) && !contains(f.Chains, event.ToChain) {
+		return false
+	}
+	if f.Sender != "" && f.Sender != event.Sender {
+		return false
+	}
+	if len(f.Types) > 0 && !contains(f.Types, event.Type) {
+		return false
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// subscriptionMessage is what a client sends on /ws. Action defaults to
+// subscribe, which replaces any earlier filter.
+type subscriptionMessage struct {
+	Action string `json:"action"`
+	SubscriptionFilter
+}
+
+type subscriptionFrame struct {
+	Type   string              `json:"type"`
+	Filter *SubscriptionFilter `json:"filter,omitempty"`
+	Error  string              `json:"error,omitempty"`
+}
+
+// handleSubscription applies a client message to its filter and returns the
+// frame to answer with. A bad message leaves the current filter in place.
+func (bs *BridgeService) handleSubscription(client *hubClient, message []byte) subscriptionFrame {
+	var msg subscriptionMessage
+	if err := json.Unmarshal(message, &msg); err != nil {
+		return subscriptionFrame{Type: "error", Error: "malformed subscription message"}
+	}
+
+	switch msg.Action {
+	case "", "subscribe":
+		filter := msg.SubscriptionFilter
+		if err := bs.validateFilter(&filter); err != nil {
+			return subscriptionFrame{Type: "error", Error: err.Error()}
+		}
+		client.filter.Store(&filter)
+		return subscriptionFrame{Type: "subscribed", Filter: &filter}
+	case "unsubscribe":
+		client.filter.Store(nil)
+		return subscriptionFrame{Type: "unsubscribed"}
+	default:
+		return subscriptionFrame{Type: "error", Error: fmt.Sprintf("unknown action %q", msg.Action)}
+	}
+}
+
+// filterFromQuery reads a filter from ?chain=, ?sender= and ?type=. chain and
+// type take comma-separated lists and may be repeated. It returns nil when
+// none is set.
+func (bs *BridgeService) filterFromQuery(query url.Values) (*SubscriptionFilter, error) {
+	filter := &SubscriptionFilter{
+		Chains: splitQueryList(query["chain"]),
+		Sender: query.Get("sender"),
+		Types:  splitQueryList(query["type"]),
+	}
+	if len(filter.Chains) == 0 && filter.Sender == "" && len(filter.Types) == 0 {
+		return nil, nil
+	}
+	if err := bs.validateFilter(filter); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+func splitQueryList(values []string) []string {
+	var out []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				out = append(out, item)
+			}
+		}
+	}
+	return out
+}
+
+// validateFilter rejects unknown chains and types and checksums the sender,
+// since events carry checksummed addresses.
+func (bs *BridgeService) validateFilter(f *SubscriptionFilter) error {
+	for _, chain := range f.Chains {
+		if !bs.chains.Has(chain) {
+			return fmt.Errorf("unknown chain %q", chain)
+		}
+	}
+	for _, eventType := range f.Types {
+		if !subscribableTypes[eventType] {
+			return fmt.Errorf("unknown event type %q", eventType)
+		}
+	}
+	if f.Sender != "" {
+		if !common.IsHexAddress(f.Sender) {
+			return fmt.Errorf("malformed sender address")
+		}
+		f.Sender = common.HexToAddress(f.Sender).Hex()
+	}
+	return nil
+}
@@ -0,0 +1,421 @@
+// Package testutil provides an in-memory chain for exercising the bridge
+// service without an RPC endpoint.
+package testutil
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// MockChain satisfies the service's ChainClient. Logs added with AddLogs are
+// served by FilterLogs, pushed to matching log subscriptions and listed in
+// their transaction's receipt; transactions passed to SendTransaction are
+// recorded and, unless Revert says otherwise, get a successful receipt in the
+// next block.
+type MockChain struct {
+	mu sync.Mutex
+
+	head     uint64
+	headers  map[uint64]*types.Header
+	logs     []types.Log
+	receipts map[common.Hash]*types.Receipt
+	sent     []*types.Transaction
+	nonce    uint64
+	pool     map[uint64]*types.Transaction
+	calls    map[string]int
+	logSubs  []*logSubscription
+	headSubs []*headSubscription
+	switched chan struct{}
+
+	// NoSubscriptions makes the subscribe methods fail the way an HTTP
+	// endpoint does, so callers fall back to polling.
+	NoSubscriptions bool
+	// SendErr, when set, is returned by SendTransaction; the transaction is
+	// still recorded, as a node may accept a transaction and still error.
+	SendErr error
+	// Hold keeps sent transactions in the pool unmined, as when fees are too
+	// low for them to be included, until MineHeld. A transaction sent with
+	// the nonce of a held one replaces it if it pays at least 10% more, and
+	// is refused as underpriced otherwise.
+	Hold bool
+	// Revert decides whether a sent transaction's receipt reports failure.
+	Revert func(tx *types.Transaction) bool
+	// Call answers CallContract; by default calls return no data.
+	Call     func(msg ethereum.CallMsg) ([]byte, error)
+	GasPrice *big.Int
+	Gas      uint64
+	// GasTipCap is the suggested priority fee. With BaseFee set, mined
+	// headers carry it, as on an EIP-1559 chain.
+	GasTipCap *big.Int
+	BaseFee   *big.Int
+	// NetworkID is reported by ChainID.
+	NetworkID uint64
+}
+
+func NewMockChain() *MockChain {
+	return &MockChain{
+		headers:   map[uint64]*types.Header{0: {Number: new(big.Int)}},
+		receipts:  make(map[common.Hash]*types.Receipt),
+		pool:      make(map[uint64]*types.Transaction),
+		calls:     make(map[string]int),
+		switched:  make(chan struct{}),
+		GasPrice:  big.NewInt(1_000_000_000),
+		GasTipCap: big.NewInt(1_000_000_000),
+		Gas:       100_000,
+
+		NetworkID: 1337,
+	}
+}
+
+// Mine advances the head by n empty blocks, one second apart.
+func (m *MockChain) Mine(n int) uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := 0; i < n; i++ {
+		m.mineLocked()
+	}
+	return m.head
+}
+
+func (m *MockChain) mineLocked() *types.Header {
+	parent := m.headers[m.head]
+	m.head++
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).SetUint64(m.head),
+		Time:       parent.Time + 1,
+	}
+	if m.BaseFee != nil {
+		header.BaseFee = new(big.Int).Set(m.BaseFee)
+	}
+	m.headers[m.head] = header
+	for _, sub := range m.headSubs {
+		sub.deliver(header)
+	}
+	return header
+}
+
+// AddLogs records logs at their BlockNumber, mining up to it if needed, fills
+// in the block hash and delivers them to matching subscriptions.
+func (m *MockChain) AddLogs(logs ...types.Log) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, vLog := range logs {
+		for m.head < vLog.BlockNumber {
+			m.mineLocked()
+		}
+		vLog.BlockHash = m.headers[vLog.BlockNumber].Hash()
+		m.logs = append(m.logs, vLog)
+		for _, sub := range m.logSubs {
+			if matches(sub.query, vLog) {
+				sub.deliver(vLog)
+			}
+		}
+	}
+}
+
+// RemoveLogs retracts the logs of txHash, as a reorg does: they are no longer
+// served or receipted, and subscribers get a copy marked Removed.
+func (m *MockChain) RemoveLogs(txHash common.Hash) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	kept := m.logs[:0]
+	for _, vLog := range m.logs {
+		if vLog.TxHash != txHash {
+			kept = append(kept, vLog)
+			continue
+		}
+		vLog.Removed = true
+		for _, sub := range m.logSubs {
+			if matches(sub.query, vLog) {
+				sub.deliver(vLog)
+			}
+		}
+	}
+	m.logs = kept
+}
+
+// Sent returns the transactions passed to SendTransaction, in order.
+func (m *MockChain) Sent() []*types.Transaction {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*types.Transaction(nil), m.sent...)
+}
+
+// Switch simulates a failover: it closes the current Switched channel and
+// ends every open subscription.
+func (m *MockChain) Switch() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	close(m.switched)
+	m.switched = make(chan struct{})
+	for _, sub := range m.logSubs {
+		sub.fail(errors.New("endpoint switched"))
+	}
+	for _, sub := range m.headSubs {
+		sub.fail(errors.New("endpoint switched"))
+	}
+	m.logSubs, m.headSubs = nil, nil
+}
+
+// DropSubscriptions ends every open subscription with an error, as a lost
+// connection to the same endpoint does.
+func (m *MockChain) DropSubscriptions() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, sub := range m.logSubs {
+		sub.fail(errors.New("connection lost"))
+	}
+	for _, sub := range m.headSubs {
+		sub.fail(errors.New("connection lost"))
+	}
+	m.logSubs, m.headSubs = nil, nil
+}
+
+// Calls returns how many times the head-reading method (BlockNumber,
+// HeaderByNumber or SubscribeNewHead) was called.
+func (m *MockChain) Calls(method string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.calls[method]
+}
+
+func (m *MockChain) Switched() <-chan struct{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.switched
+}
+
+func (m *MockChain) ChainID(ctx context.Context) (*big.Int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return new(big.Int).SetUint64(m.NetworkID), nil
+}
+
+func (m *MockChain) BlockNumber(ctx context.Context) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls["BlockNumber"]++
+	return m.head, nil
+}
+
+func (m *MockChain) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls["HeaderByNumber"]++
+	n := m.head
+	if number != nil {
+		n = number.Uint64()
+	}
+	header, ok := m.headers[n]
+	if !ok {
+		return nil, ethereum.NotFound
+	}
+	return types.CopyHeader(header), nil
+}
+
+func (m *MockChain) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if receipt, ok := m.receipts[txHash]; ok {
+		return receipt, nil
+	}
+	var receipt *types.Receipt
+	for _, vLog := range m.logs {
+		if vLog.TxHash != txHash {
+			continue
+		}
+		if receipt == nil {
+			receipt = &types.Receipt{
+				Status:      types.ReceiptStatusSuccessful,
+				TxHash:      txHash,
+				BlockHash:   vLog.BlockHash,
+				BlockNumber: new(big.Int).SetUint64(vLog.BlockNumber),
+			}
+		}
+		vLog := vLog
+		receipt.Logs = append(receipt.Logs, &vLog)
+	}
+	if receipt == nil {
+		return nil, ethereum.NotFound
+	}
+	return receipt, nil
+}
+
+// CodeAt reports code at every address, so contract checks pass.
+func (m *MockChain) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error) {
+	return []byte{0x60, 0x80}, nil
+}
+
+func (m *MockChain) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	if m.Call != nil {
+		return m.Call(msg)
+	}
+	return nil, nil
+}
+
+func (m *MockChain) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []types.Log
+	for _, vLog := range m.logs {
+		if query.FromBlock != nil && vLog.BlockNumber < query.FromBlock.Uint64() {
+			continue
+		}
+		if query.ToBlock != nil && vLog.BlockNumber > query.ToBlock.Uint64() {
+			continue
+		}
+		if matches(query, vLog) {
+			out = append(out, vLog)
+		}
+	}
+	return out, nil
+}
+
+func (m *MockChain) SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.NoSubscriptions {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	sub := &logSubscription{subscription: newSubscription(), query: query, ch: ch}
+	go sub.pump()
+	m.logSubs = append(m.logSubs, sub)
+	return sub, nil
+}
+
+func (m *MockChain) SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls["SubscribeNewHead"]++
+	if m.NoSubscriptions {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	sub := &headSubscription{subscription: newSubscription(), ch: ch}
+	go sub.pump()
+	m.headSubs = append(m.headSubs, sub)
+	return sub, nil
+}
+
+func (m *MockChain) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.nonce, nil
+}
+
+func (m *MockChain) EstimateGas(ctx context.Context, msg ethereum.CallMsg) (uint64, error) {
+	return m.Gas, nil
+}
+
+func (m *MockChain) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return new(big.Int).Set(m.GasPrice), nil
+}
+
+func (m *MockChain) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return new(big.Int).Set(m.GasTipCap), nil
+}
+
+func (m *MockChain) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if held, ok := m.pool[tx.Nonce()]; ok && !outbids(tx, held) {
+		return ErrReplacementUnderpriced
+	}
+	m.sent = append(m.sent, tx)
+	if m.SendErr != nil {
+		return m.SendErr
+	}
+	if tx.Nonce() >= m.nonce {
+		m.nonce = tx.Nonce() + 1
+	}
+	if m.Hold {
+		m.pool[tx.Nonce()] = tx
+		return nil
+	}
+	delete(m.pool, tx.Nonce())
+	m.includeLocked(tx)
+	return nil
+}
+
+// MineHeld mines the transactions Hold kept in the pool, in nonce order.
+func (m *MockChain) MineHeld() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	nonces := make([]uint64, 0, len(m.pool))
+	for nonce := range m.pool {
+		nonces = append(nonces, nonce)
+	}
+	sort.Slice(nonces, func(i, j int) bool { return nonces[i] < nonces[j] })
+	for _, nonce := range nonces {
+		m.includeLocked(m.pool[nonce])
+		delete(m.pool, nonce)
+	}
+}
+
+func (m *MockChain) includeLocked(tx *types.Transaction) {
+	header := m.mineLocked()
+	status := types.ReceiptStatusSuccessful
+	if m.Revert != nil && m.Revert(tx) {
+		status = types.ReceiptStatusFailed
+	}
+	m.receipts[tx.Hash()] = &types.Receipt{
+		Status:      status,
+		TxHash:      tx.Hash(),
+		BlockHash:   header.Hash(),
+		BlockNumber: new(big.Int).Set(header.Number),
+	}
+}
+
+// ErrReplacementUnderpriced is how a node refuses a transaction that doesn't
+// pay enough more than the pending one it would replace.
+var ErrReplacementUnderpriced = errors.New("replacement transaction underpriced")
+
+// outbids reports whether tx raises every fee of held by at least 10%.
+func outbids(tx, held *types.Transaction) bool {
+	raised := func(fee, old *big.Int) bool {
+		min := new(big.Int).Mul(old, big.NewInt(110))
+		return new(big.Int).Mul(fee, big.NewInt(100)).Cmp(min) >= 0
+	}
+	return raised(tx.GasFeeCap(), held.GasFeeCap()) && raised(tx.GasTipCap(), held.GasTipCap())
+}
+
+func matches(query ethereum.FilterQuery, vLog types.Log) bool {
+	if len(query.Addresses) > 0 && !containsAddress(query.Addresses, vLog.Address) {
+		return false
+	}
+	for i, wanted := range query.Topics {
+		if len(wanted) == 0 {
+			continue
+		}
+		if i >= len(vLog.Topics) || !containsHash(wanted, vLog.Topics[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsAddress(addresses []common.Address, address common.Address) bool {
+	for _, a := range addresses {
+		if a == address {
+			return true
+		}
+	}
+	return false
+}
+
+func containsHash(hashes []common.Hash, hash common.Hash) bool {
+	for _, h := range hashes {
+		if h == hash {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPayoutAmount(t *testing.T) {
+	tests := []struct {
+		name                     string
+		sourceDecimals, decimals uint8
+		eventType                string
+		amount                   string
+		want                     string
+	}{
+		{"6 to 18", 6, 18, "lock", "2500000", "2500000000000000000"},
+		{"18 to 6", 18, 6, "lock", "2500000000000000000", "2500000"},
+		{"equal decimals", 18, 18, "lock", "2500000000000000000", "2500000000000000000"},
+		{"burn of 18 back to 6", 6, 18, "burn", "2500000000000000000", "2500000"},
+		{"burn of 6 back to 18", 18, 6, "burn", "2500000", "2500000000000000000"},
+		{"zero", 6, 18, "lock", "0", "0"},
+		{"18 to 6 leaving dust", 18, 6, "lock", "2500000000000000001", ""},
+		{"18 to 6 below one unit", 18, 6, "lock", "999999999999", ""},
+		{"burn leaving dust", 6, 18, "burn", "1000000000001", ""},
+		{"not a number", 6, 18, "lock", "2.5", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := testMapping(tt.sourceDecimals, tt.decimals)
+			got, err := m.payoutAmount(BridgeEvent{Type: tt.eventType, Amount: tt.amount})
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("paid out %s, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("paid out %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// Limits count whole tokens, so a lock is priced in the decimals of the token
+// it moved.
+func TestLimitUsagePricesInSourceDecimals(t *testing.T) {
+	tb := newTestBridge(t)
+	tb.tokens.Put(testMapping(6, 18))
+
+	lock := BridgeEvent{ID: "lock", Type: "lock", FromChain: testSourceChain, ToChain: testTargetChain, Token: testToken.Hex(), Amount: "2500000"}
+	if usage, ok := tb.limitUsage(lock); !ok || usage.Amount.Cmp(big.NewRat(5, 2)) != 0 {
+		t.Errorf("lock usage = %+v (%v), want 2.5 tokens", usage, ok)
+	}
+	burn := BridgeEvent{ID: "burn", Type: "burn", FromChain: testTargetChain, ToChain: testSourceChain, Token: testWrapped.Hex(), Amount: "2500000000000000000"}
+	if usage, ok := tb.limitUsage(burn); !ok || usage.Amount.Cmp(big.NewRat(5, 2)) != 0 {
+		t.Errorf("burn usage = %+v (%v), want 2.5 tokens", usage, ok)
+	}
+}
+
+func TestTokenMappingRequiresDecimals(t *testing.T) {
+	chains := map[string]bool{testSourceChain: true, testTargetChain: true}
+	for _, tt := range []struct {
+		name string
+		body string
+		want string
+	}{
+		{"without sourceDecimals", `{"decimals":18}`, "sourceDecimals is required"},
+		{"without decimals", `{"sourceDecimals":6}`, "decimals is required"},
+		{"without either", `{}`, "sourceDecimals is required"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var m TokenMapping
+			if err := json.Unmarshal([]byte(tt.body), &m); err != nil {
+				t.Fatal(err)
+			}
+			m.SourceChain, m.SourceToken = testSourceChain, testToken.Hex()
+			m.TargetChain, m.TargetToken = testTargetChain, testWrapped.Hex()
+			if err := m.validate(chains); err == nil || err.Error() != tt.want {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	zero := testMapping(0, 0)
+	if err := zero.validate(chains); err != nil {
+		t.Errorf("explicit zero decimals refused: %v", err)
+	}
+}
+
+func TestPutTokenWithoutDecimalsKeepsStoredMapping(t *testing.T) {
+	tb := newTestBridge(t)
+	if err := tb.store.SaveTokenMapping(testMapping(6, 18)); err != nil {
+		t.Fatal(err)
+	}
+
+	body := `{"sourceChain":"ethereum","sourceToken":"` + testToken.Hex() + `","targetChain":"polygon","targetToken":"` + testWrapped.Hex() + `","decimals":18}`
+	rec := httptest.NewRecorder()
+	tb.handlePutToken(rec, httptest.NewRequest(http.MethodPut, "/admin/tokens", strings.NewReader(body)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("PUT without sourceDecimals: HTTP %d, want 400", rec.Code)
+	}
+	assertStoredDecimals(t, tb, 6, 18)
+}
+
+// A config written before sourceDecimals existed is refused rather than
+// saved over the decimals already in the store.
+func TestLoadTokensRefusesMappingWithoutDecimals(t *testing.T) {
+	tb := newTestBridge(t)
+	if err := tb.store.SaveTokenMapping(testMapping(6, 18)); err != nil {
+		t.Fatal(err)
+	}
+
+	configured := testMapping(6, 18)
+	configured.SourceDecimals = nil
+	if err := tb.loadTokens([]TokenMapping{configured}); err == nil || !strings.Contains(err.Error(), "sourceDecimals is required") {
+		t.Errorf("err = %v, want sourceDecimals required", err)
+	}
+	assertStoredDecimals(t, tb, 6, 18)
+}
+
+func assertStoredDecimals(t *testing.T, tb *testBridge, sourceDecimals, decimals uint8) {
+	t.Helper()
+	stored, err := tb.store.ListTokenMappings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 1 || *stored[0].SourceDecimals != sourceDecimals || *stored[0].Decimals != decimals {
+		t.Errorf("stored mappings = %+v, want decimals %d -> %d", stored, sourceDecimals, decimals)
+	}
+}
+
+// A configured mapping written in lower case is stored checksummed, so it
+// overwrites the row the admin API saved rather than adding a second one.
+func TestLoadTokensChecksumsBeforeSaving(t *testing.T) {
+	tb := newTestBridge(t)
+	mapping := testMapping(18, 18)
+	if err := tb.store.SaveTokenMapping(mapping); err != nil {
+		t.Fatal(err)
+	}
+
+	configured := mapping
+	configured.SourceToken = strings.ToLower(mapping.SourceToken)
+	configured.TargetToken = strings.ToLower(mapping.TargetToken)
+	configured.Symbol = "TKN2"
+	if err := tb.loadTokens([]TokenMapping{configured}); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := tb.store.ListTokenMappings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 1 || stored[0].SourceToken != testToken.Hex() || stored[0].TargetToken != testWrapped.Hex() || stored[0].Symbol != "TKN2" {
+		t.Errorf("stored mappings = %+v, want the one checksummed mapping from config", stored)
+	}
+}
+
+func TestLoadTokensRejectsInvalidMappingBeforeSaving(t *testing.T) {
+	unknownChain := testMapping(18, 18)
+	unknownChain.SourceChain = "bsc"
+	malformedToken := testMapping(18, 18)
+	malformedToken.SourceToken = "0x1234"
+
+	tests := []struct {
+		name    string
+		mapping TokenMapping
+	}{
+		{"unknown chain", unknownChain},
+		{"malformed token", malformedToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tb := newTestBridge(t)
+			if err := tb.loadTokens([]TokenMapping{tt.mapping}); err == nil {
+				t.Fatal("loaded an invalid mapping")
+			}
+			stored, err := tb.store.ListTokenMappings()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(stored) != 0 {
+				t.Errorf("saved %+v", stored)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TransferKey identifies a source-chain lock independently of how the chain
+// names it. Its canonical string is "<chain>:<id>", so keys from different
+// chains can never alias even if their chain-specific ids happen to match.
+type TransferKey struct {
+	Chain string
+	ID    string
+}
+
+// EVMTransferKey keeps the "0x"-prefixed bytes32 hex that BridgeEvent.Nonce
+// has always carried.
+func EVMTransferKey(chain string, nonce [32]byte) TransferKey {
+	return TransferKey{Chain: chain, ID: fmt.Sprintf("0x%x", nonce)}
+}
+
+func SlotTransferKey(chain string, slot uint64, signature string, index uint32) TransferKey {
+	return TransferKey{Chain: chain, ID: fmt.Sprintf("%d/%s/%d", slot, signature, index)}
+}
+
+func HeightTransferKey(chain string, height uint64, txHash string, msgIndex uint32) TransferKey {
+	return TransferKey{Chain: chain, ID: fmt.Sprintf("%d/%s/%d", height, strings.ToUpper(txHash), msgIndex)}
+}
+
+func ParseTransferKey(s string) (TransferKey, error) {
+	chain, id, ok := strings.Cut(s, ":")
+	if !ok || chain == "" || id == "" {
+		return TransferKey{}, fmt.Errorf("malformed transfer key %q", s)
+	}
+	key := TransferKey{Chain: chain, ID: id}
+	if strings.HasPrefix(id, "0x") {
+		if _, err := key.EVMNonce(); err != nil {
+			return TransferKey{}, err
+		}
+		return key, nil
+	}
+	if _, _, _, err := key.Triple(); err != nil {
+		return TransferKey{}, err
+	}
+	return key, nil
+}
+
+func (k TransferKey) String() string {
+	return k.Chain + ":" + k.ID
+}
+
+func (k TransferKey) IsZero() bool {
+	return k.Chain == "" && k.ID == ""
+}
+
+func (k TransferKey) EVMNonce() ([32]byte, error) {
+	var nonce [32]byte
+	raw, err := hex.DecodeString(strings.TrimPrefix(k.ID, "0x"))
+	if err != nil || len(raw) != 32 || !strings.HasPrefix(k.ID, "0x") {
+		return nonce, fmt.Errorf("transfer key %q is not an EVM bytes32 nonce", k.String())
+	}
+	copy(nonce[:], raw)
+	return nonce, nil
+}
+
+// Triple decodes the (slot, signature, index) and (height, txhash, msgIndex)
+// forms used by non-EVM adapters.
+func (k TransferKey) Triple() (uint64, string, uint32, error) {
+	parts := strings.Split(k.ID, "/")
+	if len(parts) != 3 || parts[1] == "" {
+		return 0, "", 0, fmt.Errorf("transfer key %q is not a positional identifier", k.String())
+	}
+	position, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return 0, "", 0, fmt.Errorf("transfer key %q has invalid position: %v", k.String(), err)
+	}
+	index, err := strconv.ParseUint(parts[2], 10, 32)
+	if err != nil {
+		return 0, "", 0, fmt.Errorf("transfer key %q has invalid index: %v", k.String(), err)
+	}
+	return position, parts[1], uint32(index), nil
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	warmupItemTimeout = 30 * time.Second
+	warmupRetryAfter  = "5"
+)
+
+type warmupStep struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+type WarmupItem struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Duration string `json:"duration,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Warmup holds back client-facing traffic until startup work has finished,
+// so nothing is served from a cold cache. Each item either completes or
+// times out; readiness flips once every item has settled either way.
+type Warmup struct {
+	clock Clock
+
+	mu    sync.Mutex
+	items []*WarmupItem
+	ready atomic.Bool
+}
+
+func NewWarmup(clock Clock) *Warmup {
+	return &Warmup{clock: clock}
+}
+
+func (w *Warmup) Run(ctx context.Context, steps []warmupStep) {
+	started := w.clock.Now()
+
+	w.mu.Lock()
+	items := make([]*WarmupItem, len(steps))
+	for i, step := range steps {
+		items[i] = &WarmupItem{Name: step.name, Status: "pending"}
+	}
+	w.items = items
+	w.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for i, step := range steps {
+		wg.Add(1)
+		go func(item *WarmupItem, step warmupStep) {
+			defer wg.Done()
+			w.runStep(ctx, item, step)
+		}(items[i], step)
+	}
+	wg.Wait()
+
+	w.ready.Store(true)
+	log.Printf("Warm-up finished in %s, now serving traffic", Since(w.clock, started).Round(time.Millisecond))
+}
+
+func (w *Warmup) runStep(ctx context.Context, item *WarmupItem, step warmupStep) {
+	stepCtx, cancel := WithTimeout(ctx, w.clock, warmupItemTimeout)
+	defer cancel()
+
+	started := w.clock.Now()
+	err := step.run(stepCtx)
+	elapsed := Since(w.clock, started).Round(time.Millisecond)
+
+	status := "ok"
+	switch {
+	case err != nil && context.Cause(stepCtx) == context.DeadlineExceeded:
+		status = "timeout"
+	case err != nil:
+		status = "failed"
+	}
+
+	w.mu.Lock()
+	item.Status = status
+	item.Duration = elapsed.String()
+	if err != nil {
+		item.Error = err.Error()
+	}
+	w.mu.Unlock()
+
+	if err != nil {
+		log.Printf("Warm-up %s %s after %s: %v", step.name, status, elapsed, err)
+		return
+	}
+	log.Printf("Warm-up %s done in %s", step.name, elapsed)
+}
+
+func (w *Warmup) Ready() bool {
+	return w.ready.Load()
+}
+
+func (w *Warmup) Items() []WarmupItem {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	items := make([]WarmupItem, len(w.items))
+	for i, item := range w.items {
+		items[i] = *item
+	}
+	return items
+}
+
+// Gate answers 503 with Retry-After until warm-up has finished.
+func (w *Warmup) Gate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if !w.Ready() {
+			rw.Header().Set("Retry-After", warmupRetryAfter)
+			http.Error(rw, "bridge service is warming up", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(rw, r)
+	})
+}
+
+func (w *Warmup) handleReady(rw http.ResponseWriter, r *http.Request) {
+	ready := w.Ready()
+	rw.Header().Set("Content-Type", "application/json")
+	if !ready {
+		rw.Header().Set("Retry-After", warmupRetryAfter)
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(rw).Encode(map[string]interface{}{
+		"ready": ready,
+		"items": w.Items(),
+	})
+}
+
+// registerStreamRoutes adds the live event streams, held back until warm-up
+// has finished, and the readiness probe reporting its progress.
+func (bs *BridgeService) registerStreamRoutes(router *mux.Router) {
+	router.Handle("/ws", bs.auth.RequireUpgrade(roleRead)(bs.warmup.Gate(http.HandlerFunc(bs.handleWebSocket))))
+	router.Handle("/events", bs.auth.Require(roleRead)(bs.warmup.Gate(http.HandlerFunc(bs.handleSSE)))).Methods(http.MethodGet)
+	router.HandleFunc("/healthz/ready", bs.warmup.handleReady)
+}
+
+func (bs *BridgeService) warmupSteps() []warmupStep {
+	steps := []warmupStep{{
+		// Decoding every unfinished row catches a corrupt store before
+		// replay or any client sees it.
+		name: "store consistency",
+		run: func(ctx context.Context) error {
+			_, err := bs.store.ListPending()
+			return err
+		},
+	}}
+
+	for _, chainName := range bs.chainNames() {
+		chainName := chainName
+		steps = append(steps, warmupStep{
+			name: "head cache " + chainName,
+			run: func(ctx context.Context) error {
+				return bs.waitForHead(ctx, chainName)
+			},
+		})
+	}
+	return steps
+}
+
+func (bs *BridgeService) waitForHead(ctx context.Context, chainName string) error {
+	heads, unsubscribe := bs.heads.Subscribe(chainName)
+	defer unsubscribe()
+
+	if _, fresh := bs.heads.GetHead(chainName); fresh {
+		return nil
+	}
+	select {
+	case <-heads:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("no head received from %s: %v", chainName, context.Cause(ctx))
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+type readiness struct {
+	Ready bool         `json:"ready"`
+	Items []WarmupItem `json:"items"`
+}
+
+func (r readiness) status(name string) string {
+	for _, item := range r.Items {
+		if item.Name == name {
+			return item.Status
+		}
+	}
+	return ""
+}
+
+// Until every warm-up step has finished or timed out, the client-facing
+// routes answer 503 with Retry-After and the readiness probe shows where
+// each step stands.
+func TestWarmupHoldsTrafficUntilStepsSettle(t *testing.T) {
+	tb := newTestBridge(t)
+	tb.auth = newTestAuthenticator()
+	router := mux.NewRouter()
+	tb.registerAPIRoutes(router)
+	tb.registerStreamRoutes(router)
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+testReadKey)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	probe := func() readiness {
+		t.Helper()
+		rec := serve("/healthz/ready")
+		var got readiness
+		if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Ready != (rec.Code == http.StatusOK) {
+			t.Errorf("ready %v with HTTP %d", got.Ready, rec.Code)
+		}
+		return got
+	}
+	assertHeld := func() {
+		t.Helper()
+		for _, path := range []string{"/api/transactions", "/ws", "/events", "/healthz/ready"} {
+			rec := serve(path)
+			if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != warmupRetryAfter {
+				t.Errorf("%s: HTTP %d, Retry-After %q; want 503 with Retry-After %s", path, rec.Code, rec.Header().Get("Retry-After"), warmupRetryAfter)
+			}
+		}
+	}
+
+	// Neither chain's head cache runs yet, so both head steps wait.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	steps := tb.warmupSteps()
+	done := make(chan struct{})
+	go func() {
+		tb.warmup.Run(ctx, steps)
+		close(done)
+	}()
+	tb.clock.BlockUntil(len(steps))
+	tb.waitUntil("the store check", func() bool { return probe().status("store consistency") == "ok" })
+	assertHeld()
+	if got := probe(); got.status("head cache "+testSourceChain) != "pending" || got.status("head cache "+testTargetChain) != "pending" {
+		t.Errorf("items = %+v, want both head caches pending", got.Items)
+	}
+
+	// The source chain's head arrives; the target's never does.
+	tb.followHeads(ctx)
+	tb.mocks[testSourceChain].Mine(1)
+	tb.waitUntil("the source head", func() bool { return probe().status("head cache "+testSourceChain) == "ok" })
+	assertHeld()
+	if got := probe().status("head cache " + testTargetChain); got != "pending" {
+		t.Errorf("target head cache %s, want pending", got)
+	}
+
+	tb.clock.Advance(warmupItemTimeout)
+	<-done
+	got := probe()
+	if !got.Ready {
+		t.Fatalf("not ready after every step settled: %+v", got.Items)
+	}
+	if status := got.status("head cache " + testTargetChain); status != "timeout" {
+		t.Errorf("target head cache %s, want timeout", status)
+	}
+	if rec := serve("/api/transactions"); rec.Code != http.StatusOK {
+		t.Errorf("/api/transactions after warm-up: HTTP %d, want 200", rec.Code)
+	}
+}
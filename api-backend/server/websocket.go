@@ -0,0 +1,123 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// WebSocketConfig tunes /ws keepalive. A peer that answers neither pings
+// nor sends anything for PongTimeoutSeconds is dropped, as is one whose send
+// buffer stays full for EvictAfterSeconds. Browsers may only connect from
+// AllowedOrigins ("*" for any); without it, from pages on the same host.
+type WebSocketConfig struct {
+	PingIntervalSeconds int      `json:"pingIntervalSeconds" yaml:"pingIntervalSeconds"`
+	PongTimeoutSeconds  int      `json:"pongTimeoutSeconds" yaml:"pongTimeoutSeconds"`
+	WriteTimeoutSeconds int      `json:"writeTimeoutSeconds" yaml:"writeTimeoutSeconds"`
+	EvictAfterSeconds   int      `json:"evictAfterSeconds" yaml:"evictAfterSeconds"`
+	AllowedOrigins      []string `json:"allowedOrigins" yaml:"allowedOrigins"`
+}
+
+func (c *WebSocketConfig) applyDefaults() {
+	if c.PingIntervalSeconds == 0 {
+		c.PingIntervalSeconds = 30
+	}
+	if c.PongTimeoutSeconds == 0 {
+		c.PongTimeoutSeconds = 60
+	}
+	if c.WriteTimeoutSeconds == 0 {
+		c.WriteTimeoutSeconds = 10
+	}
+	if c.EvictAfterSeconds == 0 {
+		c.EvictAfterSeconds = 10
+	}
+}
+
+// handleWebSocket streams events to a client. Query parameters set the
+// initial filter, as on /events; subscribe messages replace it.
+func (bs *BridgeService) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	filter, err := bs.filterFromQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	conn, err := bs.wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("WebSocket upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	client := bs.hub.Register()
+	defer bs.hub.Unregister(client)
+	if filter != nil {
+		client.filter.Store(filter)
+	}
+
+	websocketConnections.Inc()
+	log.Println("New WebSocket connection established")
+
+	go bs.readPump(conn, client)
+	bs.writePump(conn, client)
+}
+
+// readPump handles subscribe messages and pongs until the peer closes or
+// goes quiet; either way unregistering closes client.send and ends the
+// write pump. Socket deadlines are wall-clock, not bs.clock.
+func (bs *BridgeService) readPump(conn *websocket.Conn, client *hubClient) {
+	defer bs.hub.Unregister(client)
+
+	pongTimeout := time.Duration(bs.websocket.PongTimeoutSeconds) * time.Second
+	extend := func() { conn.SetReadDeadline(time.Now().Add(pongTimeout)) }
+	extend()
+	conn.SetPongHandler(func(string) error {
+		extend()
+		return nil
+	})
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Printf("WebSocket client dropped: %v", err)
+			}
+			return
+		}
+		extend()
+		bs.hub.Send(client, bs.handleSubscription(client, message))
+	}
+}
+
+// writePump is the connection's only writer: it sends queued frames and
+// pings, and returns once client.send is closed or a write fails.
+func (bs *BridgeService) writePump(conn *websocket.Conn, client *hubClient) {
+	writeTimeout := time.Duration(bs.websocket.WriteTimeoutSeconds) * time.Second
+	ticker := bs.clock.NewTicker(time.Duration(bs.websocket.PingIntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case frame, ok := <-client.send:
+			if !ok {
+				conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+					time.Now().Add(writeTimeout))
+				return
+			}
+			if event, ok := frame.(sequencedEvent); ok {
+				frame = event.Event
+			}
+			conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if err := conn.WriteJSON(frame); err != nil {
+				log.Printf("WebSocket write error: %v", err)
+				return
+			}
+		case <-ticker.C():
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeTimeout)); err != nil {
+				log.Printf("WebSocket ping failed: %v", err)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Every websocket client sees every event while the pipeline still handles
+// each one itself: both clients get all the locks and their mints, and each
+// lock is minted.
+func TestWebSocketClientsShareEventsWithPipeline(t *testing.T) {
+	const locks = 10
+	tb := newTestBridge(t)
+	tb.websocket.applyDefaults()
+	server := httptest.NewServer(http.HandlerFunc(tb.handleWebSocket))
+	defer server.Close()
+
+	var conns []*websocket.Conn
+	for i := 0; i < 2; i++ {
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conns = append(conns, conn)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for tb.hub.Count() != len(conns) {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d clients registered, want %d", tb.hub.Count(), len(conns))
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	type seen struct{ locked, minted map[string]bool }
+	results := make([]chan seen, len(conns))
+	for i, conn := range conns {
+		results[i] = make(chan seen, 1)
+		go func(conn *websocket.Conn, result chan<- seen) {
+			got := seen{locked: make(map[string]bool), minted: make(map[string]bool)}
+			defer func() { result <- got }()
+			conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+			for len(got.minted) < locks {
+				var event BridgeEvent
+				if err := conn.ReadJSON(&event); err != nil {
+					return
+				}
+				switch {
+				case event.Type == "lock":
+					got.locked[event.ID] = true
+				case event.Type == "mint" && event.Status == StatusCompleted:
+					got.minted[event.ID] = true
+				}
+			}
+		}(conn, results[i])
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tb.runLoops(ctx, tb.TrackConfirmations)
+	var ids []string
+	for i := 0; i < locks; i++ {
+		vLog := tb.emit(testSourceChain, tb.lockLog(uint64(5+i), byte(i+1), 1000))
+		ids = append(ids, lockEventID(testSourceChain, vLog))
+	}
+	tb.mocks[testSourceChain].Mine(testConfirmations)
+	tb.advanceUntil(confirmationPollInterval, func() bool {
+		for _, id := range ids {
+			if tb.status(id) != StatusCompleted {
+				return false
+			}
+		}
+		return true
+	})
+
+	for i, result := range results {
+		got := <-result
+		for _, id := range ids {
+			if !got.locked[id] || !got.minted[id] {
+				t.Errorf("client %d saw lock %v and mint %v of %s, want both", i, got.locked[id], got.minted[id], id)
+			}
+		}
+	}
+	if mints := tb.minted(testTargetChain); len(mints) != locks {
+		t.Errorf("minted %d times, want once per lock", len(mints))
+	}
+}
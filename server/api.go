@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+var txHashPattern = regexp.MustCompile(`^0x[0-9a-fA-F]{64}$`)
+
+// transactionView is a stored event as served by the read API.
+type transactionView struct {
+	BridgeEvent
+	Confirmations uint64 `json:"confirmations"`
+}
+
+func (bs *BridgeService) transactionView(event BridgeEvent) transactionView {
+	view := transactionView{BridgeEvent: event}
+	if head, _ := bs.heads.GetHead(event.FromChain); head != nil && head.Number.Uint64() >= event.BlockNumber {
+		view.Confirmations = head.Number.Uint64() - event.BlockNumber + 1
+	}
+	return view
+}
+
+func (bs *BridgeService) transactionViews(events []BridgeEvent) []transactionView {
+	views := make([]transactionView, 0, len(events))
+	for _, event := range events {
+		views = append(views, bs.transactionView(event))
+	}
+	return views
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+func (bs *BridgeService) handleGetTransaction(w http.ResponseWriter, r *http.Request) {
+	event, err := bs.store.GetByID(mux.Vars(r)["id"])
+	if errors.Is(err, ErrEventNotFound) {
+		writeError(w, http.StatusNotFound, "transaction not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to load transaction: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to load transaction")
+		return
+	}
+	writeJSON(w, http.StatusOK, bs.transactionView(*event))
+}
+
+// handleGetTransactionsByTx returns every bridge event emitted by a source
+// transaction; one transaction can lock more than once.
+func (bs *BridgeService) handleGetTransactionsByTx(w http.ResponseWriter, r *http.Request) {
+	txHash := mux.Vars(r)["txHash"]
+	if !txHashPattern.MatchString(txHash) {
+		writeError(w, http.StatusBadRequest, "malformed transaction hash")
+		return
+	}
+
+	events, err := bs.store.GetByTxHash(common.HexToHash(txHash).Hex())
+	if err != nil {
+		log.Printf("Failed to load transactions: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to load transactions")
+		return
+	}
+	if len(events) == 0 {
+		writeError(w, http.StatusNotFound, "transaction not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"transactions": bs.transactionViews(events)})
+}
+
+func (bs *BridgeService) handleListTransactions(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := EventFilter{FromChain: query.Get("fromChain"), Limit: defaultPageSize}
+
+	if sender := query.Get("sender"); sender != "" {
+		if !common.IsHexAddress(sender) {
+			writeError(w, http.StatusBadRequest, "malformed sender address")
+			return
+		}
+		// Senders are stored checksummed; lowercase input matches too.
+		filter.Sender = common.HexToAddress(sender).Hex()
+	}
+
+	switch status := query.Get("status"); status {
+	case "":
+	case "pending":
+		filter.Statuses = pendingStatuses
+	default:
+		if !knownStatus(TransferStatus(status)) {
+			writeError(w, http.StatusBadRequest, "unknown status")
+			return
+		}
+		filter.Statuses = []TransferStatus{TransferStatus(status)}
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxPageSize {
+			writeError(w, http.StatusBadRequest, "limit must be between 1 and 200")
+			return
+		}
+		filter.Limit = n
+	}
+
+	// A corridor query walks terminal transfers in sequence order so a
+	// consumer can fill gaps from the last sequence number it processed.
+	if corridor := query.Get("corridor"); corridor != "" {
+		var minSeq uint64
+		if value := query.Get("minSeq"); value != "" {
+			n, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "malformed minSeq")
+				return
+			}
+			minSeq = n
+		}
+		events, err := bs.store.ListCorridor(corridor, minSeq, filter.Limit)
+		if err != nil {
+			log.Printf("Failed to list corridor: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to list transactions")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"transactions": bs.transactionViews(events)})
+		return
+	}
+
+	if cursor := query.Get("cursor"); cursor != "" {
+		decoded, err := decodeCursor(cursor)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "malformed cursor")
+			return
+		}
+		filter.Cursor = decoded
+	}
+
+	events, next, err := bs.store.ListEvents(filter)
+	if err != nil {
+		log.Printf("Failed to list transactions: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to list transactions")
+		return
+	}
+
+	response := map[string]interface{}{"transactions": bs.transactionViews(events)}
+	if next != nil {
+		response["nextCursor"] = encodeCursor(*next)
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func encodeCursor(cursor EventCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(value string) (*EventCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	var cursor EventCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	if cursor.ID == "" {
+		return nil, errors.New("cursor has no id")
+	}
+	return &cursor, nil
+}
+
+func (bs *BridgeService) registerAPIRoutes(router *mux.Router) {
+	api := router.PathPrefix("/api").Subrouter()
+	api.Use(bs.auth.Require(roleRead), bs.warmup.Gate)
+	api.HandleFunc("/transactions", bs.handleListTransactions).Methods(http.MethodGet)
+	api.HandleFunc("/transactions/by-tx/{txHash}", bs.handleGetTransactionsByTx).Methods(http.MethodGet)
+	api.HandleFunc("/transactions/{id}", bs.handleGetTransaction).Methods(http.MethodGet)
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestProcessLockEventRecordsLock(t *testing.T) {
+	tb := newTestBridge(t)
+	vLog := tb.emit(testSourceChain, tb.lockLog(5, 1, 1000))
+
+	id := lockEventID(testSourceChain, vLog)
+	event, err := tb.store.GetByID(id)
+	if err != nil {
+		t.Fatalf("lock was not stored: %v", err)
+	}
+	if event.Status != StatusPendingConfirmation {
+		t.Errorf("status = %s, want %s", event.Status, StatusPendingConfirmation)
+	}
+	if event.FromChain != testSourceChain || event.ToChain != testTargetChain {
+		t.Errorf("corridor = %s -> %s, want %s -> %s", event.FromChain, event.ToChain, testSourceChain, testTargetChain)
+	}
+	if event.Amount != "1000" || event.Token != testToken.Hex() || event.Sender != testSender.Hex() || event.Recipient != testRecipient.Hex() {
+		t.Errorf("decoded %s of %s from %s to %s", event.Amount, event.Token, event.Sender, event.Recipient)
+	}
+	if want := fmt.Sprintf("%s:0x%064x", testSourceChain, 1); event.TransferKey != want {
+		t.Errorf("transfer key = %s, want %s", event.TransferKey, want)
+	}
+	if event.BlockNumber != 5 || event.BlockHash != vLog.BlockHash.Hex() {
+		t.Errorf("block = %d %s, want 5 %s", event.BlockNumber, event.BlockHash, vLog.BlockHash.Hex())
+	}
+
+	select {
+	case queued := <-tb.eventChan:
+		if queued.ID != id {
+			t.Errorf("queued %s, want %s", queued.ID, id)
+		}
+	default:
+		t.Fatal("lock was not queued")
+	}
+	checkpoint, found, err := tb.store.GetCheckpoint(testSourceChain)
+	if err != nil || !found || checkpoint.Block != 5 {
+		t.Errorf("checkpoint = %+v (found %v, err %v), want block 5", checkpoint, found, err)
+	}
+}
+
+func TestInitiateMintSendsMint(t *testing.T) {
+	tb := newTestBridge(t)
+	vLog := tb.emit(testSourceChain, tb.lockLog(5, 7, 2500))
+	tb.drain()
+	if sent := tb.mocks[testTargetChain].Sent(); len(sent) != 0 {
+		t.Fatalf("minted before confirmation: %d transactions", len(sent))
+	}
+
+	tb.confirm()
+
+	calls := tb.minted(testTargetChain)
+	if len(calls) != 1 {
+		t.Fatalf("sent %d settlements, want 1", len(calls))
+	}
+	call := calls[0]
+	if call.Method != "mint" || call.Token != testWrapped || call.Recipient != testRecipient || call.Amount.Cmp(big.NewInt(2500)) != 0 || call.Nonce[31] != 7 {
+		t.Errorf("sent %s(%s, %s, %s, %x)", call.Method, call.Token.Hex(), call.Recipient.Hex(), call.Amount, call.Nonce)
+	}
+	if *call.Tx.To() != testBridges[testTargetChain] {
+		t.Errorf("mint sent to %s, want the %s bridge", call.Tx.To().Hex(), testTargetChain)
+	}
+
+	id := lockEventID(testSourceChain, vLog)
+	event, err := tb.store.GetByID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Status != StatusCompleted || event.CorridorSeq != 1 {
+		t.Errorf("status %s with corridor seq %d, want completed with 1", event.Status, event.CorridorSeq)
+	}
+}
+
+func TestListenToChainBackfillsAndFollows(t *testing.T) {
+	tb := newTestBridge(t)
+	if err := tb.store.SaveCheckpoint(testSourceChain, Checkpoint{Block: 1, LogIndex: wholeBlock}); err != nil {
+		t.Fatal(err)
+	}
+	missed := tb.lockLog(3, 1, 100)
+	tb.mocks[testSourceChain].AddLogs(missed)
+
+	stop := tb.listen(testSourceChain)
+	defer stop()
+	tb.waitForEvent(lockEventID(testSourceChain, tb.chainLog(testSourceChain, missed.TxHash)))
+
+	live := tb.lockLog(6, 2, 200)
+	tb.mocks[testSourceChain].AddLogs(live)
+	tb.waitForEvent(lockEventID(testSourceChain, tb.chainLog(testSourceChain, live.TxHash)))
+}
+
+func TestListenToChainPollsWithoutSubscriptions(t *testing.T) {
+	tb := newTestBridge(t)
+	tb.mocks[testSourceChain].NoSubscriptions = true
+
+	stop := tb.listen(testSourceChain)
+	defer stop()
+	// With no checkpoint polling starts at the head; wait until it has
+	// recorded one and is waiting for the next round.
+	tb.clock.BlockUntil(1)
+
+	vLog := tb.lockLog(4, 1, 100)
+	tb.mocks[testSourceChain].AddLogs(vLog)
+	tb.clock.Advance(time.Duration(defaultPollIntervalSeconds) * time.Second)
+	tb.waitForEvent(lockEventID(testSourceChain, tb.chainLog(testSourceChain, vLog.TxHash)))
+}
+
+// listen runs ListenToChain until the returned func is called.
+func (tb *testBridge) listen(chainName string) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		tb.ListenToChain(ctx, chainName)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// waitForEvent waits for a goroutine under test to store event id.
+func (tb *testBridge) waitForEvent(id string) *BridgeEvent {
+	tb.t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		event, err := tb.store.GetByID(id)
+		if err == nil {
+			return event
+		}
+		if time.Now().After(deadline) {
+			tb.t.Fatalf("%s was not recorded: %v", id, err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSameLogTwiceMintsOnce(t *testing.T) {
+	tb := newTestBridge(t)
+	vLog := tb.emit(testSourceChain, tb.lockLog(5, 1, 1000))
+	// The backfill and the subscription both deliver it.
+	tb.processLog(testSourceChain, vLog)
+	tb.drain()
+	tb.confirm()
+	tb.confirm()
+
+	if mints := tb.minted(testTargetChain); len(mints) != 1 {
+		t.Errorf("minted %d times, want once", len(mints))
+	}
+	if status := tb.status(lockEventID(testSourceChain, vLog)); status != StatusCompleted {
+		t.Errorf("status = %s, want completed", status)
+	}
+}
+
+func TestSameNonceInTwoLogsMintsOnce(t *testing.T) {
+	tb := newTestBridge(t)
+	first := tb.emit(testSourceChain, tb.lockLog(5, 1, 1000))
+	second := tb.emit(testSourceChain, tb.lockLog(6, 1, 1000))
+	tb.drain()
+	tb.confirm()
+
+	if mints := tb.minted(testTargetChain); len(mints) != 1 {
+		t.Errorf("minted %d times, want once", len(mints))
+	}
+	if got := tb.duplicates.Load(); got != 1 {
+		t.Errorf("counted %d duplicates, want 1", got)
+	}
+	if _, err := tb.store.GetByID(lockEventID(testSourceChain, second)); err == nil {
+		t.Error("recorded the second log of the nonce")
+	}
+	if status := tb.status(lockEventID(testSourceChain, first)); status != StatusCompleted {
+		t.Errorf("status = %s, want completed", status)
+	}
+}
+
+// A relayer that stopped after claiming a transfer but before recording the
+// outcome must neither mint blindly nor drop the transfer as a duplicate.
+func TestSettlementInterruptedAfterClaimIsRetried(t *testing.T) {
+	tb := newTestBridge(t)
+	vLog := tb.emit(testSourceChain, tb.lockLog(5, 1, 1000))
+	id := lockEventID(testSourceChain, vLog)
+	tb.drain()
+	event, err := tb.store.GetByID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first, err := tb.store.MarkTransferProcessed(event.TransferKey, id); err != nil || !first {
+		t.Fatalf("claim = %v, %v", first, err)
+	}
+
+	tb.confirm()
+	if status := tb.status(id); status != StatusRetrying {
+		t.Fatalf("status = %s, want retrying", status)
+	}
+	if got := tb.duplicates.Load(); got != 0 {
+		t.Errorf("counted the event's own claim as %d duplicates", got)
+	}
+	if mints := tb.minted(testTargetChain); len(mints) != 0 {
+		t.Fatalf("minted %d times before the retry", len(mints))
+	}
+
+	tb.retryAfter(tb.retry.backoff(1))
+	if mints := tb.minted(testTargetChain); len(mints) != 1 {
+		t.Errorf("minted %d times, want once", len(mints))
+	}
+	if status := tb.status(id); status != StatusCompleted {
+		t.Errorf("status = %s, want completed", status)
+	}
+}
+
+// A mint broadcast just before the relayer stopped is found after the
+// restart: its nonce stays held and it is either picked up once mined or
+// replaced under that nonce, never sent again beside it.
+func TestSettlementBroadcastBeforeRestartIsNotSentAgain(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		mined bool
+	}{
+		{"mined after the restart", true},
+		{"still pending", false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "bridge.db")
+			before := newTestBridgeWithStore(t, newTestStore(t, path))
+			target := before.mocks[testTargetChain]
+			target.Hold = true
+			vLog := before.lockLog(5, 1, 1000)
+			id := lockEventID(testSourceChain, before.emit(testSourceChain, vLog))
+			before.drain()
+			before.mocks[testSourceChain].Mine(testConfirmations)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			before.runLoops(ctx, before.TrackConfirmations)
+			before.advanceUntil(confirmationPollInterval, func() bool { return len(target.Sent()) == 1 })
+			cancel()
+			// The relayer stops with the mint out and its outcome unknown.
+			before.db.Close()
+
+			after := newTestBridgeWithStore(t, newTestStore(t, path))
+			chain, _ := after.chains.GetChain(testTargetChain)
+			after.chains.Register(chain, target)
+			after.mocks[testTargetChain] = target
+			after.mocks[testSourceChain].AddLogs(vLog)
+			after.syncNonces()
+			after.replayPending()
+			after.drain()
+			after.confirm()
+			if status := after.status(id); status != StatusRetrying {
+				t.Fatalf("status = %s after restart, want retrying", status)
+			}
+			after.assertNextNonce(1)
+
+			target.Hold = false
+			if tt.mined {
+				target.MineHeld()
+			}
+			after.retryAfter(after.retry.backoff(1))
+			if status := after.status(id); status != StatusCompleted {
+				t.Fatalf("status = %s, want completed", status)
+			}
+			// Only a mint still pending is replaced.
+			want := 1
+			if !tt.mined {
+				want = 2
+			}
+			sent := target.Sent()
+			if len(sent) != want {
+				t.Errorf("sent %d mints, want %d", len(sent), want)
+			}
+			for _, tx := range sent {
+				if tx.Nonce() != 0 {
+					t.Errorf("mint %s sent with nonce %d, want both on nonce 0", tx.Hash().Hex(), tx.Nonce())
+				}
+			}
+		})
+	}
+}
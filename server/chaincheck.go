@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	onCheckFailureRefuse  = "refuse"
+	onCheckFailureDegrade = "degrade"
+)
+
+// ChainChecksConfig sets what happens when a chain fails its startup checks:
+// "refuse" stops the service from starting, "degrade" leaves the chain out
+// so the others can run.
+type ChainChecksConfig struct {
+	OnFailure      string `json:"onFailure" yaml:"onFailure"`
+	TimeoutSeconds int    `json:"timeoutSeconds" yaml:"timeoutSeconds"`
+}
+
+func (c *ChainChecksConfig) applyDefaults() {
+	if c.OnFailure == "" {
+		c.OnFailure = onCheckFailureRefuse
+	}
+	if c.TimeoutSeconds == 0 {
+		c.TimeoutSeconds = 10
+	}
+}
+
+func (c ChainChecksConfig) validate() error {
+	if c.OnFailure != onCheckFailureRefuse && c.OnFailure != onCheckFailureDegrade {
+		return fmt.Errorf("chainChecks: onFailure must be %q or %q", onCheckFailureRefuse, onCheckFailureDegrade)
+	}
+	if c.TimeoutSeconds < 1 {
+		return fmt.Errorf("chainChecks: timeoutSeconds must be positive")
+	}
+	return nil
+}
+
+// ChainCheck is the outcome of a chain's startup checks: that the endpoint
+// serves the configured chain ID and that the bridge contract has code.
+type ChainCheck struct {
+	ExpectedChainID uint64    `json:"expectedChainId"`
+	ChainID         uint64    `json:"chainId,omitempty"`
+	ContractCode    bool      `json:"contractCode"`
+	Passed          bool      `json:"passed"`
+	Degraded        bool      `json:"degraded,omitempty"`
+	Error           string    `json:"error,omitempty"`
+	CheckedAt       time.Time `json:"checkedAt"`
+}
+
+// ChainChecks keeps the latest check of each chain for /status.
+type ChainChecks struct {
+	mu     sync.RWMutex
+	checks map[string]ChainCheck
+}
+
+func NewChainChecks() *ChainChecks {
+	return &ChainChecks{checks: make(map[string]ChainCheck)}
+}
+
+func (c *ChainChecks) record(chainName string, check ChainCheck) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks[chainName] = check
+}
+
+func (c *ChainChecks) Snapshot() map[string]ChainCheck {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	out := make(map[string]ChainCheck, len(c.checks))
+	for name, check := range c.checks {
+		out[name] = check
+	}
+	return out
+}
+
+// checkChain confirms that client is connected to chain's network and that
+// its bridge contract is deployed there, so a misconfigured endpoint or
+// contract address can't go unnoticed.
+func (bs *BridgeService) checkChain(chain ChainConfig, client ChainClient) ChainCheck {
+	check := ChainCheck{ExpectedChainID: chain.ChainID, CheckedAt: bs.clock.Now()}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(bs.chainChecksCfg.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	chainID, err := client.ChainID(ctx)
+	if err != nil {
+		rpcErrors.WithLabelValues(chain.Name, "chain_id").Inc()
+		check.Error = fmt.Sprintf("failed to read chain ID: %v", err)
+		return check
+	}
+	check.ChainID = chainID.Uint64()
+	if !chainID.IsUint64() || check.ChainID != chain.ChainID {
+		check.Error = fmt.Sprintf("endpoint serves chain ID %s, expected %d", chainID, chain.ChainID)
+		return check
+	}
+
+	code, err := client.CodeAt(ctx, common.HexToAddress(chain.Contract), nil)
+	if err != nil {
+		rpcErrors.WithLabelValues(chain.Name, "code_at").Inc()
+		check.Error = fmt.Sprintf("failed to read bridge contract code: %v", err)
+		return check
+	}
+	if len(code) == 0 {
+		check.Error = fmt.Sprintf("no contract deployed at %s", common.HexToAddress(chain.Contract).Hex())
+		return check
+	}
+	check.ContractCode = true
+	check.Passed = true
+	return check
+}
+
+// verifyChain runs chain's startup checks and records the result. It
+// returns an error if the service must not start, and false if the chain
+// should be left out.
+func (bs *BridgeService) verifyChain(chain ChainConfig, client ChainClient) (bool, error) {
+	check := bs.checkChain(chain, client)
+	if check.Passed {
+		bs.chainChecks.record(chain.Name, check)
+		log.Printf("Chain %s passed startup checks: chain ID %d, bridge contract deployed", chain.Name, check.ChainID)
+		return true, nil
+	}
+	if bs.chainChecksCfg.OnFailure == onCheckFailureRefuse {
+		return false, fmt.Errorf("chain %s failed startup checks: %s", chain.Name, check.Error)
+	}
+	check.Degraded = true
+	bs.chainChecks.record(chain.Name, check)
+	log.Printf("ALERT: chain %s failed startup checks and is degraded, not listening or settling on it: %s", chain.Name, check.Error)
+	return false, nil
+}
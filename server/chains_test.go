@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/AIhangzhou56/YHGS-Bridge/server/testutil"
+)
+
+// Chains are registered and removed while listeners, settlement and handlers
+// read the registry; run with -race.
+func TestChainRegistryConcurrentAccess(t *testing.T) {
+	registry := NewChainRegistry()
+	registry.Register(ChainConfig{Name: testSourceChain, Contract: testBridges[testSourceChain].Hex()}, testutil.NewMockChain())
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			name := fmt.Sprintf("chain-%d", w)
+			for i := 0; i < 200; i++ {
+				registry.Register(ChainConfig{Name: name, ChainID: uint64(i)}, testutil.NewMockChain())
+				if i%2 == 0 && !registry.Remove(name) {
+					t.Errorf("%s vanished before its own Remove", name)
+					return
+				}
+			}
+		}(w)
+	}
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				if chain, ok := registry.GetChain(testSourceChain); !ok || chain.Name != testSourceChain {
+					t.Errorf("lost %s while other chains changed", testSourceChain)
+					return
+				}
+				if contract, ok := registry.GetContract(testSourceChain); !ok || contract != testBridges[testSourceChain] {
+					t.Errorf("contract of %s = %s", testSourceChain, contract.Hex())
+					return
+				}
+				names := registry.Names()
+				for j := 1; j < len(names); j++ {
+					if names[j-1] >= names[j] {
+						t.Errorf("names out of order: %v", names)
+						return
+					}
+				}
+				for name, chain := range registry.Configs() {
+					if chain.Name != name {
+						t.Errorf("config of %s is named %s", name, chain.Name)
+						return
+					}
+				}
+				for name, client := range registry.Clients() {
+					if client == nil {
+						t.Errorf("%s has no client", name)
+						return
+					}
+				}
+				registry.Has("chain-0")
+				registry.GetClient("chain-1")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if names := registry.Names(); len(names) != 5 {
+		t.Errorf("registered %v, want %s and the four churned chains", names, testSourceChain)
+	}
+	if chain, _ := registry.GetChain("chain-0"); chain.ChainID != 199 {
+		t.Errorf("chain-0 kept registration %d, want the last one", chain.ChainID)
+	}
+}
+
+// Locks arriving concurrently, some delivered twice, each mint once with a
+// nonce of their own, while another chain is added and removed; run with
+// -race.
+func TestConcurrentLocksSettleOnceEach(t *testing.T) {
+	const writers, perWriter = 4, 10
+	tb := newTestBridge(t)
+	var logs [writers][]types.Log
+	for w := range logs {
+		for i := 0; i < perWriter; i++ {
+			nonce := byte(w*perWriter + i + 1)
+			logs[w] = append(logs[w], tb.lockLog(uint64(3+i), nonce, int64(nonce)*100))
+		}
+	}
+
+	// Backfill from a checkpoint catches locks added before the listener has
+	// subscribed.
+	if err := tb.store.SaveCheckpoint(testSourceChain, Checkpoint{Block: 1, LogIndex: wholeBlock}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stop := tb.listen(testSourceChain)
+	defer stop()
+	tb.runLoops(ctx, tb.TrackConfirmations)
+
+	churned := make(chan struct{})
+	go func() {
+		defer close(churned)
+		for i := 0; i < 100; i++ {
+			tb.chains.Register(ChainConfig{Name: "bsc", ChainID: 56}, testutil.NewMockChain())
+			tb.chainSet()
+			tb.chains.Remove("bsc")
+		}
+	}()
+	var wg sync.WaitGroup
+	for w := range logs {
+		wg.Add(1)
+		go func(batch []types.Log) {
+			defer wg.Done()
+			for i, vLog := range batch {
+				tb.mocks[testSourceChain].AddLogs(vLog)
+				if i%3 == 0 {
+					tb.mocks[testSourceChain].AddLogs(vLog)
+				}
+			}
+		}(logs[w])
+	}
+	wg.Wait()
+	<-churned
+
+	var ids []string
+	for _, batch := range logs {
+		for _, vLog := range batch {
+			id := lockEventID(testSourceChain, tb.chainLog(testSourceChain, vLog.TxHash))
+			tb.waitForEvent(id)
+			ids = append(ids, id)
+		}
+	}
+	tb.mocks[testSourceChain].Mine(testConfirmations)
+	tb.advanceUntil(confirmationPollInterval, func() bool {
+		for _, id := range ids {
+			if tb.status(id) != StatusCompleted {
+				return false
+			}
+		}
+		return true
+	})
+	tb.settlements.Wait()
+
+	mints := tb.minted(testTargetChain)
+	if len(mints) != writers*perWriter {
+		t.Fatalf("minted %d times, want %d", len(mints), writers*perWriter)
+	}
+	transfers := make(map[[32]byte]bool)
+	txNonces := make(map[uint64]bool)
+	for _, mint := range mints {
+		if transfers[mint.Nonce] {
+			t.Errorf("transfer %x minted twice", mint.Nonce)
+		}
+		transfers[mint.Nonce] = true
+		if txNonces[mint.Tx.Nonce()] {
+			t.Errorf("relayer nonce %d used twice", mint.Tx.Nonce())
+		}
+		txNonces[mint.Tx.Nonce()] = true
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is the service's only source of time. Background loops schedule
+// through it so they can run against a FakeClock instead of waiting.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (realClock) NewTicker(d time.Duration) Ticker       { return realTicker{time.NewTicker(d)} }
+
+type realTicker struct{ t *time.Ticker }
+
+func (t realTicker) C() <-chan time.Time { return t.t.C }
+func (t realTicker) Stop()               { t.t.Stop() }
+
+// Since is time.Since on clock.
+func Since(clock Clock, t time.Time) time.Duration {
+	return clock.Now().Sub(t)
+}
+
+// Sleep waits d on clock. It returns false if ctx was cancelled first.
+func Sleep(ctx context.Context, clock Clock, d time.Duration) bool {
+	select {
+	case <-clock.After(d):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// SleepUntil waits until clock reaches t. It returns false if ctx was
+// cancelled first.
+func SleepUntil(ctx context.Context, clock Clock, t time.Time) bool {
+	return Sleep(ctx, clock, t.Sub(clock.Now()))
+}
+
+// WithTimeout is context.WithTimeout on clock. The returned context's cause
+// is context.DeadlineExceeded once d has passed.
+func WithTimeout(ctx context.Context, clock Clock, d time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	expired := clock.After(d)
+	go func() {
+		select {
+		case <-expired:
+			cancel(context.DeadlineExceeded)
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() { cancel(context.Canceled) }
+}
+
+// Every calls fn each interval until ctx is cancelled. The first call is one
+// interval after Every starts.
+func Every(ctx context.Context, clock Clock, interval time.Duration, fn func(ctx context.Context)) {
+	ticker := clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C():
+			fn(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// FakeClock only moves when Advance is called. Timers and tickers due within
+// the advanced span fire in time order, each sent its own deadline.
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*fakeWaiter
+	changed chan struct{}
+}
+
+type fakeWaiter struct {
+	at     time.Time
+	period time.Duration
+	ch     chan time.Time
+}
+
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start, changed: make(chan struct{})}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w := &fakeWaiter{at: c.now.Add(d), ch: make(chan time.Time, 1)}
+	if d <= 0 {
+		w.ch <- c.now
+		return w.ch
+	}
+	c.addLocked(w)
+	return w.ch
+}
+
+func (c *FakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for FakeClock.NewTicker")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w := &fakeWaiter{at: c.now.Add(d), period: d, ch: make(chan time.Time, 1)}
+	c.addLocked(w)
+	return &fakeTicker{clock: c, w: w}
+}
+
+// Advance moves the clock forward by d, firing everything due on the way.
+// Like time.Ticker, a ticker whose channel is still full drops the tick.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	target := c.now.Add(d)
+	for len(c.waiters) > 0 && !c.waiters[0].at.After(target) {
+		w := c.waiters[0]
+		c.waiters = c.waiters[1:]
+		c.now = w.at
+		select {
+		case w.ch <- c.now:
+		default:
+		}
+		if w.period > 0 {
+			w.at = w.at.Add(w.period)
+			c.insertLocked(w)
+		}
+	}
+	c.now = target
+}
+
+// BlockUntil waits until n timers or tickers are pending, so a test can
+// advance only once the goroutine under test has started waiting.
+func (c *FakeClock) BlockUntil(n int) {
+	for {
+		c.mu.Lock()
+		pending, changed := len(c.waiters), c.changed
+		c.mu.Unlock()
+		if pending >= n {
+			return
+		}
+		<-changed
+	}
+}
+
+func (c *FakeClock) addLocked(w *fakeWaiter) {
+	c.insertLocked(w)
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+func (c *FakeClock) insertLocked(w *fakeWaiter) {
+	i := sort.Search(len(c.waiters), func(i int) bool { return c.waiters[i].at.After(w.at) })
+	c.waiters = append(c.waiters, nil)
+	copy(c.waiters[i+1:], c.waiters[i:])
+	c.waiters[i] = w
+}
+
+func (c *FakeClock) remove(w *fakeWaiter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, pending := range c.waiters {
+		if pending == w {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+type fakeTicker struct {
+	clock *FakeClock
+	w     *fakeWaiter
+}
+
+func (t *fakeTicker) C() <-chan time.Time { return t.w.ch }
+func (t *fakeTicker) Stop()               { t.clock.remove(t.w) }
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestRemovedLogLeavesDecisionToTracker(t *testing.T) {
+	tb := newTestBridge(t)
+	vLog := tb.emit(testSourceChain, tb.lockLog(5, 1, 1000))
+	id := lockEventID(testSourceChain, vLog)
+	tb.drain()
+
+	tb.reorgOut(testSourceChain, vLog)
+	if status := tb.status(id); status != StatusPendingConfirmation {
+		t.Fatalf("status right after the removal = %s, want still pending", status)
+	}
+
+	tb.confirm()
+	if status := tb.status(id); status != StatusReorged {
+		t.Errorf("status = %s, want reorged", status)
+	}
+	if mints := tb.minted(testTargetChain); len(mints) != 0 {
+		t.Errorf("minted %d times for a reorged lock", len(mints))
+	}
+}
+
+func TestRemovedThenReincludedLogSettles(t *testing.T) {
+	tb := newTestBridge(t)
+	vLog := tb.emit(testSourceChain, tb.lockLog(5, 1, 1000))
+	id := lockEventID(testSourceChain, vLog)
+	tb.drain()
+
+	tb.reorgOut(testSourceChain, vLog)
+	tb.reinclude(testSourceChain, vLog, 8)
+	tb.drain()
+	// The first round sees the lock in its new block and restarts the count.
+	tb.confirm()
+	tb.confirm()
+
+	if status := tb.status(id); status != StatusCompleted {
+		t.Errorf("status = %s, want completed", status)
+	}
+	if mints := tb.minted(testTargetChain); len(mints) != 1 {
+		t.Errorf("minted %d times, want once", len(mints))
+	}
+}
+
+func TestReorgedLockRevivedWhenReincluded(t *testing.T) {
+	tb := newTestBridge(t)
+	vLog := tb.emit(testSourceChain, tb.lockLog(5, 1, 1000))
+	id := lockEventID(testSourceChain, vLog)
+	tb.drain()
+
+	tb.reorgOut(testSourceChain, vLog)
+	tb.confirm()
+	if status := tb.status(id); status != StatusReorged {
+		t.Fatalf("status = %s, want reorged", status)
+	}
+
+	tb.reinclude(testSourceChain, vLog, 12)
+	if status := tb.status(id); status != StatusPendingConfirmation {
+		t.Fatalf("status after re-inclusion = %s, want pending_confirmation", status)
+	}
+	tb.drain()
+	tb.confirm()
+
+	if status := tb.status(id); status != StatusCompleted {
+		t.Errorf("status = %s, want completed", status)
+	}
+	if mints := tb.minted(testTargetChain); len(mints) != 1 {
+		t.Errorf("minted %d times, want once", len(mints))
+	}
+	if got := tb.duplicates.Load(); got != 0 {
+		t.Errorf("counted %d duplicates", got)
+	}
+}
+
+// After a reorg the contract may hand a dropped lock's nonce to a different
+// lock on the new branch. That lock is a transfer of its own.
+func TestNonceOfReorgedLockCanBeReused(t *testing.T) {
+	tb := newTestBridge(t)
+	dropped := tb.emit(testSourceChain, tb.lockLog(5, 1, 1000))
+	tb.drain()
+	tb.reorgOut(testSourceChain, dropped)
+	tb.confirm()
+
+	replacement := tb.emit(testSourceChain, tb.lockLog(9, 1, 700))
+	tb.drain()
+	tb.confirm()
+
+	if status := tb.status(lockEventID(testSourceChain, dropped)); status != StatusReorged {
+		t.Errorf("dropped lock is %s, want reorged", status)
+	}
+	if status := tb.status(lockEventID(testSourceChain, replacement)); status != StatusCompleted {
+		t.Errorf("replacement lock is %s, want completed", status)
+	}
+	mints := tb.minted(testTargetChain)
+	if len(mints) != 1 || mints[0].Amount.Int64() != 700 {
+		t.Errorf("minted %+v, want one mint of 700", mints)
+	}
+}
+
+// withFinality re-registers the source chain with the given finality.
+func (tb *testBridge) withFinality(finality string) {
+	chain, _ := tb.chains.GetChain(testSourceChain)
+	chain.Finality = finality
+	tb.chains.Register(chain, tb.mocks[testSourceChain])
+}
+
+// stepsToSettle counts the poll intervals, each bringing one new source
+// block, until id completes. Work that needs neither is given a moment to
+// finish before every step.
+func (tb *testBridge) stepsToSettle(id string) int {
+	tb.t.Helper()
+	for steps := 0; ; steps++ {
+		deadline := time.Now().Add(200 * time.Millisecond)
+		for time.Now().Before(deadline) {
+			if tb.status(id) == StatusCompleted {
+				return steps
+			}
+			time.Sleep(time.Millisecond)
+		}
+		if steps == 20 {
+			tb.t.Fatalf("%s is %s after %d steps", id, tb.status(id), steps)
+		}
+		tb.mocks[testSourceChain].Mine(1)
+		tb.clock.Advance(confirmationPollInterval)
+	}
+}
+
+// A lock on an instant-finality chain is minted as soon as its receipt is
+// checked, with no poll interval or block to wait for; on a depth chain it
+// waits for its confirmations.
+func TestInstantFinalitySettlesWithoutWaiting(t *testing.T) {
+	steps := make(map[string]int)
+	for _, finality := range []string{finalityDepth, finalityInstant} {
+		tb := newTestBridge(t)
+		tb.withFinality(finality)
+		ctx, cancel := context.WithCancel(context.Background())
+		tb.runLoops(ctx, tb.TrackConfirmations)
+
+		vLog := tb.emit(testSourceChain, tb.lockLog(5, 1, 1000))
+		id := lockEventID(testSourceChain, vLog)
+		steps[finality] = tb.stepsToSettle(id)
+		cancel()
+
+		event, err := tb.store.GetByID(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event.Confirmation != finality {
+			t.Errorf("%s lock recorded confirmation %q", finality, event.Confirmation)
+		}
+		if mints := tb.minted(testTargetChain); len(mints) != 1 {
+			t.Errorf("%s lock minted %d times, want once", finality, len(mints))
+		}
+	}
+
+	if steps[finalityInstant] != 0 {
+		t.Errorf("instant lock settled after %d steps, want none", steps[finalityInstant])
+	}
+	if steps[finalityDepth] < testConfirmations {
+		t.Errorf("depth lock settled after %d steps, before its %d confirmations", steps[finalityDepth], testConfirmations)
+	}
+}
+
+// A receipt that doesn't match the log sends the lock to the depth tracker,
+// which settles it once the block it is really in is buried.
+func TestInstantFinalityFallsBackToDepth(t *testing.T) {
+	tb := newTestBridge(t)
+	tb.withFinality(finalityInstant)
+	vLog := tb.emit(testSourceChain, tb.lockLog(5, 1, 1000))
+	id := lockEventID(testSourceChain, vLog)
+	// Taken off the queue here rather than drained, so the instant
+	// confirmation runs only where the test calls it.
+	event := <-tb.eventChan
+	// The node now has the transaction in another block.
+	tb.mocks[testSourceChain].RemoveLogs(vLog.TxHash)
+	vLog.BlockNumber, vLog.BlockHash = 7, common.Hash{}
+	tb.mocks[testSourceChain].AddLogs(vLog)
+
+	tb.confirmInstant(event)
+	if status := tb.status(id); status != StatusPendingConfirmation {
+		t.Fatalf("status = %s, want still pending", status)
+	}
+	if stored, _ := tb.store.GetByID(id); stored.Confirmation != finalityDepth {
+		t.Errorf("confirmation = %q after the fallback, want %q", stored.Confirmation, finalityDepth)
+	}
+	if tracked := tb.confirmations.Len(); tracked != 1 {
+		t.Fatalf("%d events tracked, want the lock", tracked)
+	}
+
+	// The first round sees the lock in its new block and restarts the count.
+	tb.confirm()
+	tb.confirm()
+	if status := tb.status(id); status != StatusCompleted {
+		t.Errorf("status = %s, want completed", status)
+	}
+	if mints := tb.minted(testTargetChain); len(mints) != 1 {
+		t.Errorf("minted %d times, want once", len(mints))
+	}
+}
+
+// Instant finality skips only the wait for blocks: a pause still holds the
+// lock back.
+func TestInstantFinalityHonoursPause(t *testing.T) {
+	tb := newTestBridge(t)
+	tb.withFinality(finalityInstant)
+	tb.pauses.set(PauseState{Scope: testTargetChain, Reason: "maintenance", Since: testEpoch})
+	vLog := tb.emit(testSourceChain, tb.lockLog(5, 1, 1000))
+	id := lockEventID(testSourceChain, vLog)
+	event := <-tb.eventChan
+
+	tb.confirmInstant(event)
+	tb.settle()
+	if status := tb.status(id); status != StatusPaused {
+		t.Errorf("status = %s, want paused", status)
+	}
+	if mints := tb.minted(testTargetChain); len(mints) != 0 {
+		t.Errorf("minted %d times while paused", len(mints))
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/gorilla/websocket"
+)
+
+// Egress destinations are named so each one can carry its own proxy override,
+// e.g. BRIDGE_PROXY_ETHEREUM or BRIDGE_PROXY_STATUS_CALLBACK. An override of
+// "direct" bypasses the global proxy for that destination.
+const (
+	egressStatusCallback = "status_callback"
+	egressDirect         = "direct"
+)
+
+type EgressConfig struct {
+	global    *url.URL
+	overrides map[string]*url.URL
+}
+
+type EgressCheck struct {
+	Destination string    `json:"destination"`
+	Host        string    `json:"host"`
+	Via         string    `json:"via"`
+	OK          bool      `json:"ok"`
+	Error       string    `json:"error,omitempty"`
+	CheckedAt   time.Time `json:"checkedAt"`
+}
+
+type egressTarget struct {
+	url   string
+	check func(ctx context.Context) error
+}
+
+type EgressMonitor struct {
+	clock Clock
+
+	mu      sync.RWMutex
+	targets map[string]egressTarget
+	results map[string]EgressCheck
+}
+
+func LoadEgressConfig() (*EgressConfig, error) {
+	cfg := &EgressConfig{overrides: make(map[string]*url.URL)}
+
+	if raw := os.Getenv("BRIDGE_PROXY_URL"); raw != "" {
+		proxyURL, err := parseProxyURL(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid BRIDGE_PROXY_URL: %v", err)
+		}
+		cfg.global = proxyURL
+	}
+
+	for _, kv := range os.Environ() {
+		key, value, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(key, "BRIDGE_PROXY_") || key == "BRIDGE_PROXY_URL" {
+			continue
+		}
+		dest := strings.ToLower(strings.TrimPrefix(key, "BRIDGE_PROXY_"))
+		if strings.EqualFold(value, egressDirect) {
+			cfg.overrides[dest] = nil
+			continue
+		}
+		proxyURL, err := parseProxyURL(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", key, err)
+		}
+		cfg.overrides[dest] = proxyURL
+	}
+
+	return cfg, nil
+}
+
+func parseProxyURL(raw string) (*url.URL, error) {
+	proxyURL, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	if proxyURL.Scheme != "http" && proxyURL.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported proxy scheme %q", proxyURL.Scheme)
+	}
+	if proxyURL.Host == "" {
+		return nil, fmt.Errorf("missing proxy host")
+	}
+	return proxyURL, nil
+}
+
+func (c *EgressConfig) ProxyFor(dest string) *url.URL {
+	if proxyURL, ok := c.overrides[dest]; ok {
+		return proxyURL
+	}
+	return c.global
+}
+
+func (c *EgressConfig) describe(dest string) string {
+	proxyURL := c.ProxyFor(dest)
+	if proxyURL == nil {
+		return egressDirect
+	}
+	return "proxy " + proxyURL.Host
+}
+
+// Credentials in the proxy URL are sent as Proxy-Authorization, both for
+// plain HTTP requests and for the CONNECT used by TLS and websocket dials.
+func (c *EgressConfig) HTTPClient(dest string, timeout time.Duration) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	proxyURL := c.ProxyFor(dest)
+	transport.Proxy = func(*http.Request) (*url.URL, error) { return proxyURL, nil }
+	return &http.Client{Transport: transport, Timeout: timeout}
+}
+
+func (c *EgressConfig) WebsocketDialer(dest string) websocket.Dialer {
+	proxyURL := c.ProxyFor(dest)
+	return websocket.Dialer{
+		Proxy:            func(*http.Request) (*url.URL, error) { return proxyURL, nil },
+		HandshakeTimeout: 45 * time.Second,
+		ReadBufferSize:   1024,
+		WriteBufferSize:  1024,
+	}
+}
+
+func (c *EgressConfig) DialRPC(ctx context.Context, dest, rawURL string) (*ethclient.Client, error) {
+	rpcClient, err := rpc.DialOptions(ctx, rawURL,
+		rpc.WithHTTPClient(c.HTTPClient(dest, 30*time.Second)),
+		rpc.WithWebsocketDialer(c.WebsocketDialer(dest)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return ethclient.NewClient(rpcClient), nil
+}
+
+func NewEgressMonitor(clock Clock) *EgressMonitor {
+	return &EgressMonitor{
+		clock:   clock,
+		targets: make(map[string]egressTarget),
+		results: make(map[string]EgressCheck),
+	}
+}
+
+func (m *EgressMonitor) Register(dest, rawURL string, check func(ctx context.Context) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.targets[dest] = egressTarget{url: rawURL, check: check}
+}
+
+// SelfTest probes every registered destination through its configured path and
+// records the outcome per destination. Failures are reported, not fatal.
+func (m *EgressMonitor) SelfTest(ctx context.Context, cfg *EgressConfig) {
+	m.mu.RLock()
+	targets := make(map[string]egressTarget, len(m.targets))
+	for dest, target := range m.targets {
+		targets[dest] = target
+	}
+	m.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for dest, target := range targets {
+		wg.Add(1)
+		go func(dest string, target egressTarget) {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+			defer cancel()
+
+			result := EgressCheck{
+				Destination: dest,
+				Host:        hostOf(target.url),
+				Via:         cfg.describe(dest),
+				CheckedAt:   m.clock.Now(),
+			}
+			if err := target.check(checkCtx); err != nil {
+				result.Error = err.Error()
+				log.Printf("Egress self-test failed for %s (%s via %s): %v", dest, result.Host, result.Via, err)
+			} else {
+				result.OK = true
+			}
+
+			m.mu.Lock()
+			m.results[dest] = result
+			m.mu.Unlock()
+		}(dest, target)
+	}
+	wg.Wait()
+}
+
+func (m *EgressMonitor) Results() map[string]EgressCheck {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	results := make(map[string]EgressCheck, len(m.results))
+	for dest, result := range m.results {
+		results[dest] = result
+	}
+	return results
+}
+
+func httpReachable(client *http.Client, rawURL string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, rawURL, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	}
+}
+
+// hostOf keeps API keys embedded in RPC paths out of logs and /status.
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "invalid-url"
+	}
+	return u.Host
+}
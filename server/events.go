@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+//go:embed abi/*.json
+var bridgeABIs embed.FS
+
+const defaultContractVersion = "v1"
+
+// listenedEvents are the bridge events every chain listener subscribes to.
+// They are resolved against the embedded ABI at startup, so a typo here fails
+// InitializeClients instead of silently subscribing to a topic nobody emits.
+var listenedEvents = []string{
+	"Locked(address,address,bytes32,bytes,uint256,bytes32)",
+	"Burned(address,address,bytes32,bytes,uint256,bytes32)",
+}
+
+type EventDefinition struct {
+	Name      string      `json:"name"`
+	Signature string      `json:"signature"`
+	Topic     common.Hash `json:"topic"`
+	Version   string      `json:"version"`
+}
+
+type registeredEvent struct {
+	version string
+	event   abi.Event
+}
+
+type EventRegistry struct {
+	versions map[string]abi.ABI
+	byTopic  map[common.Hash]registeredEvent
+}
+
+func LoadEventRegistry() (*EventRegistry, error) {
+	entries, err := bridgeABIs.ReadDir("abi")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embedded ABIs: %v", err)
+	}
+
+	registry := &EventRegistry{
+		versions: make(map[string]abi.ABI),
+		byTopic:  make(map[common.Hash]registeredEvent),
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		version := strings.TrimSuffix(strings.TrimPrefix(name, "bridge_"), ".json")
+
+		data, err := bridgeABIs.ReadFile("abi/" + name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ABI %s: %v", name, err)
+		}
+		parsed, err := abi.JSON(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse ABI %s: %v", name, err)
+		}
+		registry.versions[version] = parsed
+
+		for _, event := range parsed.Events {
+			existing, ok := registry.byTopic[event.ID]
+			if ok && !sameEventLayout(existing.event, event) {
+				return nil, fmt.Errorf("topic %s is %s in ABI %s but %s in ABI %s with a different layout",
+					event.ID.Hex(), existing.event.Sig, existing.version, event.Sig, version)
+			}
+			if !ok {
+				registry.byTopic[event.ID] = registeredEvent{version: version, event: event}
+			}
+		}
+	}
+
+	return registry, nil
+}
+
+// Changing which arguments are indexed keeps the topic hash but changes how
+// the log must be decoded, so two versions sharing a topic must agree on it.
+func sameEventLayout(a, b abi.Event) bool {
+	if a.Sig != b.Sig || len(a.Inputs) != len(b.Inputs) {
+		return false
+	}
+	for i := range a.Inputs {
+		if a.Inputs[i].Indexed != b.Inputs[i].Indexed {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *EventRegistry) ABI(version string) (abi.ABI, error) {
+	parsed, ok := r.versions[version]
+	if !ok {
+		return abi.ABI{}, fmt.Errorf("unknown bridge contract version %q", version)
+	}
+	return parsed, nil
+}
+
+func (r *EventRegistry) Resolve(version string, signatures []string) ([]EventDefinition, error) {
+	parsed, err := r.ABI(version)
+	if err != nil {
+		return nil, err
+	}
+
+	definitions := make([]EventDefinition, 0, len(signatures))
+	for _, signature := range signatures {
+		event, ok := findEventBySig(parsed, signature)
+		if !ok {
+			return nil, fmt.Errorf("event %q not found in bridge ABI %s", signature, version)
+		}
+		definitions = append(definitions, EventDefinition{
+			Name:      event.Name,
+			Signature: event.Sig,
+			Topic:     event.ID,
+			Version:   version,
+		})
+	}
+	return definitions, nil
+}
+
+func findEventBySig(parsed abi.ABI, signature string) (abi.Event, bool) {
+	for _, event := range parsed.Events {
+		if event.Sig == signature {
+			return event, true
+		}
+	}
+	return abi.Event{}, false
+}
+
+func topicsOf(definitions []EventDefinition) []common.Hash {
+	topics := make([]common.Hash, 0, len(definitions))
+	for _, def := range definitions {
+		topics = append(topics, def.Topic)
+	}
+	return topics
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Explorer templates use {tx}, {address} and {token} placeholders. Values are
+// path-escaped so non-hex identifiers from non-EVM chains render safely.
+type ExplorerTemplates struct {
+	TxURL      string `json:"txUrl,omitempty"`
+	AddressURL string `json:"addressUrl,omitempty"`
+	TokenURL   string `json:"tokenUrl,omitempty"`
+}
+
+type ExplorerLinks struct {
+	LockTx    string `json:"lockTx,omitempty"`
+	MintTx    string `json:"mintTx,omitempty"`
+	Sender    string `json:"sender,omitempty"`
+	Recipient string `json:"recipient,omitempty"`
+	Token     string `json:"token,omitempty"`
+}
+
+var defaultExplorers = map[string]ExplorerTemplates{
+	"ethereum": {
+		TxURL:      "https://etherscan.io/tx/{tx}",
+		AddressURL: "https://etherscan.io/address/{address}",
+		TokenURL:   "https://etherscan.io/token/{token}",
+	},
+	"polygon": {
+		TxURL:      "https://polygonscan.com/tx/{tx}",
+		AddressURL: "https://polygonscan.com/address/{address}",
+		TokenURL:   "https://polygonscan.com/token/{token}",
+	},
+	"bsc": {
+		TxURL:      "https://bscscan.com/tx/{tx}",
+		AddressURL: "https://bscscan.com/address/{address}",
+		TokenURL:   "https://bscscan.com/token/{token}",
+	},
+}
+
+// renderExplorerURL returns "" when either the template or the value is
+// missing, so callers omit the link instead of emitting a broken URL.
+func renderExplorerURL(template, placeholder, value string) string {
+	if template == "" || value == "" || !strings.Contains(template, placeholder) {
+		return ""
+	}
+	return strings.ReplaceAll(template, placeholder, url.PathEscape(value))
+}
+
+func (bs *BridgeService) lockExplorerLinks(event BridgeEvent) *ExplorerLinks {
+	source := bs.explorers[event.FromChain]
+	target := bs.explorers[event.ToChain]
+
+	links := &ExplorerLinks{
+		LockTx:    renderExplorerURL(source.TxURL, "{tx}", event.TxHash),
+		Sender:    renderExplorerURL(source.AddressURL, "{address}", event.Sender),
+		Recipient: renderExplorerURL(target.AddressURL, "{address}", event.Recipient),
+		Token:     renderExplorerURL(source.TokenURL, "{token}", event.Token),
+	}
+	if *links == (ExplorerLinks{}) {
+		return nil
+	}
+	return links
+}
+
+func (bs *BridgeService) mintExplorerLinks(lockEvent BridgeEvent, mintTxHash string) *ExplorerLinks {
+	links := &ExplorerLinks{}
+	if lockEvent.ExplorerLinks != nil {
+		*links = *lockEvent.ExplorerLinks
+	}
+	links.MintTx = renderExplorerURL(bs.explorers[lockEvent.ToChain].TxURL, "{tx}", mintTxHash)
+	if *links == (ExplorerLinks{}) {
+		return nil
+	}
+	return links
+}
@@ -0,0 +1,425 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	rpcHealthInterval = 15 * time.Second
+	rpcHealthTimeout  = 5 * time.Second
+)
+
+// errEndpointSwitched ends a subscription loop so it resubscribes on the
+// endpoint the failover client moved to.
+var errEndpointSwitched = errors.New("rpc endpoint switched")
+
+type rpcEndpoint struct {
+	url       string
+	client    *ethclient.Client
+	healthy   bool
+	head      uint64
+	err       string
+	checkedAt time.Time
+}
+
+type EndpointStatus struct {
+	Host      string    `json:"host"`
+	Active    bool      `json:"active"`
+	Healthy   bool      `json:"healthy"`
+	Head      uint64    `json:"head,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// FailoverClient spreads one chain over an ordered list of RPC endpoints. Calls
+// go to the active endpoint; a transport failure marks it unhealthy and the
+// call is retried on the next healthy one. Errors the node itself returns
+// (reverts, nonce errors, not found) are passed through without failing over.
+// The health check moves back to an earlier endpoint once it recovers.
+type FailoverClient struct {
+	chain string
+	clock Clock
+	dial  func(ctx context.Context, rawURL string) (*ethclient.Client, error)
+
+	mu        sync.RWMutex
+	endpoints []*rpcEndpoint
+	active    int
+	switched  chan struct{}
+}
+
+// NewFailoverClient dials every endpoint. Endpoints that can't be dialed yet
+// start unhealthy and are retried by the health check; at least one must
+// connect.
+func NewFailoverClient(chainName string, urls []string, clock Clock, dial func(ctx context.Context, rawURL string) (*ethclient.Client, error)) (*FailoverClient, error) {
+	fc := &FailoverClient{chain: chainName, clock: clock, dial: dial, active: -1, switched: make(chan struct{})}
+
+	var failures []string
+	for i, rawURL := range urls {
+		endpoint := &rpcEndpoint{url: rawURL, checkedAt: clock.Now()}
+		client, err := dial(context.Background(), rawURL)
+		if err != nil {
+			endpoint.err = err.Error()
+			failures = append(failures, fmt.Sprintf("%s: %v", hostOf(rawURL), err))
+			log.Printf("Failed to connect to %s endpoint %s: %v", chainName, hostOf(rawURL), err)
+		} else {
+			endpoint.client = client
+			endpoint.healthy = true
+			if fc.active < 0 {
+				fc.active = i
+			}
+		}
+		fc.endpoints = append(fc.endpoints, endpoint)
+	}
+	if fc.active < 0 {
+		return nil, fmt.Errorf("no reachable endpoint (%s)", strings.Join(failures, "; "))
+	}
+	return fc, nil
+}
+
+func (fc *FailoverClient) current() (int, *ethclient.Client) {
+	fc.mu.RLock()
+	defer fc.mu.RUnlock()
+	return fc.active, fc.endpoints[fc.active].client
+}
+
+// Switched is closed the next time the active endpoint changes.
+func (fc *FailoverClient) Switched() <-chan struct{} {
+	fc.mu.RLock()
+	defer fc.mu.RUnlock()
+	return fc.switched
+}
+
+// fail marks endpoint i unhealthy and, if it was active, moves to the next
+// healthy endpoint after it. With none left the active one is kept.
+func (fc *FailoverClient) fail(i int, err error) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	endpoint := fc.endpoints[i]
+	endpoint.healthy = false
+	endpoint.err = err.Error()
+	endpoint.checkedAt = fc.clock.Now()
+	if i != fc.active {
+		return
+	}
+	for step := 1; step < len(fc.endpoints); step++ {
+		next := (i + step) % len(fc.endpoints)
+		if fc.endpoints[next].healthy && fc.endpoints[next].client != nil {
+			fc.switchLocked(next, err.Error())
+			return
+		}
+	}
+}
+
+func (fc *FailoverClient) switchLocked(next int, reason string) {
+	log.Printf("Switching %s RPC from %s to %s: %s", fc.chain,
+		hostOf(fc.endpoints[fc.active].url), hostOf(fc.endpoints[next].url), reason)
+	rpcFailovers.WithLabelValues(fc.chain).Inc()
+	fc.active = next
+	close(fc.switched)
+	fc.switched = make(chan struct{})
+}
+
+// shouldFailOver reports whether err says the endpoint is unusable rather
+// than that the node answered with an error.
+func shouldFailOver(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil || errors.Is(err, ethereum.NotFound) {
+		return false
+	}
+	var rpcErr rpc.Error
+	return !errors.As(err, &rpcErr)
+}
+
+// call runs fn against the active endpoint, failing over at most once per
+// endpoint.
+func (fc *FailoverClient) call(ctx context.Context, fn func(client *ethclient.Client) error) error {
+	var err error
+	for attempt := 0; attempt < len(fc.endpoints); attempt++ {
+		i, client := fc.current()
+		if err = fn(client); !shouldFailOver(ctx, err) {
+			return err
+		}
+		fc.fail(i, err)
+		if next, _ := fc.current(); next == i {
+			return err
+		}
+	}
+	return err
+}
+
+// watch marks the endpoint a subscription runs on as failed when the
+// subscription errors out.
+func (fc *FailoverClient) watch(client *ethclient.Client, sub ethereum.Subscription) ethereum.Subscription {
+	errc := make(chan error, 1)
+	go func() {
+		err, ok := <-sub.Err()
+		if ok && err != nil {
+			if i := fc.indexOf(client); i >= 0 {
+				fc.fail(i, err)
+			}
+			errc <- err
+		}
+		close(errc)
+	}()
+	return &watchedSubscription{Subscription: sub, errc: errc}
+}
+
+func (fc *FailoverClient) indexOf(client *ethclient.Client) int {
+	fc.mu.RLock()
+	defer fc.mu.RUnlock()
+	for i, endpoint := range fc.endpoints {
+		if endpoint.client == client {
+			return i
+		}
+	}
+	return -1
+}
+
+type watchedSubscription struct {
+	ethereum.Subscription
+	errc chan error
+}
+
+func (s *watchedSubscription) Err() <-chan error { return s.errc }
+
+// RunHealthChecks probes every endpoint until ctx is cancelled, redialing
+// ones that never connected.
+func (fc *FailoverClient) RunHealthChecks(ctx context.Context) {
+	Every(ctx, fc.clock, rpcHealthInterval, fc.checkHealth)
+}
+
+func (fc *FailoverClient) checkHealth(ctx context.Context) {
+	fc.mu.RLock()
+	endpoints := append([]*rpcEndpoint(nil), fc.endpoints...)
+	fc.mu.RUnlock()
+
+	type result struct {
+		client *ethclient.Client
+		head   uint64
+		err    error
+	}
+	results := make([]result, len(endpoints))
+	var wg sync.WaitGroup
+	for i, endpoint := range endpoints {
+		fc.mu.RLock()
+		client := endpoint.client
+		fc.mu.RUnlock()
+
+		wg.Add(1)
+		go func(i int, rawURL string, client *ethclient.Client) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, rpcHealthTimeout)
+			defer cancel()
+
+			if client == nil {
+				dialed, err := fc.dial(checkCtx, rawURL)
+				if err != nil {
+					results[i] = result{err: err}
+					return
+				}
+				client = dialed
+			}
+			head, err := client.BlockNumber(checkCtx)
+			results[i] = result{client: client, head: head, err: err}
+		}(i, endpoint.url, client)
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return
+	}
+
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	for i, res := range results {
+		endpoint := fc.endpoints[i]
+		endpoint.checkedAt = fc.clock.Now()
+		if res.client != nil {
+			endpoint.client = res.client
+		}
+		if res.err != nil {
+			endpoint.healthy = false
+			endpoint.err = res.err.Error()
+			continue
+		}
+		endpoint.healthy = true
+		endpoint.head = res.head
+		endpoint.err = ""
+	}
+
+	// Prefer the earliest healthy endpoint, so traffic returns to the
+	// primary once it recovers.
+	for i, endpoint := range fc.endpoints {
+		if endpoint.healthy {
+			if i != fc.active {
+				fc.switchLocked(i, "health check")
+			}
+			return
+		}
+	}
+}
+
+func (fc *FailoverClient) Status() []EndpointStatus {
+	fc.mu.RLock()
+	defer fc.mu.RUnlock()
+
+	statuses := make([]EndpointStatus, 0, len(fc.endpoints))
+	for i, endpoint := range fc.endpoints {
+		statuses = append(statuses, EndpointStatus{
+			Host:      hostOf(endpoint.url),
+			Active:    i == fc.active,
+			Healthy:   endpoint.healthy,
+			Head:      endpoint.head,
+			Error:     endpoint.err,
+			CheckedAt: endpoint.checkedAt,
+		})
+	}
+	return statuses
+}
+
+func (fc *FailoverClient) ChainID(ctx context.Context) (*big.Int, error) {
+	var chainID *big.Int
+	err := fc.call(ctx, func(client *ethclient.Client) (err error) {
+		chainID, err = client.ChainID(ctx)
+		return err
+	})
+	return chainID, err
+}
+
+func (fc *FailoverClient) BlockNumber(ctx context.Context) (uint64, error) {
+	var head uint64
+	err := fc.call(ctx, func(client *ethclient.Client) (err error) {
+		head, err = client.BlockNumber(ctx)
+		return err
+	})
+	return head, err
+}
+
+func (fc *FailoverClient) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	var header *types.Header
+	err := fc.call(ctx, func(client *ethclient.Client) (err error) {
+		header, err = client.HeaderByNumber(ctx, number)
+		return err
+	})
+	return header, err
+}
+
+func (fc *FailoverClient) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	var receipt *types.Receipt
+	err := fc.call(ctx, func(client *ethclient.Client) (err error) {
+		receipt, err = client.TransactionReceipt(ctx, txHash)
+		return err
+	})
+	return receipt, err
+}
+
+func (fc *FailoverClient) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error) {
+	var code []byte
+	err := fc.call(ctx, func(client *ethclient.Client) (err error) {
+		code, err = client.CodeAt(ctx, account, blockNumber)
+		return err
+	})
+	return code, err
+}
+
+func (fc *FailoverClient) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	var logs []types.Log
+	err := fc.call(ctx, func(client *ethclient.Client) (err error) {
+		logs, err = client.FilterLogs(ctx, query)
+		return err
+	})
+	return logs, err
+}
+
+func (fc *FailoverClient) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	var out []byte
+	err := fc.call(ctx, func(client *ethclient.Client) (err error) {
+		out, err = client.CallContract(ctx, msg, blockNumber)
+		return err
+	})
+	return out, err
+}
+
+func (fc *FailoverClient) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	var nonce uint64
+	err := fc.call(ctx, func(client *ethclient.Client) (err error) {
+		nonce, err = client.PendingNonceAt(ctx, account)
+		return err
+	})
+	return nonce, err
+}
+
+func (fc *FailoverClient) EstimateGas(ctx context.Context, msg ethereum.CallMsg) (uint64, error) {
+	var gas uint64
+	err := fc.call(ctx, func(client *ethclient.Client) (err error) {
+		gas, err = client.EstimateGas(ctx, msg)
+		return err
+	})
+	return gas, err
+}
+
+func (fc *FailoverClient) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	var price *big.Int
+	err := fc.call(ctx, func(client *ethclient.Client) (err error) {
+		price, err = client.SuggestGasPrice(ctx)
+		return err
+	})
+	return price, err
+}
+
+// SendTransaction may reach a second endpoint after a transport error on the
+// first; resending a signed transaction is harmless, the hash is the same.
+func (fc *FailoverClient) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	var tip *big.Int
+	err := fc.call(ctx, func(client *ethclient.Client) (err error) {
+		tip, err = client.SuggestGasTipCap(ctx)
+		return err
+	})
+	return tip, err
+}
+
+func (fc *FailoverClient) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	return fc.call(ctx, func(client *ethclient.Client) error {
+		return client.SendTransaction(ctx, tx)
+	})
+}
+
+func (fc *FailoverClient) SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	var sub ethereum.Subscription
+	var used *ethclient.Client
+	err := fc.call(ctx, func(client *ethclient.Client) (err error) {
+		sub, err = client.SubscribeFilterLogs(ctx, query, ch)
+		used = client
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fc.watch(used, sub), nil
+}
+
+func (fc *FailoverClient) SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error) {
+	var sub ethereum.Subscription
+	var used *ethclient.Client
+	err := fc.call(ctx, func(client *ethclient.Client) (err error) {
+		sub, err = client.SubscribeNewHead(ctx, ch)
+		used = client
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fc.watch(used, sub), nil
+}
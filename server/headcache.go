@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	headPollInterval = 6 * time.Second
+	headRetryDelay   = 5 * time.Second
+	headStaleAfter   = 2 * time.Minute
+)
+
+type chainHead struct {
+	header    *types.Header
+	updatedAt time.Time
+	mode      string
+	err       string
+}
+
+type HeadStatus struct {
+	Number    uint64    `json:"number"`
+	Hash      string    `json:"hash"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	Stale     bool      `json:"stale"`
+	Mode      string    `json:"mode"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// HeadCache owns the single heads subscription (or poll loop) per chain so
+// every component needing the latest block shares one RPC stream.
+type HeadCache struct {
+	clock Clock
+
+	mu    sync.RWMutex
+	heads map[string]*chainHead
+	subs  map[string]map[chan *types.Header]struct{}
+}
+
+func NewHeadCache(clock Clock) *HeadCache {
+	return &HeadCache{
+		clock: clock,
+		heads: make(map[string]*chainHead),
+		subs:  make(map[string]map[chan *types.Header]struct{}),
+	}
+}
+
+// Run follows chainName's head until ctx is cancelled, resubscribing after a
+// lost subscription and polling when the endpoint has no notification support.
+func (hc *HeadCache) Run(ctx context.Context, chainName string, client ChainClient) {
+	for {
+		err := hc.follow(ctx, chainName, client)
+		if ctx.Err() != nil {
+			return
+		}
+		if errors.Is(err, errEndpointSwitched) {
+			continue
+		}
+		log.Printf("Head tracking for %s interrupted: %v", chainName, err)
+		hc.setError(chainName, err)
+
+		if !Sleep(ctx, hc.clock, headRetryDelay) {
+			return
+		}
+	}
+}
+
+func (hc *HeadCache) follow(ctx context.Context, chainName string, client ChainClient) error {
+	headers := make(chan *types.Header, 16)
+	sub, err := client.SubscribeNewHead(ctx, headers)
+	if errors.Is(err, rpc.ErrNotificationsUnsupported) {
+		return hc.poll(ctx, chainName, client)
+	}
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe()
+
+	switched := client.Switched()
+	for {
+		select {
+		case err := <-sub.Err():
+			return err
+		case <-switched:
+			return errEndpointSwitched
+		case header := <-headers:
+			hc.update(chainName, header, "subscription")
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (hc *HeadCache) poll(ctx context.Context, chainName string, client ChainClient) error {
+	ticker := hc.clock.NewTicker(headPollInterval)
+	defer ticker.Stop()
+
+	for {
+		header, err := client.HeaderByNumber(ctx, nil)
+		if err != nil {
+			return err
+		}
+		hc.update(chainName, header, "poll")
+
+		select {
+		case <-ticker.C():
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (hc *HeadCache) update(chainName string, header *types.Header, mode string) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	current, ok := hc.heads[chainName]
+	if ok && current.header != nil && current.header.Hash() == header.Hash() {
+		current.updatedAt = hc.clock.Now()
+		current.err = ""
+		return
+	}
+	hc.heads[chainName] = &chainHead{header: header, updatedAt: hc.clock.Now(), mode: mode}
+
+	// Subscribers that fall behind miss intermediate heads; GetHead always
+	// returns the latest one.
+	for ch := range hc.subs[chainName] {
+		select {
+		case ch <- header:
+		default:
+		}
+	}
+}
+
+func (hc *HeadCache) setError(chainName string, err error) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	current, ok := hc.heads[chainName]
+	if !ok {
+		current = &chainHead{}
+		hc.heads[chainName] = current
+	}
+	current.err = err.Error()
+}
+
+// GetHead returns the latest known header for chainName and whether it is
+// still fresh.
+func (hc *HeadCache) GetHead(chainName string) (*types.Header, bool) {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+
+	current, ok := hc.heads[chainName]
+	if !ok || current.header == nil {
+		return nil, false
+	}
+	return current.header, Since(hc.clock, current.updatedAt) <= headStaleAfter
+}
+
+func (hc *HeadCache) Subscribe(chainName string) (<-chan *types.Header, func()) {
+	ch := make(chan *types.Header, 1)
+
+	hc.mu.Lock()
+	if hc.subs[chainName] == nil {
+		hc.subs[chainName] = make(map[chan *types.Header]struct{})
+	}
+	hc.subs[chainName][ch] = struct{}{}
+	hc.mu.Unlock()
+
+	unsubscribe := func() {
+		hc.mu.Lock()
+		delete(hc.subs[chainName], ch)
+		hc.mu.Unlock()
+	}
+	return ch, unsubscribe
+}
+
+func (hc *HeadCache) Status() map[string]HeadStatus {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+
+	status := make(map[string]HeadStatus, len(hc.heads))
+	for chainName, current := range hc.heads {
+		entry := HeadStatus{
+			UpdatedAt: current.updatedAt,
+			Stale:     Since(hc.clock, current.updatedAt) > headStaleAfter,
+			Mode:      current.mode,
+			Error:     current.err,
+		}
+		if current.header != nil {
+			entry.Number = current.header.Number.Uint64()
+			entry.Hash = current.header.Hash().Hex()
+		}
+		status[chainName] = entry
+	}
+	return status
+}
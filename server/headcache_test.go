@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// followHeads runs the source chain's head cache on ctx and waits until it
+// has subscribed.
+func (tb *testBridge) followHeads(ctx context.Context) {
+	tb.t.Helper()
+	mock := tb.mocks[testSourceChain]
+	subscribed := mock.Calls("SubscribeNewHead")
+	go tb.heads.Run(ctx, testSourceChain, mock)
+	tb.waitUntil("the head subscription", func() bool { return mock.Calls("SubscribeNewHead") > subscribed })
+}
+
+// waitUntil polls done in real time, for goroutines that don't wait on the
+// clock.
+func (tb *testBridge) waitUntil(what string, done func() bool) {
+	tb.t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			tb.t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (tb *testBridge) headIs(number uint64) func() bool {
+	return func() bool {
+		head, _ := tb.heads.GetHead(testSourceChain)
+		return head != nil && head.Number.Uint64() == number
+	}
+}
+
+// However many components subscribe to a chain's heads or read them, the
+// node sees one subscription and no head requests.
+func TestHeadCacheCallsDoNotGrowWithConsumers(t *testing.T) {
+	const blocks = 5
+	calls := make(map[int]map[string]int)
+	for _, consumers := range []int{1, 8} {
+		tb := newTestBridge(t)
+		mock := tb.mocks[testSourceChain]
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		tb.followHeads(ctx)
+
+		var subs []<-chan *types.Header
+		for i := 0; i < consumers; i++ {
+			heads, unsubscribe := tb.heads.Subscribe(testSourceChain)
+			defer unsubscribe()
+			subs = append(subs, heads)
+		}
+		before := map[string]int{
+			"BlockNumber":    mock.Calls("BlockNumber"),
+			"HeaderByNumber": mock.Calls("HeaderByNumber"),
+		}
+		for i := 0; i < blocks; i++ {
+			want := mock.Mine(1)
+			for _, heads := range subs {
+				select {
+				case head := <-heads:
+					if head.Number.Uint64() != want {
+						t.Fatalf("consumer got head %d, want %d", head.Number, want)
+					}
+				case <-time.After(5 * time.Second):
+					t.Fatalf("consumer never got head %d", want)
+				}
+			}
+			// Each consumer also reads the head the way the confirmation
+			// tracker, the API and warm-up do.
+			for range subs {
+				if head, err := tb.currentHead(ctx, testSourceChain); err != nil || head != want {
+					t.Fatalf("currentHead = %d, %v; want %d", head, err, want)
+				}
+				if head, fresh := tb.heads.GetHead(testSourceChain); !fresh || head.Number.Uint64() != want {
+					t.Fatalf("GetHead = %d, fresh %v; want fresh %d", head.Number, fresh, want)
+				}
+			}
+		}
+		calls[consumers] = map[string]int{
+			"SubscribeNewHead": mock.Calls("SubscribeNewHead"),
+			"BlockNumber":      mock.Calls("BlockNumber") - before["BlockNumber"],
+			"HeaderByNumber":   mock.Calls("HeaderByNumber") - before["HeaderByNumber"],
+		}
+	}
+
+	for consumers, got := range calls {
+		if got["SubscribeNewHead"] != 1 {
+			t.Errorf("%d consumers: subscribed to heads %d times, want once", consumers, got["SubscribeNewHead"])
+		}
+		for _, method := range []string{"BlockNumber", "HeaderByNumber"} {
+			if got[method] != 0 {
+				t.Errorf("%d consumers: called %s %d times over %d blocks, want never", consumers, method, got[method], blocks)
+			}
+		}
+	}
+}
+
+// A lost head subscription is reported in the status and resubscribed after
+// the retry delay, and consumers keep getting heads without subscribing again.
+func TestHeadCacheRecoversLostSubscription(t *testing.T) {
+	tb := newTestBridge(t)
+	mock := tb.mocks[testSourceChain]
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tb.followHeads(ctx)
+	heads, unsubscribe := tb.heads.Subscribe(testSourceChain)
+	defer unsubscribe()
+
+	mock.Mine(1)
+	tb.waitUntil("head 1", tb.headIs(1))
+	<-heads
+
+	mock.DropSubscriptions()
+	tb.waitUntil("the lost subscription to be reported", func() bool {
+		return tb.heads.Status()[testSourceChain].Error != ""
+	})
+	if calls := mock.Calls("SubscribeNewHead"); calls != 1 {
+		t.Fatalf("subscribed %d times before the retry delay, want once", calls)
+	}
+
+	tb.clock.BlockUntil(1)
+	tb.clock.Advance(headRetryDelay)
+	tb.waitUntil("the resubscription", func() bool { return mock.Calls("SubscribeNewHead") == 2 })
+
+	mock.Mine(1)
+	select {
+	case head := <-heads:
+		if head.Number.Uint64() != 2 {
+			t.Errorf("consumer got head %d after recovery, want 2", head.Number)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("consumer got no head after recovery")
+	}
+	status := tb.heads.Status()[testSourceChain]
+	if status.Number != 2 || status.Error != "" || status.Mode != "subscription" {
+		t.Errorf("status after recovery = %+v, want head 2 by subscription without error", status)
+	}
+}
+
+// A head not updated for headStaleAfter is reported stale, readers go to the
+// node instead of trusting it, and the next head makes it fresh again.
+func TestHeadCacheReportsStaleHead(t *testing.T) {
+	tb := newTestBridge(t)
+	mock := tb.mocks[testSourceChain]
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tb.followHeads(ctx)
+
+	mock.Mine(1)
+	tb.waitUntil("head 1", tb.headIs(1))
+	tb.clock.Advance(headStaleAfter)
+	if _, fresh := tb.heads.GetHead(testSourceChain); !fresh {
+		t.Error("head stale at exactly headStaleAfter")
+	}
+
+	tb.clock.Advance(time.Second)
+	if head, fresh := tb.heads.GetHead(testSourceChain); fresh || head.Number.Uint64() != 1 {
+		t.Errorf("GetHead = %d, fresh %v; want stale 1", head.Number, fresh)
+	}
+	if status := tb.heads.Status()[testSourceChain]; !status.Stale {
+		t.Errorf("status = %+v, want stale", status)
+	}
+	reads := mock.Calls("HeaderByNumber")
+	if _, err := tb.currentHead(ctx, testSourceChain); err != nil {
+		t.Fatal(err)
+	}
+	if mock.Calls("HeaderByNumber") != reads+1 {
+		t.Error("currentHead trusted a stale head instead of asking the node")
+	}
+
+	mock.Mine(1)
+	tb.waitUntil("head 2", tb.headIs(2))
+	if _, fresh := tb.heads.GetHead(testSourceChain); !fresh {
+		t.Error("head still stale after a new block")
+	}
+	if status := tb.heads.Status()[testSourceChain]; status.Stale {
+		t.Errorf("status = %+v, want fresh", status)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	clientSendBuffer  = 64
+	defaultEvictAfter = 10 * time.Second
+	// recentEvents is how many broadcasts the hub keeps for clients resuming
+	// with Last-Event-ID.
+	recentEvents = 256
+)
+
+// sequencedEvent is a broadcast event with its place in the hub's sequence.
+type sequencedEvent struct {
+	Seq   uint64
+	Event BridgeEvent
+}
+
+// hubClient's send carries sequencedEvents and control frames for the
+// connection's single writer.
+type hubClient struct {
+	send   chan interface{}
+	filter atomic.Pointer[SubscriptionFilter]
+
+	// fullSince is when the send buffer was first found full (UnixNano),
+	// 0 while the client keeps up.
+	fullSince atomic.Int64
+}
+
+// Hub fans processed events out to websocket and SSE clients. It never
+// reads from eventChan, which belongs exclusively to ProcessBridgeEvents. A
+// client whose buffer stays full for evictAfter is disconnected so it stops
+// costing every broadcast a dropped send.
+//
+// Every broadcast gets the next sequence number, and the most recent ones are
+// kept so a client can resume where it left off.
+type Hub struct {
+	clock      Clock
+	evictAfter time.Duration
+	evictions  atomic.Uint64
+
+	mu      sync.RWMutex
+	clients map[*hubClient]struct{}
+	seq     uint64
+	recent  []sequencedEvent
+}
+
+func NewHub(clock Clock) *Hub {
+	return &Hub{
+		clock:      clock,
+		evictAfter: defaultEvictAfter,
+		clients:    make(map[*hubClient]struct{}),
+		// Seeding from the clock keeps sequence numbers increasing across
+		// restarts, so a client resuming after one isn't sent stale events.
+		seq: uint64(clock.Now().UnixMicro()),
+	}
+}
+
+func (h *Hub) Register() *hubClient {
+	client := &hubClient{send: make(chan interface{}, clientSendBuffer)}
+
+	h.mu.Lock()
+	h.clients[client] = struct{}{}
+	h.mu.Unlock()
+
+	return client
+}
+
+// Resume registers a client that has seen every event up to sequence number
+// after and queues the kept events since then that match filter. missed
+// reports that some of those events are no longer kept.
+func (h *Hub) Resume(filter *SubscriptionFilter, after uint64) (client *hubClient, missed bool) {
+	client = &hubClient{send: make(chan interface{}, clientSendBuffer+recentEvents)}
+	if filter != nil {
+		client.filter.Store(filter)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.recent) > 0 && h.recent[0].Seq > after+1 {
+		missed = true
+	}
+	for _, event := range h.recent {
+		if event.Seq > after && (filter == nil || filter.Matches(event.Event)) {
+			client.send <- event
+		}
+	}
+	h.clients[client] = struct{}{}
+	return client, missed
+}
+
+// Unregister is safe to call more than once; the client's send channel is
+// closed the first time so its writer loop exits. It reports whether this
+// call removed the client.
+func (h *Hub) Unregister(client *hubClient) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.clients[client]; !ok {
+		return false
+	}
+	delete(h.clients, client)
+	close(client.send)
+	return true
+}
+
+// CloseAll disconnects every client, ending their writer loops.
+func (h *Hub) CloseAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for client := range h.clients {
+		delete(h.clients, client)
+		close(client.send)
+	}
+}
+
+func (h *Hub) Broadcast(event BridgeEvent) {
+	var slow []*hubClient
+
+	h.mu.Lock()
+	h.seq++
+	sequenced := sequencedEvent{Seq: h.seq, Event: event}
+	h.recent = append(h.recent, sequenced)
+	if len(h.recent) > recentEvents {
+		h.recent = append(h.recent[:0], h.recent[len(h.recent)-recentEvents:]...)
+	}
+
+	for client := range h.clients {
+		if filter := client.filter.Load(); filter != nil && !filter.Matches(event) {
+			continue
+		}
+		select {
+		case client.send <- sequenced:
+			client.fullSince.Store(0)
+		default:
+			log.Printf("Client buffer full, dropping event %s", event.ID)
+			if h.stuck(client) {
+				slow = append(slow, client)
+			}
+		}
+	}
+	h.mu.Unlock()
+
+	for _, client := range slow {
+		if h.Unregister(client) {
+			h.evictions.Add(1)
+			websocketEvictions.Inc()
+			log.Printf("Evicted client whose buffer stayed full for over %s", h.evictAfter)
+		}
+	}
+}
+
+// stuck records that client's buffer is full and reports whether it has
+// been full for longer than evictAfter.
+func (h *Hub) stuck(client *hubClient) bool {
+	now := h.clock.Now().UnixNano()
+	if client.fullSince.CompareAndSwap(0, now) {
+		return false
+	}
+	return time.Duration(now-client.fullSince.Load()) > h.evictAfter
+}
+
+func (h *Hub) Evictions() uint64 {
+	return h.evictions.Load()
+}
+
+// Send queues a control frame for one client. It is a no-op once the client
+// is unregistered.
+func (h *Hub) Send(client *hubClient, frame interface{}) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if _, ok := h.clients[client]; !ok {
+		return
+	}
+	select {
+	case client.send <- frame:
+	default:
+		log.Printf("WebSocket client buffer full, dropping control frame")
+	}
+}
+
+func (h *Hub) Count() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Metric names are part of the dashboards built on them; don't rename.
+var (
+	bridgeEventsObserved = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "yhgs_bridge_lock_events_observed_total",
+		Help: "Bridge events recorded from chain logs, by source chain and type (lock or burn).",
+	}, []string{"chain", "type"})
+
+	mintsAttempted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "yhgs_bridge_mints_attempted_total",
+		Help: "Settlement transactions attempted, by target chain and method (mint or unlock).",
+	}, []string{"chain", "method"})
+
+	mintsSucceeded = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "yhgs_bridge_mints_succeeded_total",
+		Help: "Settlement transactions mined successfully, by target chain and method.",
+	}, []string{"chain", "method"})
+
+	mintsFailed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "yhgs_bridge_mints_failed_total",
+		Help: "Settlement transactions that failed to send or reverted, by target chain and method.",
+	}, []string{"chain", "method"})
+
+	mintsDeadLettered = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "yhgs_bridge_mints_dead_lettered_total",
+		Help: "Settlements given up on after exhausting their retries, by target chain and method.",
+	}, []string{"chain", "method"})
+
+	limitViolations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "yhgs_bridge_limit_violations_total",
+		Help: "Transfers held for exceeding a transfer limit, by source chain, target chain and rule (min, max or daily_cap).",
+	}, []string{"from_chain", "to_chain", "rule"})
+
+	webhookDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "yhgs_bridge_webhook_deliveries_total",
+		Help: "Webhook deliveries that finished, by webhook and outcome (delivered or failed).",
+	}, []string{"webhook", "outcome"})
+
+	webhookFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "yhgs_bridge_webhook_failures_total",
+		Help: "Failed webhook delivery attempts, by webhook.",
+	}, []string{"webhook"})
+
+	feeCapDeferrals = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "yhgs_bridge_fee_cap_deferrals_total",
+		Help: "Settlements parked because gas was above the chain's fee cap, by chain and method.",
+	}, []string{"chain", "method"})
+
+	feeBumps = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "yhgs_bridge_fee_bumps_total",
+		Help: "Stuck settlements sent again with a higher fee, by chain and method.",
+	}, []string{"chain", "method"})
+
+	callbackQueueDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "yhgs_bridge_callback_queue_depth",
+		Help: "Status updates queued for a callback and not yet accepted, by callback.",
+	}, []string{"callback"})
+
+	invalidTransitions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "yhgs_bridge_invalid_status_transitions_total",
+		Help: "Status changes rejected by the transfer lifecycle, by current and requested status.",
+	}, []string{"from", "to"})
+
+	mintLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "yhgs_bridge_mint_latency_seconds",
+		Help:    "Time from the block of a lock or burn to broadcasting its settlement transaction.",
+		Buckets: []float64{5, 15, 30, 60, 120, 300, 600, 1200, 3600},
+	}, []string{"chain", "method"})
+
+	subscriptionUp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "yhgs_bridge_subscription_up",
+		Help: "1 while the chain's log subscription is active, 0 otherwise.",
+	}, []string{"chain"})
+
+	websocketConnections = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "yhgs_bridge_websocket_connections_total",
+		Help: "Websocket connections accepted.",
+	})
+
+	sseConnections = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "yhgs_bridge_sse_connections_total",
+		Help: "Server-Sent Events connections accepted on /events.",
+	})
+
+	websocketEvictions = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "yhgs_bridge_websocket_evictions_total",
+		Help: "Websocket clients disconnected because their send buffer stayed full.",
+	})
+
+	rpcFailovers = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "yhgs_bridge_rpc_failovers_total",
+		Help: "Times a chain's active RPC endpoint changed.",
+	}, []string{"chain"})
+
+	rpcErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "yhgs_bridge_rpc_errors_total",
+		Help: "Failed RPC calls, by chain and call.",
+	}, []string{"chain", "call"})
+)
+
+// registerServiceMetrics exposes gauges read straight from the service.
+func (bs *BridgeService) registerServiceMetrics() {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "yhgs_bridge_event_queue_depth",
+		Help: "Events waiting in eventChan.",
+	}, func() float64 { return float64(len(bs.eventChan)) })
+
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "yhgs_bridge_websocket_clients",
+		Help: "Connected websocket and SSE clients.",
+	}, func() float64 { return float64(bs.hub.Count()) })
+}
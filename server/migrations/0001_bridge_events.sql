@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS bridge_events (
+    id           TEXT PRIMARY KEY,
+    event_type   TEXT NOT NULL,
+    from_chain   TEXT NOT NULL,
+    to_chain     TEXT NOT NULL,
+    nonce        TEXT NOT NULL,
+    tx_hash      TEXT NOT NULL,
+    sender       TEXT NOT NULL,
+    block_number BIGINT NOT NULL,
+    status       TEXT NOT NULL,
+    payload      TEXT NOT NULL,
+    created_at   BIGINT NOT NULL,
+    updated_at   BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_bridge_events_nonce ON bridge_events (from_chain, nonce);
+
+CREATE INDEX IF NOT EXISTS idx_bridge_events_status ON bridge_events (status);
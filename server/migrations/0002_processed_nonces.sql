@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS processed_nonces (
+    from_chain   TEXT NOT NULL,
+    nonce        TEXT NOT NULL,
+    event_id     TEXT NOT NULL,
+    processed_at BIGINT NOT NULL,
+    PRIMARY KEY (from_chain, nonce)
+);
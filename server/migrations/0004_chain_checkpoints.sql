@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS chain_checkpoints (
+    chain        TEXT PRIMARY KEY,
+    block_number BIGINT NOT NULL,
+    log_index    BIGINT NOT NULL,
+    updated_at   BIGINT NOT NULL
+);
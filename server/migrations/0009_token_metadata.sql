@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS token_metadata (
+    chain      TEXT NOT NULL,
+    token      TEXT NOT NULL,
+    symbol     TEXT NOT NULL,
+    name       TEXT NOT NULL,
+    decimals   INTEGER,
+    fetched_at BIGINT NOT NULL,
+    PRIMARY KEY (chain, token)
+);
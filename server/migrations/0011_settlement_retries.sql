@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS settlement_retries (
+    event_id     TEXT PRIMARY KEY,
+    method       TEXT NOT NULL,
+    attempts     INTEGER NOT NULL,
+    next_attempt BIGINT NOT NULL,
+    last_error   TEXT NOT NULL,
+    tx_hashes    TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_settlement_retries_next ON settlement_retries (next_attempt);
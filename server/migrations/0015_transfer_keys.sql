@@ -0,0 +1,11 @@
+ALTER TABLE bridge_events ADD COLUMN transfer_key TEXT NOT NULL DEFAULT '';
+
+UPDATE bridge_events SET transfer_key = from_chain || ':' || nonce;
+
+CREATE INDEX IF NOT EXISTS idx_bridge_events_transfer_key ON bridge_events (transfer_key);
+
+ALTER TABLE processed_nonces ADD COLUMN transfer_key TEXT NOT NULL DEFAULT '';
+
+UPDATE processed_nonces SET transfer_key = from_chain || ':' || nonce;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_processed_nonces_transfer_key ON processed_nonces (transfer_key);
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const mintSendTimeout = 30 * time.Second
+
+// sendBridgeCall sends method (mint or unlock, which take the same arguments)
+// to the bridge contract on the event's target chain, paying out amount of
+// token in its smallest unit. It also returns the gas settings used. The
+// transaction is recorded on retry before it is sent. When retry holds the
+// nonce of an earlier attempt that may still be mined, the call is sent with
+// that nonce instead of a newly allocated one, priced to replace the earlier
+// transaction; the nonce stays held whatever happens.
+func (bs *BridgeService) sendBridgeCall(event BridgeEvent, method string, token common.Address, amount *big.Int, retry *SettlementRetry) (*types.Transaction, *GasParams, error) {
+	chain, ok := bs.chains.GetChain(event.ToChain)
+	if !ok {
+		return nil, nil, fmt.Errorf("no config for target chain %s", event.ToChain)
+	}
+	client, _ := bs.chains.GetClient(event.ToChain)
+	contract, _ := bs.chains.GetContract(event.ToChain)
+
+	calldata, err := bs.packBridgeCall(chain.ContractVersion, method, event, token, amount)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mintSendTimeout)
+	defer cancel()
+
+	heldNonce := retry.PendingNonce
+	nonces := bs.nonceManager(event.ToChain)
+	var accountNonce uint64
+	if heldNonce != nil {
+		accountNonce = *heldNonce
+	} else if accountNonce, err = nonces.Allocate(ctx); err != nil {
+		return nil, nil, err
+	}
+	release := func() {
+		if heldNonce == nil {
+			nonces.Release(accountNonce)
+		}
+	}
+
+	from := bs.signer.Address()
+	tx, baseFee, err := buildSettlementTx(ctx, chain, client, from, contract, accountNonce, calldata)
+	if err == nil && heldNonce != nil && retry.PendingGas != nil {
+		tx, baseFee, err = bs.outbid(ctx, chain, client, tx, *retry.PendingGas)
+	}
+	if err != nil {
+		release()
+		return nil, nil, err
+	}
+	gas := gasParamsOf(tx, baseFee)
+	signedTx, err := bs.signer.SignTx(tx, new(big.Int).SetUint64(chain.ChainID))
+	if err != nil {
+		release()
+		return nil, gas, fmt.Errorf("failed to sign %s: %v", method, err)
+	}
+
+	// The transaction is recorded before it goes out, so that a settlement
+	// resumed after the relayer stopped finds it and holds its nonce instead
+	// of sending a second one beside it.
+	previousGas := retry.PendingGas
+	retry.TxHashes = append(retry.TxHashes, signedTx.Hash().Hex())
+	retry.PendingNonce, retry.PendingGas = &accountNonce, gas
+	retry.NextAttempt = bs.clock.Now().Add(bs.retry.backoff(retry.Attempts + 1))
+	if err := bs.store.SaveRetry(*retry); err != nil {
+		release()
+		retry.TxHashes = retry.TxHashes[:len(retry.TxHashes)-1]
+		retry.PendingNonce, retry.PendingGas = heldNonce, previousGas
+		return nil, gas, fmt.Errorf("failed to record %s before sending it: %v", method, err)
+	}
+
+	// The signed transaction is returned even if broadcasting it failed: the
+	// node may have accepted it anyway, and a retry checks for its receipt.
+	// Whether it did decides if the nonce is used, so ask the node.
+	if err := client.SendTransaction(ctx, signedTx); err != nil {
+		rpcErrors.WithLabelValues(event.ToChain, "send_transaction").Inc()
+		if heldNonce != nil {
+			return signedTx, gas, fmt.Errorf("failed to send %s: %v", method, err)
+		}
+		nonces.Done(accountNonce)
+		retry.PendingNonce, retry.PendingGas = nil, nil
+		if syncErr := nonces.Resync(ctx); syncErr != nil {
+			log.Printf("Failed to resync relayer nonce on %s: %v", event.ToChain, syncErr)
+		}
+		return signedTx, gas, fmt.Errorf("failed to send %s: %v", method, err)
+	}
+	return signedTx, gas, nil
+}
+
+func (bs *BridgeService) packBridgeCall(contractVersion, method string, event BridgeEvent, token common.Address, amount *big.Int) ([]byte, error) {
+	contractABI, err := bs.events.ABI(contractVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	recipient, err := parseRecipient(event.Recipient)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ParseTransferKey(event.TransferKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := key.EVMNonce()
+	if err != nil {
+		return nil, err
+	}
+
+	return contractABI.Pack(method, token, recipient, amount, nonce)
+}
+
+// parseRecipient accepts a 0x-hex address or the raw 20 address bytes a lock
+// may carry in targetAddr.
+func parseRecipient(recipient string) (common.Address, error) {
+	if common.IsHexAddress(recipient) {
+		return common.HexToAddress(recipient), nil
+	}
+	if len(recipient) == common.AddressLength {
+		return common.BytesToAddress([]byte(recipient)), nil
+	}
+	return common.Address{}, fmt.Errorf("recipient %q is not an EVM address", recipient)
+}
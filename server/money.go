@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// All token arithmetic goes through this file. Amounts are integers in a
+// token's smallest unit together with the number of decimals that unit
+// represents. Scale is exact: scaling down refuses to drop non-zero digits.
+var (
+	ErrNegativeAmount = errors.New("amount is negative")
+	ErrPrecisionLoss  = errors.New("amount is not representable at target scale")
+	ErrAmountOverflow = errors.New("amount exceeds uint256")
+)
+
+var maxUint256 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+
+type Amount struct {
+	Value *big.Int
+	Scale uint8
+}
+
+func NewAmount(value *big.Int, scale uint8) (Amount, error) {
+	if value == nil || value.Sign() < 0 {
+		return Amount{}, ErrNegativeAmount
+	}
+	if value.Cmp(maxUint256) > 0 {
+		return Amount{}, ErrAmountOverflow
+	}
+	return Amount{Value: new(big.Int).Set(value), Scale: scale}, nil
+}
+
+func (a Amount) String() string {
+	if a.Value == nil {
+		return "0"
+	}
+	return a.Value.String()
+}
+
+// Scale re-expresses a at the given number of decimals. Scaling down fails with
+// ErrPrecisionLoss rather than silently dropping dust.
+func Scale(a Amount, to uint8) (Amount, error) {
+	if a.Value == nil || a.Value.Sign() < 0 {
+		return Amount{}, ErrNegativeAmount
+	}
+
+	value := new(big.Int).Set(a.Value)
+	switch {
+	case to > a.Scale:
+		value.Mul(value, pow10(to-a.Scale))
+		if value.Cmp(maxUint256) > 0 {
+			return Amount{}, ErrAmountOverflow
+		}
+	case to < a.Scale:
+		quotient, remainder := new(big.Int).QuoRem(value, pow10(a.Scale-to), new(big.Int))
+		if remainder.Sign() != 0 {
+			return Amount{}, fmt.Errorf("%w: %s at %d decimals leaves %s at %d decimals",
+				ErrPrecisionLoss, a.Value, a.Scale, remainder, a.Scale)
+		}
+		value = quotient
+	}
+	return Amount{Value: value, Scale: to}, nil
+}
+
+// Whole expresses a in whole tokens, exactly, so amounts of tokens with
+// different decimals can be compared and summed.
+func (a Amount) Whole() *big.Rat {
+	return new(big.Rat).SetFrac(a.Value, pow10(a.Scale))
+}
+
+// ParseWhole parses a non-negative decimal number of whole tokens, such as
+// "2500" or "0.05".
+func ParseWhole(s string) (*big.Rat, error) {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok || strings.ContainsAny(s, "/eE") {
+		return nil, fmt.Errorf("invalid decimal amount %q", s)
+	}
+	if r.Sign() < 0 {
+		return nil, ErrNegativeAmount
+	}
+	return r, nil
+}
+
+// FormatWhole renders a whole-token quantity as a plain decimal with no
+// trailing zeros. Quantities from Whole and ParseWhole always terminate.
+func FormatWhole(r *big.Rat) string {
+	digits := 0
+	for scaled := new(big.Rat).Set(r); !scaled.IsInt() && digits < 255; digits++ {
+		scaled.Mul(scaled, big.NewRat(10, 1))
+	}
+	return r.FloatString(digits)
+}
+
+func pow10(n uint8) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+// fuzzAmount turns fuzzer bytes into a uint256 amount.
+func fuzzAmount(raw []byte, scale uint8) Amount {
+	if len(raw) > 32 {
+		raw = raw[:32]
+	}
+	return Amount{Value: new(big.Int).SetBytes(raw), Scale: scale}
+}
+
+func TestScale(t *testing.T) {
+	tests := []struct {
+		value    string
+		from, to uint8
+		want     string
+		err      error
+	}{
+		{"1000000", 6, 18, "1000000000000000000", nil},
+		{"1000000000000000000", 18, 6, "1000000", nil},
+		{"1000000000000000001", 18, 6, "", ErrPrecisionLoss},
+		{"12345", 8, 8, "12345", nil},
+		{"0", 18, 0, "0", nil},
+		{maxUint256.String(), 0, 1, "", ErrAmountOverflow},
+	}
+	for _, tt := range tests {
+		value, _ := new(big.Int).SetString(tt.value, 10)
+		got, err := Scale(Amount{Value: value, Scale: tt.from}, tt.to)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Scale(%s, %d -> %d) error = %v, want %v", tt.value, tt.from, tt.to, err, tt.err)
+			continue
+		}
+		if err == nil && (got.String() != tt.want || got.Scale != tt.to) {
+			t.Errorf("Scale(%s, %d -> %d) = %s at %d", tt.value, tt.from, tt.to, got, got.Scale)
+		}
+	}
+}
+
+func FuzzScale(f *testing.F) {
+	f.Add([]byte{0x0f, 0x42, 0x40}, uint8(6), uint8(18))
+	f.Add([]byte{0x0d, 0xe0, 0xb6, 0xb3, 0xa7, 0x64, 0x00, 0x00}, uint8(18), uint8(6))
+	f.Add([]byte{0x01}, uint8(0), uint8(77))
+	f.Add([]byte{}, uint8(255), uint8(0))
+	f.Fuzz(func(t *testing.T, raw []byte, from, to uint8) {
+		a := fuzzAmount(raw, from)
+		scaled, err := Scale(a, to)
+		switch {
+		case errors.Is(err, ErrAmountOverflow):
+			if to <= from {
+				t.Fatalf("scaling %s down from %d to %d overflowed", a, from, to)
+			}
+			return
+		case errors.Is(err, ErrPrecisionLoss):
+			if to >= from {
+				t.Fatalf("scaling %s up from %d to %d lost precision", a, from, to)
+			}
+			return
+		case err != nil:
+			t.Fatal(err)
+		}
+
+		if scaled.Scale != to || scaled.Value.Cmp(maxUint256) > 0 {
+			t.Fatalf("Scale(%s, %d -> %d) = %s at %d", a, from, to, scaled, scaled.Scale)
+		}
+		if scaled.Whole().Cmp(a.Whole()) != 0 {
+			t.Fatalf("Scale(%s, %d -> %d) changed the whole amount to %s", a, from, to, scaled)
+		}
+		back, err := Scale(scaled, from)
+		if err != nil || back.Value.Cmp(a.Value) != 0 {
+			t.Fatalf("scaling %s back to %d = %v, %v; want %s", scaled, from, back, err, a)
+		}
+	})
+}
+
+func FuzzParseFormatWhole(f *testing.F) {
+	for _, seed := range []string{"0", "2500", "0.05", "1.000", "007.10", "123456789.987654321"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		r, err := ParseWhole(s)
+		if err != nil {
+			return
+		}
+		if r.Sign() < 0 {
+			t.Fatalf("ParseWhole(%q) = %s", s, r)
+		}
+		// FormatWhole renders at most 255 decimals, the most a token has.
+		if r.Denom().Cmp(pow10(255)) > 0 {
+			return
+		}
+		formatted := FormatWhole(r)
+		back, err := ParseWhole(formatted)
+		if err != nil || back.Cmp(r) != 0 {
+			t.Fatalf("ParseWhole(%q) = %s, formatted %q, parsed back as %v, %v", s, r.RatString(), formatted, back, err)
+		}
+		if FormatWhole(back) != formatted {
+			t.Fatalf("%q formats differently after a round trip", formatted)
+		}
+	})
+}
+
+func FuzzWholeRoundTrip(f *testing.F) {
+	f.Add([]byte{0x0f, 0x42, 0x40}, uint8(6))
+	f.Add([]byte{0x01}, uint8(18))
+	f.Add([]byte{}, uint8(0))
+	f.Fuzz(func(t *testing.T, raw []byte, scale uint8) {
+		a := fuzzAmount(raw, scale)
+		formatted := FormatWhole(a.Whole())
+		r, err := ParseWhole(formatted)
+		if err != nil {
+			t.Fatalf("FormatWhole(%s at %d) = %q: %v", a, scale, formatted, err)
+		}
+		back := new(big.Rat).Mul(r, new(big.Rat).SetInt(pow10(scale)))
+		if !back.IsInt() || back.Num().Cmp(a.Value) != 0 {
+			t.Fatalf("%s at %d formatted as %q parses back to %s", a, scale, formatted, back.RatString())
+		}
+	})
+}
+
+func TestParseWholeRejects(t *testing.T) {
+	for _, s := range []string{"", "abc", "1/3", "1e18", "1E3", "-1", "-0.5"} {
+		if r, err := ParseWhole(s); err == nil {
+			t.Errorf("ParseWhole(%q) = %s", s, r)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+)
+
+// NonceManager hands out the relayer account's nonces on one chain, so
+// concurrent settlements each get their own without waiting on one another's
+// broadcast. Nonces are tracked from allocation until their transaction is
+// done with. A nonce whose transaction was never broadcast is given back and
+// handed out again first, so it doesn't leave a gap that holds up every
+// later transaction.
+type NonceManager struct {
+	chain string
+	// pending reads the account's next nonce from the node, counting
+	// transactions in its pool.
+	pending func(ctx context.Context) (uint64, error)
+
+	mu       sync.Mutex
+	synced   bool
+	next     uint64
+	inFlight map[uint64]bool
+	free     []uint64
+}
+
+func NewNonceManager(chain string, pending func(ctx context.Context) (uint64, error)) *NonceManager {
+	return &NonceManager{chain: chain, pending: pending, inFlight: make(map[uint64]bool)}
+}
+
+// Allocate returns the lowest free nonce, syncing with the node first if
+// the manager hasn't yet.
+func (m *NonceManager) Allocate(ctx context.Context) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.synced {
+		if err := m.resyncLocked(ctx); err != nil {
+			return 0, err
+		}
+	}
+
+	var nonce uint64
+	if len(m.free) > 0 {
+		nonce, m.free = m.free[0], m.free[1:]
+	} else {
+		nonce = m.next
+		m.next++
+	}
+	m.inFlight[nonce] = true
+	return nonce, nil
+}
+
+// Release gives back a nonce whose transaction was never broadcast.
+func (m *NonceManager) Release(nonce uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.inFlight[nonce] {
+		return
+	}
+	delete(m.inFlight, nonce)
+	if nonce == m.next-1 {
+		m.next--
+		return
+	}
+	m.addFreeLocked(nonce)
+}
+
+// Hold tracks nonce as in flight without handing it out, for a transaction
+// sent before a restart that may still be mined.
+func (m *NonceManager) Hold(nonce uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inFlight[nonce] = true
+	if !m.synced {
+		return
+	}
+	for i, free := range m.free {
+		if free == nonce {
+			m.free = append(m.free[:i], m.free[i+1:]...)
+			break
+		}
+	}
+	for ; m.next <= nonce; m.next++ {
+		if m.next < nonce {
+			m.addFreeLocked(m.next)
+		}
+	}
+}
+
+// Done stops tracking a nonce whose transaction was mined or given up on.
+// The node accounts for it from then on.
+func (m *NonceManager) Done(nonce uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.inFlight, nonce)
+}
+
+// Resync re-reads the account's nonce from the node, e.g. after it rejected
+// a transaction's nonce. Nonces below it are used and forgotten. Nonces
+// between it and the next one to hand out that no tracked transaction holds
+// are gaps and are handed out again.
+func (m *NonceManager) Resync(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.resyncLocked(ctx)
+}
+
+func (m *NonceManager) resyncLocked(ctx context.Context) error {
+	pending, err := m.pending(ctx)
+	if err != nil {
+		rpcErrors.WithLabelValues(m.chain, "pending_nonce").Inc()
+		return fmt.Errorf("failed to fetch relayer nonce: %v", err)
+	}
+
+	for nonce := range m.inFlight {
+		if nonce < pending {
+			delete(m.inFlight, nonce)
+		}
+	}
+	if !m.synced || pending > m.next {
+		if m.synced {
+			log.Printf("Relayer nonce on %s moved ahead to %d from %d, another sender may be using the account", m.chain, pending, m.next)
+		}
+		m.next = pending
+	}
+	for nonce := range m.inFlight {
+		if nonce >= m.next {
+			m.next = nonce + 1
+		}
+	}
+
+	m.free = nil
+	for nonce := pending; nonce < m.next; nonce++ {
+		if !m.inFlight[nonce] {
+			m.addFreeLocked(nonce)
+		}
+	}
+	if m.synced && len(m.free) > 0 {
+		log.Printf("Relayer nonces %v on %s have no transaction in the node's pool and will be reused", m.free, m.chain)
+	}
+	m.synced = true
+	return nil
+}
+
+func (m *NonceManager) addFreeLocked(nonce uint64) {
+	i := sort.Search(len(m.free), func(i int) bool { return m.free[i] >= nonce })
+	if i < len(m.free) && m.free[i] == nonce {
+		return
+	}
+	m.free = append(m.free, 0)
+	copy(m.free[i+1:], m.free[i:])
+	m.free[i] = nonce
+}
+
+// nonceManager returns the relayer's nonce manager for chainName, creating
+// it on first use.
+func (bs *BridgeService) nonceManager(chainName string) *NonceManager {
+	if m, ok := bs.nonces.Load(chainName); ok {
+		return m.(*NonceManager)
+	}
+	m, _ := bs.nonces.LoadOrStore(chainName, NewNonceManager(chainName, func(ctx context.Context) (uint64, error) {
+		client, ok := bs.chains.GetClient(chainName)
+		if !ok {
+			return 0, errUnknownChain(chainName)
+		}
+		return client.PendingNonceAt(ctx, bs.signer.Address())
+	}))
+	return m.(*NonceManager)
+}
+
+// syncNonces reads the relayer's nonce on every chain at startup. A chain
+// that can't be read now is synced on its first settlement instead. Nonces
+// that retries hold for transactions which may still be mined are held
+// again first, so no other settlement is given them.
+func (bs *BridgeService) syncNonces() {
+	held, err := bs.store.HeldNonces()
+	if err != nil {
+		log.Printf("Failed to load the nonces retries hold: %v", err)
+	}
+	for _, chainName := range bs.chainNames() {
+		nonces := bs.nonceManager(chainName)
+		for _, nonce := range held[chainName] {
+			nonces.Hold(nonce)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), mintSendTimeout)
+		if err := nonces.Resync(ctx); err != nil {
+			log.Printf("Failed to sync relayer nonce on %s: %v", chainName, err)
+		}
+		cancel()
+	}
+}
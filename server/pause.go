@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// pauseAll is the scope of the bridge-wide pause; other scopes are chain
+// names.
+const pauseAll = "*"
+
+type PauseState struct {
+	Scope  string    `json:"scope"`
+	Reason string    `json:"reason"`
+	Actor  string    `json:"actor"`
+	Since  time.Time `json:"since"`
+}
+
+// Pauses holds settlement back for paused chains. Listeners keep recording
+// locks and burns; only promotion to mint or unlock waits.
+type Pauses struct {
+	mu     sync.RWMutex
+	scopes map[string]PauseState
+}
+
+func NewPauses() *Pauses {
+	return &Pauses{scopes: make(map[string]PauseState)}
+}
+
+// Blocks reports the pause, if any, that holds event back: the global one or
+// one on either of its chains.
+func (p *Pauses) Blocks(event BridgeEvent) (PauseState, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, scope := range []string{pauseAll, event.FromChain, event.ToChain} {
+		if state, ok := p.scopes[scope]; ok {
+			return state, true
+		}
+	}
+	return PauseState{}, false
+}
+
+func (p *Pauses) set(state PauseState) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.scopes[state.Scope] = state
+}
+
+func (p *Pauses) clear(scope string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, ok := p.scopes[scope]
+	delete(p.scopes, scope)
+	return ok
+}
+
+func (p *Pauses) Snapshot() []PauseState {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	states := make([]PauseState, 0, len(p.scopes))
+	for _, state := range p.scopes {
+		states = append(states, state)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Scope < states[j].Scope })
+	return states
+}
+
+func (bs *BridgeService) loadPauses() error {
+	states, err := bs.store.ListPauses()
+	if err != nil {
+		return err
+	}
+	for _, state := range states {
+		log.Printf("Settlement paused for %s since %s by %s: %s", describeScope(state.Scope), state.Since.Format(time.RFC3339), state.Actor, state.Reason)
+		bs.pauses.set(state)
+	}
+	return nil
+}
+
+func describeScope(scope string) string {
+	if scope == pauseAll {
+		return "the whole bridge"
+	}
+	return scope
+}
+
+// holdPaused parks a confirmed lock or burn until its pause is lifted.
+func (bs *BridgeService) holdPaused(event BridgeEvent, pause PauseState) {
+	if !bs.TransitionStatus(&event, StatusPaused) {
+		return
+	}
+	if err := bs.store.UpdateStatus(event.ID, event.Status); err != nil {
+		log.Printf("Failed to persist status of %s: %v", event.ID, err)
+	}
+	log.Printf("Holding %s %s: settlement paused for %s", event.Type, event.ID, describeScope(pause.Scope))
+
+	bs.updateTransactionStatus(event)
+	bs.broadcastEvent(event)
+}
+
+// releasePaused promotes held events that no pause blocks any more.
+func (bs *BridgeService) releasePaused() {
+	filter := EventFilter{Statuses: []TransferStatus{StatusPaused}, Limit: maxPageSize}
+	var held []BridgeEvent
+	for {
+		events, next, err := bs.store.ListEvents(filter)
+		if err != nil {
+			log.Printf("Failed to load paused events: %v", err)
+			return
+		}
+		held = append(held, events...)
+		if next == nil {
+			break
+		}
+		filter.Cursor = next
+	}
+
+	released := 0
+	for _, event := range held {
+		if _, blocked := bs.pauses.Blocks(event); blocked {
+			continue
+		}
+		bs.promoteConfirmed(event)
+		released++
+	}
+	if released > 0 {
+		log.Printf("Released %d paused transfers", released)
+	}
+}
+
+type pauseRequest struct {
+	Reason string `json:"reason"`
+}
+
+func (bs *BridgeService) handlePause(w http.ResponseWriter, r *http.Request) {
+	bs.changePause(w, r, pauseAll, true)
+}
+
+func (bs *BridgeService) handleResume(w http.ResponseWriter, r *http.Request) {
+	bs.changePause(w, r, pauseAll, false)
+}
+
+func (bs *BridgeService) handlePauseChain(w http.ResponseWriter, r *http.Request) {
+	bs.changeChainPause(w, r, true)
+}
+
+func (bs *BridgeService) handleResumeChain(w http.ResponseWriter, r *http.Request) {
+	bs.changeChainPause(w, r, false)
+}
+
+func (bs *BridgeService) changeChainPause(w http.ResponseWriter, r *http.Request, pause bool) {
+	chainName := mux.Vars(r)["name"]
+	if !bs.chains.Has(chainName) {
+		writeError(w, http.StatusNotFound, "unknown chain")
+		return
+	}
+	bs.changePause(w, r, chainName, pause)
+}
+
+func (bs *BridgeService) changePause(w http.ResponseWriter, r *http.Request, scope string, pause bool) {
+	var req pauseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Reason) == "" {
+		writeError(w, http.StatusBadRequest, "a reason is required")
+		return
+	}
+
+	action := "resume"
+	if pause {
+		action = "pause"
+	}
+	state := PauseState{Scope: scope, Reason: req.Reason, Actor: requestActor(r), Since: bs.clock.Now()}
+	if err := bs.store.RecordPause(state, action); err != nil {
+		log.Printf("Failed to record %s of %s: %v", action, describeScope(scope), err)
+		writeError(w, http.StatusInternalServerError, "failed to record "+action)
+		return
+	}
+	log.Printf("AUDIT: %s %s settlement for %s: %s", state.Actor, action+"d", describeScope(scope), state.Reason)
+
+	if pause {
+		bs.pauses.set(state)
+	} else if bs.pauses.clear(scope) {
+		go bs.releasePaused()
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"paused": bs.pauses.Snapshot()})
+}
+
+func (bs *BridgeService) registerPauseRoutes(router *mux.Router) {
+	admin := bs.auth.Require(roleAdmin)
+	router.Handle("/admin/pause", admin(http.HandlerFunc(bs.handlePause))).Methods(http.MethodPost)
+	router.Handle("/admin/resume", admin(http.HandlerFunc(bs.handleResume))).Methods(http.MethodPost)
+	router.Handle("/admin/chains/{name}/pause", admin(http.HandlerFunc(bs.handlePauseChain))).Methods(http.MethodPost)
+	router.Handle("/admin/chains/{name}/resume", admin(http.HandlerFunc(bs.handleResumeChain))).Methods(http.MethodPost)
+}
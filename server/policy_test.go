@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func newTestPolicy(t *testing.T, cfg SignerPolicyConfig) *SignerPolicy {
+	t.Helper()
+	registry, err := LoadEventRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.applyDefaults()
+	chains := map[string]ChainConfig{
+		testSourceChain: {Name: testSourceChain, ChainID: testChainIDs[testSourceChain], Contract: testBridges[testSourceChain].Hex(), ContractVersion: defaultContractVersion},
+		testTargetChain: {Name: testTargetChain, ChainID: testChainIDs[testTargetChain], Contract: testBridges[testTargetChain].Hex(), ContractVersion: defaultContractVersion},
+	}
+	policy, err := NewSignerPolicy(cfg, chains, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return policy
+}
+
+// bridgeCalldata packs method of the v1 bridge ABI with test arguments.
+func bridgeCalldata(t *testing.T, method string) []byte {
+	t.Helper()
+	registry, err := LoadEventRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	contractABI, err := registry.ABI(defaultContractVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := contractABI.Pack(method, testWrapped, testRecipient, big.NewInt(1000), [32]byte{31: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func erc20TransferCalldata() []byte {
+	data := append([]byte{}, crypto.Keccak256([]byte("transfer(address,uint256)"))[:4]...)
+	data = append(data, common.LeftPadBytes(testRecipient.Bytes(), 32)...)
+	return append(data, common.LeftPadBytes(big.NewInt(1000).Bytes(), 32)...)
+}
+
+func TestSignerPolicy(t *testing.T) {
+	mint := bridgeCalldata(t, "mint")
+	bridge, otherBridge := testBridges[testTargetChain], testBridges[testSourceChain]
+	chainID := new(big.Int).SetUint64(testChainIDs[testTargetChain])
+	gwei := big.NewInt(params.GWei)
+
+	tests := []struct {
+		name    string
+		chainID *big.Int
+		tx      types.TxData
+		allowed bool
+	}{
+		{"mint on the bridge", chainID,
+			&types.DynamicFeeTx{To: &bridge, Gas: 100000, GasFeeCap: gwei, GasTipCap: gwei, Data: mint}, true},
+		{"unlock on the bridge", chainID,
+			&types.LegacyTx{To: &bridge, Gas: 100000, GasPrice: gwei, Data: bridgeCalldata(t, "unlock")}, true},
+		{"erc20 transfer on the bridge", chainID,
+			&types.DynamicFeeTx{To: &bridge, Gas: 100000, GasFeeCap: gwei, GasTipCap: gwei, Data: erc20TransferCalldata()}, false},
+		{"erc20 transfer on the token", chainID,
+			&types.DynamicFeeTx{To: &testWrapped, Gas: 100000, GasFeeCap: gwei, GasTipCap: gwei, Data: erc20TransferCalldata()}, false},
+		{"arbitrary selector", chainID,
+			&types.DynamicFeeTx{To: &bridge, Gas: 100000, GasFeeCap: gwei, GasTipCap: gwei, Data: []byte{0xde, 0xad, 0xbe, 0xef, 0x00}}, false},
+		{"mint on another contract", chainID,
+			&types.DynamicFeeTx{To: &testToken, Gas: 100000, GasFeeCap: gwei, GasTipCap: gwei, Data: mint}, false},
+		{"mint on the other chain's bridge", chainID,
+			&types.DynamicFeeTx{To: &otherBridge, Gas: 100000, GasFeeCap: gwei, GasTipCap: gwei, Data: mint}, false},
+		{"plain value transfer", chainID,
+			&types.DynamicFeeTx{To: &testRecipient, Gas: 21000, GasFeeCap: gwei, GasTipCap: gwei, Value: big.NewInt(1)}, false},
+		{"mint with value", chainID,
+			&types.DynamicFeeTx{To: &bridge, Gas: 100000, GasFeeCap: gwei, GasTipCap: gwei, Value: big.NewInt(1), Data: mint}, false},
+		{"contract creation", chainID,
+			&types.DynamicFeeTx{Gas: 100000, GasFeeCap: gwei, GasTipCap: gwei, Data: mint}, false},
+		{"calldata without selector", chainID,
+			&types.DynamicFeeTx{To: &bridge, Gas: 100000, GasFeeCap: gwei, GasTipCap: gwei, Data: []byte{0x40, 0xc1}}, false},
+		{"gas limit above cap", chainID,
+			&types.DynamicFeeTx{To: &bridge, Gas: defaultMaxGasLimit + 1, GasFeeCap: gwei, GasTipCap: gwei, Data: mint}, false},
+		{"fee cap above cap", chainID,
+			&types.DynamicFeeTx{To: &bridge, Gas: 100000, GasFeeCap: new(big.Int).Mul(big.NewInt(defaultMaxGasPriceGwei+1), gwei), GasTipCap: gwei, Data: mint}, false},
+		{"unknown chain", big.NewInt(56),
+			&types.DynamicFeeTx{To: &bridge, Gas: 100000, GasFeeCap: gwei, GasTipCap: gwei, Data: mint}, false},
+		{"chain id beyond uint64", new(big.Int).Lsh(big.NewInt(1), 70),
+			&types.LegacyTx{To: &bridge, Gas: 100000, GasPrice: gwei, Data: mint}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := newTestPolicy(t, SignerPolicyConfig{})
+			err := policy.Check(types.NewTx(tt.tx), tt.chainID)
+			if tt.allowed {
+				if err != nil {
+					t.Fatalf("refused: %v", err)
+				}
+				if policy.Refusals() != 0 {
+					t.Errorf("counted %d refusals", policy.Refusals())
+				}
+				return
+			}
+			if !errors.Is(err, ErrPolicyViolation) {
+				t.Fatalf("err = %v, want a policy violation", err)
+			}
+			if policy.Refusals() != 1 {
+				t.Errorf("counted %d refusals, want 1", policy.Refusals())
+			}
+		})
+	}
+}
+
+func TestSignerPolicyOnlyAllowsConfiguredMethods(t *testing.T) {
+	policy := newTestPolicy(t, SignerPolicyConfig{AllowedMethods: []string{"mint"}})
+	bridge := testBridges[testTargetChain]
+	chainID := new(big.Int).SetUint64(testChainIDs[testTargetChain])
+
+	unlock := types.NewTx(&types.LegacyTx{To: &bridge, Gas: 100000, GasPrice: big.NewInt(params.GWei), Data: bridgeCalldata(t, "unlock")})
+	if err := policy.Check(unlock, chainID); !errors.Is(err, ErrPolicyViolation) {
+		t.Errorf("unlock with only mint allowed: err = %v", err)
+	}
+	mint := types.NewTx(&types.LegacyTx{To: &bridge, Gas: 100000, GasPrice: big.NewInt(params.GWei), Data: bridgeCalldata(t, "mint")})
+	if err := policy.Check(mint, chainID); err != nil {
+		t.Errorf("mint refused: %v", err)
+	}
+}
+
+func TestSignerRefusesWithoutSigning(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := newSigner(key)
+	bridge := testBridges[testTargetChain]
+	chainID := new(big.Int).SetUint64(testChainIDs[testTargetChain])
+	transfer := types.NewTx(&types.LegacyTx{To: &bridge, Gas: 100000, GasPrice: big.NewInt(params.GWei), Data: erc20TransferCalldata()})
+
+	if signed, err := signer.SignTx(transfer, chainID); !errors.Is(err, ErrPolicyViolation) || signed != nil {
+		t.Errorf("signed without a policy: %v, %v", signed, err)
+	}
+
+	policy := newTestPolicy(t, SignerPolicyConfig{})
+	signer.SetPolicy(policy)
+	if signed, err := signer.SignTx(transfer, chainID); !errors.Is(err, ErrPolicyViolation) || signed != nil {
+		t.Errorf("signed an erc20 transfer: %v, %v", signed, err)
+	}
+	if policy.Refusals() != 1 {
+		t.Errorf("counted %d refusals, want 1", policy.Refusals())
+	}
+
+	mint := types.NewTx(&types.LegacyTx{To: &bridge, Gas: 100000, GasPrice: big.NewInt(params.GWei), Data: bridgeCalldata(t, "mint")})
+	signed, err := signer.SignTx(mint, chainID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	from, err := types.Sender(types.LatestSignerForChainID(chainID), signed)
+	if err != nil || from != signer.Address() {
+		t.Errorf("signed by %s (%v), want %s", from.Hex(), err, signer.Address().Hex())
+	}
+}
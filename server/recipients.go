@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const addressFormatEVM = "evm"
+
+// An AddressFormat checks the raw targetAddr bytes of a lock or burn against
+// the destination chain's address format and returns the canonical form
+// stored as the transfer's recipient.
+type AddressFormat func(raw []byte) (string, error)
+
+// addressFormats are the formats a chain's addressFormat can name.
+var addressFormats = map[string]AddressFormat{
+	addressFormatEVM: evmRecipient,
+}
+
+// evmRecipient accepts exactly 20 address bytes or a 0x-hex address string
+// and returns it checksummed. The zero address is rejected: anything sent
+// there is lost.
+func evmRecipient(raw []byte) (string, error) {
+	var address common.Address
+	switch {
+	case len(raw) == common.AddressLength:
+		address = common.BytesToAddress(raw)
+	case len(raw) == 2+2*common.AddressLength && common.IsHexAddress(string(raw)):
+		address = common.HexToAddress(string(raw))
+	default:
+		return "", fmt.Errorf("recipient is %d bytes, not a 20-byte or 0x-hex EVM address", len(raw))
+	}
+	if address == (common.Address{}) {
+		return "", errors.New("recipient is the zero address")
+	}
+	return address.Hex(), nil
+}
+
+// normalizeRecipient validates raw as an address on chainName. Chains that
+// aren't configured are checked as EVM chains.
+func (bs *BridgeService) normalizeRecipient(chainName string, raw []byte) (string, error) {
+	format := addressFormatEVM
+	if chain, ok := bs.chains.GetChain(chainName); ok && chain.AddressFormat != "" {
+		format = chain.AddressFormat
+	}
+	return addressFormats[format](raw)
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/AIhangzhou56/YHGS-Bridge/server/testutil"
+)
+
+// The test bridge runs between two mock chains. Locks on testSourceChain
+// mint testWrapped on testTargetChain.
+const (
+	testSourceChain = "ethereum"
+	testTargetChain = "polygon"
+
+	testConfirmations = 2
+)
+
+var (
+	testToken     = common.HexToAddress("0x1000000000000000000000000000000000000001")
+	testWrapped   = common.HexToAddress("0x2000000000000000000000000000000000000002")
+	testSender    = common.HexToAddress("0x3000000000000000000000000000000000000003")
+	testRecipient = common.HexToAddress("0x4000000000000000000000000000000000000004")
+
+	testChainIDs = map[string]uint64{testSourceChain: 1, testTargetChain: 137}
+	testBridges  = map[string]common.Address{
+		testSourceChain: common.HexToAddress("0xb000000000000000000000000000000000000001"),
+		testTargetChain: common.HexToAddress("0xb000000000000000000000000000000000000002"),
+	}
+
+	testEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// testBridge is a BridgeService on a FakeClock, a SQLite store in a temporary
+// directory and one MockChain per chain.
+type testBridge struct {
+	*BridgeService
+	t     *testing.T
+	clock *FakeClock
+	db    *SQLStore
+	mocks map[string]*testutil.MockChain
+	key   *ecdsa.PrivateKey
+}
+
+// newTestStore opens a SQLite store at path, or in a fresh temporary
+// directory if path is empty, and closes it when the test ends.
+func newTestStore(t *testing.T, path string) *SQLStore {
+	t.Helper()
+	if path == "" {
+		path = filepath.Join(t.TempDir(), "bridge.db")
+	}
+	store, err := NewSQLStore("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func newTestBridge(t *testing.T) *testBridge {
+	return newTestBridgeWithStore(t, newTestStore(t, ""))
+}
+
+func newTestBridgeWithStore(t *testing.T, store *SQLStore) *testBridge {
+	t.Helper()
+	clock := NewFakeClock(testEpoch)
+	store.clock = clock
+	tb := &testBridge{
+		BridgeService: newBridgeService(clock),
+		t:             t,
+		clock:         clock,
+		db:            store,
+		mocks:         make(map[string]*testutil.MockChain),
+	}
+	bs := tb.BridgeService
+	bs.store = store
+
+	events, err := LoadEventRegistry()
+	if err != nil {
+		t.Fatalf("load event registry: %v", err)
+	}
+	bs.events = events
+	bs.auth = NewAuthenticator(AuthConfig{})
+	bs.integrity = NewIntegritySampler(IntegrityConfig{})
+	bs.integrity.cfg.applyDefaults()
+	bs.retry.applyDefaults()
+	bs.callbackQueue.applyDefaults()
+
+	for _, name := range []string{testSourceChain, testTargetChain} {
+		chain := ChainConfig{
+			Name:                name,
+			Contract:            testBridges[name].Hex(),
+			ContractVersion:     defaultContractVersion,
+			ChainID:             testChainIDs[name],
+			Confirmations:       testConfirmations,
+			Finality:            finalityDepth,
+			AddressFormat:       addressFormatEVM,
+			PollIntervalSeconds: defaultPollIntervalSeconds,
+			MaxBlocksPerQuery:   defaultMaxBlocksPerQuery,
+		}
+		chain.Gas.applyDefaults()
+		mock := testutil.NewMockChain()
+		mock.NetworkID = chain.ChainID
+		definitions, err := events.Resolve(chain.ContractVersion, listenedEvents)
+		if err != nil {
+			t.Fatalf("resolve events: %v", err)
+		}
+		bs.chains.Register(chain, mock)
+		bs.listening[name] = definitions
+		tb.mocks[name] = mock
+	}
+
+	tb.key, err = crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("generate relayer key: %v", err)
+	}
+	bs.signer = newSigner(tb.key)
+	policyCfg := SignerPolicyConfig{}
+	policyCfg.applyDefaults()
+	policy, err := NewSignerPolicy(policyCfg, bs.chains.Configs(), events)
+	if err != nil {
+		t.Fatalf("signer policy: %v", err)
+	}
+	bs.signer.SetPolicy(policy)
+
+	bs.tokens.Put(testMapping(18, 18))
+	return tb
+}
+
+// testMapping maps testToken on testSourceChain to testWrapped on
+// testTargetChain.
+func testMapping(sourceDecimals, decimals uint8) TokenMapping {
+	return TokenMapping{
+		SourceChain:    testSourceChain,
+		SourceToken:    testToken.Hex(),
+		SourceDecimals: &sourceDecimals,
+		TargetChain:    testTargetChain,
+		TargetToken:    testWrapped.Hex(),
+		Decimals:       &decimals,
+		Symbol:         "TKN",
+	}
+}
+
+// lockLog builds the Locked log of a lock of amount with the given nonce,
+// in its own transaction at block.
+func (tb *testBridge) lockLog(block uint64, nonce byte, amount int64) types.Log {
+	return tb.transferLog("Locked", testSourceChain, testToken, block, nonce, amount)
+}
+
+func (tb *testBridge) transferLog(eventName, chainName string, token common.Address, block uint64, nonce byte, amount int64) types.Log {
+	tb.t.Helper()
+	contractABI, err := tb.events.ABI(defaultContractVersion)
+	if err != nil {
+		tb.t.Fatal(err)
+	}
+	event := contractABI.Events[eventName]
+
+	toChain := testTargetChain
+	if chainName == testTargetChain {
+		toChain = testSourceChain
+	}
+	var targetChain, transferNonce [32]byte
+	copy(targetChain[:], toChain)
+	transferNonce[31] = nonce
+	data, err := event.Inputs.NonIndexed().Pack(targetChain, testRecipient.Bytes(), big.NewInt(amount), transferNonce)
+	if err != nil {
+		tb.t.Fatalf("pack %s: %v", eventName, err)
+	}
+
+	return types.Log{
+		Address:     testBridges[chainName],
+		Topics:      []common.Hash{event.ID, common.BytesToHash(token.Bytes()), common.BytesToHash(testSender.Bytes())},
+		Data:        data,
+		BlockNumber: block,
+		TxHash:      crypto.Keccak256Hash([]byte(chainName), []byte(eventName), []byte{nonce}, new(big.Int).SetUint64(block).Bytes()),
+	}
+}
+
+// emit adds vLog to its chain and feeds it through processLog, as the
+// listener would, returning the log as the chain stored it.
+func (tb *testBridge) emit(chainName string, vLog types.Log) types.Log {
+	tb.mocks[chainName].AddLogs(vLog)
+	stored := tb.chainLog(chainName, vLog.TxHash)
+	tb.processLog(chainName, stored)
+	return stored
+}
+
+// reorgOut retracts vLog from its chain and delivers the removal, as the
+// subscription would.
+func (tb *testBridge) reorgOut(chainName string, vLog types.Log) {
+	tb.mocks[chainName].RemoveLogs(vLog.TxHash)
+	vLog.Removed = true
+	tb.processRemovedLog(chainName, vLog)
+}
+
+// reinclude puts vLog back on its chain in a later block and feeds it
+// through processLog.
+func (tb *testBridge) reinclude(chainName string, vLog types.Log, block uint64) types.Log {
+	vLog.BlockNumber = block
+	vLog.BlockHash = common.Hash{}
+	return tb.emit(chainName, vLog)
+}
+
+func (tb *testBridge) chainLog(chainName string, txHash common.Hash) types.Log {
+	tb.t.Helper()
+	logs, err := tb.mocks[chainName].FilterLogs(context.Background(), bridgeFilterQuery(testBridges[chainName], tb.listening[chainName]))
+	if err != nil {
+		tb.t.Fatal(err)
+	}
+	for _, vLog := range logs {
+		if vLog.TxHash == txHash {
+			return vLog
+		}
+	}
+	tb.t.Fatalf("log of %s not on %s", txHash.Hex(), chainName)
+	return types.Log{}
+}
+
+// drain handles every queued event, as ProcessBridgeEvents would, and
+// reports whether there were any.
+func (tb *testBridge) drain() bool {
+	handled := false
+	for {
+		select {
+		case event := <-tb.eventChan:
+			tb.handleBridgeEvent(event)
+			handled = true
+		default:
+			return handled
+		}
+	}
+}
+
+// settle waits for running settlements and handles their outcomes until
+// nothing is left to do.
+func (tb *testBridge) settle() {
+	for {
+		tb.settlements.Wait()
+		if !tb.drain() {
+			return
+		}
+	}
+}
+
+// confirm buries every tracked transfer deep enough and lets the tracker
+// promote them.
+func (tb *testBridge) confirm() {
+	for _, mock := range tb.mocks {
+		mock.Mine(testConfirmations)
+	}
+	tb.checkConfirmations(context.Background())
+	tb.settle()
+}
+
+// retryAfter moves the clock on by d and runs the retries then due.
+func (tb *testBridge) retryAfter(d time.Duration) {
+	tb.clock.Advance(d)
+	tb.runDueRetries(context.Background())
+	tb.settle()
+}
+
+func (tb *testBridge) status(id string) TransferStatus {
+	tb.t.Helper()
+	event, err := tb.store.GetByID(id)
+	if err != nil {
+		tb.t.Fatalf("load %s: %v", id, err)
+	}
+	return event.Status
+}
+
+// minted decodes the settlement calls sent on chainName.
+func (tb *testBridge) minted(chainName string) []settlementCall {
+	tb.t.Helper()
+	contractABI, err := tb.events.ABI(defaultContractVersion)
+	if err != nil {
+		tb.t.Fatal(err)
+	}
+	var calls []settlementCall
+	for _, tx := range tb.mocks[chainName].Sent() {
+		method, err := contractABI.MethodById(tx.Data())
+		if err != nil {
+			tb.t.Fatalf("decode %s: %v", tx.Hash().Hex(), err)
+		}
+		args, err := method.Inputs.Unpack(tx.Data()[4:])
+		if err != nil {
+			tb.t.Fatalf("unpack %s: %v", tx.Hash().Hex(), err)
+		}
+		calls = append(calls, settlementCall{
+			Method:    method.Name,
+			Token:     args[0].(common.Address),
+			Recipient: args[1].(common.Address),
+			Amount:    args[2].(*big.Int),
+			Nonce:     args[3].([32]byte),
+			Tx:        tx,
+		})
+	}
+	return calls
+}
+
+type settlementCall struct {
+	Method    string
+	Token     common.Address
+	Recipient common.Address
+	Amount    *big.Int
+	Nonce     [32]byte
+	Tx        *types.Transaction
+}
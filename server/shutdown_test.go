@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+// A lock still queued when the service stops is saved by shutdown and
+// settled after the next start.
+func TestShutdownPersistsQueuedLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bridge.db")
+	before := newTestBridgeWithStore(t, newTestStore(t, path))
+	vLog := before.lockLog(5, 1, 1000)
+	lock := before.emit(testSourceChain, vLog)
+	id := lockEventID(testSourceChain, lock)
+
+	// Drop the row written at intake, so only shutdown can have saved it.
+	if _, err := before.db.db.Exec(before.db.rebind(`DELETE FROM bridge_events WHERE id = ?`), id); err != nil {
+		t.Fatal(err)
+	}
+	if len(before.eventChan) != 1 {
+		t.Fatalf("%d events queued, want the lock", len(before.eventChan))
+	}
+	before.shutdown(&http.Server{})
+	before.db.Close()
+
+	after := newTestBridgeWithStore(t, newTestStore(t, path))
+	after.mocks[testSourceChain].AddLogs(vLog)
+	if status := after.status(id); status != StatusPendingConfirmation {
+		t.Fatalf("%s restarted as %s", id, status)
+	}
+	after.replayPending()
+	if !after.drain() {
+		t.Fatal("queued lock was not replayed")
+	}
+	after.confirm()
+
+	if status := after.status(id); status != StatusCompleted {
+		t.Errorf("%s is %s after restart, want completed", id, status)
+	}
+	if mints := after.minted(testTargetChain); len(mints) != 1 {
+		t.Errorf("minted %d times, want once", len(mints))
+	}
+}
+
+// A mint that landed but whose outcome was still queued is recorded as
+// completed by shutdown, and not settled again after the next start.
+func TestShutdownPersistsQueuedSettlementOutcome(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bridge.db")
+	before := newTestBridgeWithStore(t, newTestStore(t, path))
+	vLog := before.lockLog(5, 1, 1000)
+	lock := before.emit(testSourceChain, vLog)
+	before.drain()
+	id := lockEventID(testSourceChain, lock)
+
+	for _, mock := range before.mocks {
+		mock.Mine(testConfirmations)
+	}
+	before.checkConfirmations(context.Background())
+	before.settlements.Wait()
+	if len(before.eventChan) == 0 {
+		t.Fatal("no settlement outcome queued")
+	}
+	before.shutdown(&http.Server{})
+	if status := before.status(id); status != StatusCompleted {
+		t.Fatalf("%s is %s after shutdown, want completed", id, status)
+	}
+	before.db.Close()
+
+	after := newTestBridgeWithStore(t, newTestStore(t, path))
+	after.mocks[testSourceChain].AddLogs(vLog)
+	after.replayPending()
+	if after.drain() {
+		t.Error("replayed a transfer that was already settled")
+	}
+	after.confirm()
+	if mints := before.minted(testTargetChain); len(mints) != 1 {
+		t.Errorf("minted %d times before the restart, want once", len(mints))
+	}
+	if mints := after.minted(testTargetChain); len(mints) != 0 {
+		t.Errorf("minted %d times after the restart, want none", len(mints))
+	}
+}
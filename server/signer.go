@@ -0,0 +1,70 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+type Signer struct {
+	key     *ecdsa.PrivateKey
+	address common.Address
+	policy  *SignerPolicy
+}
+
+// LoadRelayerSigner reads the relayer key from an encrypted keystore file
+// (RELAYER_KEYSTORE + RELAYER_KEYSTORE_PASSWORD) or, failing that, from a raw
+// hex key in RELAYER_PRIVATE_KEY.
+func LoadRelayerSigner() (*Signer, error) {
+	if path := os.Getenv("RELAYER_KEYSTORE"); path != "" {
+		keyJSON, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read relayer keystore: %v", err)
+		}
+		key, err := keystore.DecryptKey(keyJSON, os.Getenv("RELAYER_KEYSTORE_PASSWORD"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt relayer keystore: %v", err)
+		}
+		return newSigner(key.PrivateKey), nil
+	}
+
+	if hexKey := os.Getenv("RELAYER_PRIVATE_KEY"); hexKey != "" {
+		key, err := crypto.HexToECDSA(strings.TrimPrefix(hexKey, "0x"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid RELAYER_PRIVATE_KEY: %v", err)
+		}
+		return newSigner(key), nil
+	}
+
+	return nil, fmt.Errorf("no relayer key configured: set RELAYER_KEYSTORE or RELAYER_PRIVATE_KEY")
+}
+
+func newSigner(key *ecdsa.PrivateKey) *Signer {
+	return &Signer{key: key, address: crypto.PubkeyToAddress(key.PublicKey)}
+}
+
+func (s *Signer) Address() common.Address {
+	return s.address
+}
+
+// SetPolicy must be called before SignTx; without a policy nothing is signed.
+func (s *Signer) SetPolicy(policy *SignerPolicy) {
+	s.policy = policy
+}
+
+func (s *Signer) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	if s.policy == nil {
+		return nil, fmt.Errorf("%w: no signer policy loaded", ErrPolicyViolation)
+	}
+	if err := s.policy.Check(tx, chainID); err != nil {
+		return nil, err
+	}
+	return types.SignTx(tx, types.LatestSignerForChainID(chainID), s.key)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"log"
+	"slices"
+)
+
+// TransferStatus is where a transfer is in its lifecycle. A lock goes
+//
+//	pending_confirmation → confirmed → minting → completed
+//
+// and a burn burned → unlocking → completed. A settlement that fails goes to
+// retrying and, once its attempts run out, to failed; one not sent because
+// gas is above the chain's cap waits in fee_cap_exceeded. Before settlement a
+// transfer can be held (paused, limit_exceeded), dropped by a reorg
+// (reorged, until its log is re-included) or rejected (unsupported_token, unsupported_amount). One whose
+// recipient isn't a valid address on the destination chain is recorded as
+// invalid_recipient and never settled. Any transfer can be flagged for
+// manual_review.
+type TransferStatus string
+
+const (
+	// StatusLocked is the status of locks recorded before confirmation
+	// tracking existed.
+	StatusLocked              TransferStatus = "locked"
+	StatusPendingConfirmation TransferStatus = "pending_confirmation"
+	StatusBurned              TransferStatus = "burned"
+	StatusConfirmed           TransferStatus = "confirmed"
+	StatusMinting             TransferStatus = "minting"
+	StatusUnlocking           TransferStatus = "unlocking"
+	StatusRetrying            TransferStatus = "retrying"
+	StatusCompleted           TransferStatus = "completed"
+	StatusFailed              TransferStatus = "failed"
+	StatusPaused              TransferStatus = "paused"
+	StatusLimitExceeded       TransferStatus = "limit_exceeded"
+	StatusReorged             TransferStatus = "reorged"
+	StatusManualReview        TransferStatus = "manual_review"
+	StatusUnsupportedToken    TransferStatus = "unsupported_token"
+	StatusUnsupportedAmount   TransferStatus = "unsupported_amount"
+	StatusInvalidRecipient    TransferStatus = "invalid_recipient"
+	StatusFeeCapExceeded      TransferStatus = "fee_cap_exceeded"
+)
+
+// statusTransitions lists where each status may move. Moving to
+// manual_review is always allowed and staying put is never a transition, so
+// neither is listed. Statuses without an entry are terminal.
+//
+// In-flight statuses may go back to confirmed, unlocking, paused or
+// limit_exceeded because replay after a restart promotes them again.
+var statusTransitions = map[TransferStatus][]TransferStatus{
+	StatusLocked:              {StatusConfirmed, StatusPaused, StatusLimitExceeded, StatusReorged},
+	StatusPendingConfirmation: {StatusConfirmed, StatusPaused, StatusLimitExceeded, StatusReorged},
+	StatusBurned:              {StatusUnlocking, StatusPaused, StatusLimitExceeded, StatusReorged},
+	StatusPaused:              {StatusConfirmed, StatusUnlocking, StatusLimitExceeded},
+	StatusLimitExceeded:       {StatusConfirmed, StatusUnlocking, StatusPaused},
+	StatusReorged:             {StatusPendingConfirmation, StatusBurned, StatusInvalidRecipient},
+	StatusConfirmed:           {StatusMinting, StatusPaused, StatusLimitExceeded, StatusUnsupportedToken, StatusUnsupportedAmount},
+	StatusMinting:             {StatusCompleted, StatusRetrying, StatusFeeCapExceeded, StatusFailed, StatusConfirmed, StatusPaused, StatusLimitExceeded, StatusUnsupportedToken, StatusUnsupportedAmount},
+	StatusUnlocking:           {StatusCompleted, StatusRetrying, StatusFeeCapExceeded, StatusFailed, StatusPaused, StatusLimitExceeded, StatusUnsupportedToken, StatusUnsupportedAmount},
+	StatusRetrying:            {StatusCompleted, StatusFeeCapExceeded, StatusFailed, StatusUnsupportedToken, StatusUnsupportedAmount},
+	StatusFeeCapExceeded:      {StatusCompleted, StatusRetrying, StatusFailed, StatusUnsupportedToken, StatusUnsupportedAmount},
+}
+
+func canTransition(from, to TransferStatus) bool {
+	if from == to || to == StatusManualReview {
+		return true
+	}
+	for _, next := range statusTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// knownStatus reports whether status is one the lifecycle can reach: a key
+// or target of statusTransitions, or manual_review.
+func knownStatus(status TransferStatus) bool {
+	if status == StatusManualReview {
+		return true
+	}
+	for from, targets := range statusTransitions {
+		if from == status || slices.Contains(targets, status) {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionStatus moves event to status to if the lifecycle allows it. A
+// rejected move is logged and counted, and event is left as it was.
+func (bs *BridgeService) TransitionStatus(event *BridgeEvent, to TransferStatus) bool {
+	if !canTransition(event.Status, to) {
+		invalidTransitions.WithLabelValues(string(event.Status), string(to)).Inc()
+		log.Printf("Rejected status change of %s %s from %s to %s", event.Type, event.ID, event.Status, to)
+		return false
+	}
+	event.Status = to
+	return true
+}
+
+// transitionSettlement checks a mint or unlock outcome against the stored
+// status of its transfer, so a late or repeated outcome can't move a
+// transfer backwards. If the stored status can't be read the outcome is
+// applied as before.
+func (bs *BridgeService) transitionSettlement(settlement *BridgeEvent) bool {
+	stored, err := bs.store.GetByID(settlement.ID)
+	if err != nil {
+		log.Printf("Failed to load status of %s, applying %s unchecked: %v", settlement.ID, settlement.Status, err)
+		return true
+	}
+	to := settlement.Status
+	settlement.Status = stored.Status
+	if !bs.TransitionStatus(settlement, to) {
+		settlement.Status = to
+		return false
+	}
+	return true
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+// stickMint sends the mint of a lock to a target chain that holds it unmined
+// and runs the settlement until it gives up bumping its fee, returning the
+// lock's event ID.
+func (tb *testBridge) stickMint() string {
+	tb.t.Helper()
+	target := tb.mocks[testTargetChain]
+	target.Hold = true
+	vLog := tb.emit(testSourceChain, tb.lockLog(5, 1, 1000))
+	id := lockEventID(testSourceChain, vLog)
+	tb.drain()
+	tb.mocks[testSourceChain].Mine(testConfirmations)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	tb.runLoops(ctx, tb.TrackConfirmations)
+	tb.advanceUntil(receiptPollInterval, func() bool { return tb.status(id) == StatusRetrying })
+	cancel()
+	tb.settlements.Wait()
+
+	chain, _ := tb.chains.GetChain(testTargetChain)
+	if sent := len(target.Sent()); sent != 1+chain.Gas.MaxBumps {
+		tb.t.Fatalf("sent %d transactions, want the mint and %d fee bumps", sent, chain.Gas.MaxBumps)
+	}
+	return id
+}
+
+// assertNextNonce checks the nonce the target chain's manager hands out next
+// and gives it back.
+func (tb *testBridge) assertNextNonce(want uint64) {
+	tb.t.Helper()
+	nonces := tb.nonceManager(testTargetChain)
+	nonce, err := nonces.Allocate(context.Background())
+	if err != nil {
+		tb.t.Fatal(err)
+	}
+	nonces.Release(nonce)
+	if nonce != want {
+		tb.t.Errorf("next nonce = %d, want %d", nonce, want)
+	}
+}
+
+// A mint still unmined after its last fee bump keeps its nonce, and the
+// retry replaces it with a transaction of the same nonce rather than sending
+// a second mint that could be mined beside it. The replacement outbids the
+// last fee bump, which the node insists on, so it doesn't use up attempts
+// being refused.
+func TestStuckMintRetriesUnderItsNonce(t *testing.T) {
+	tb := newTestBridge(t)
+	id := tb.stickMint()
+	tb.assertNextNonce(1)
+	stuck := tb.mocks[testTargetChain].Sent()
+	last := stuck[len(stuck)-1]
+
+	tb.mocks[testTargetChain].Hold = false
+	tb.retryAfter(tb.retry.backoff(1))
+	if status := tb.status(id); status != StatusCompleted {
+		t.Fatalf("status = %s, want completed", status)
+	}
+	sent := tb.mocks[testTargetChain].Sent()
+	if len(sent) != len(stuck)+1 {
+		t.Fatalf("sent %d transactions on retry, want the one replacement", len(sent)-len(stuck))
+	}
+	for _, tx := range sent {
+		if tx.Nonce() != 0 {
+			t.Errorf("%s sent with nonce %d, want every attempt on nonce 0", tx.Hash().Hex(), tx.Nonce())
+		}
+	}
+	if replacement := sent[len(sent)-1]; replacement.GasPrice().Cmp(last.GasPrice()) <= 0 {
+		t.Errorf("replacement pays %s, not more than the stuck %s", replacement.GasPrice(), last.GasPrice())
+	}
+	tb.assertNextNonce(1)
+}
+
+// A stuck mint mined before its retry is due settles the transfer, and its
+// nonce is not handed out again.
+func TestStuckMintMinedBeforeRetry(t *testing.T) {
+	tb := newTestBridge(t)
+	id := tb.stickMint()
+	sent := len(tb.mocks[testTargetChain].Sent())
+
+	tb.mocks[testTargetChain].Hold = false
+	tb.mocks[testTargetChain].MineHeld()
+	tb.retryAfter(tb.retry.backoff(1))
+	if status := tb.status(id); status != StatusCompleted {
+		t.Fatalf("status = %s, want completed", status)
+	}
+	if now := len(tb.mocks[testTargetChain].Sent()); now != sent {
+		t.Errorf("sent %d more transactions after the mint was mined", now-sent)
+	}
+	tb.assertNextNonce(1)
+}
+
+// The nonce a retry holds is saved with it and held again after a restart.
+func TestHeldNonceSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bridge.db")
+	before := newTestBridgeWithStore(t, newTestStore(t, path))
+	id := before.stickMint()
+	before.db.Close()
+
+	after := newTestBridgeWithStore(t, newTestStore(t, path))
+	after.syncNonces()
+	after.assertNextNonce(1)
+
+	after.retryAfter(before.clock.Now().Sub(testEpoch) + after.retry.backoff(1))
+	if status := after.status(id); status != StatusCompleted {
+		t.Fatalf("status = %s, want completed", status)
+	}
+	stuck := before.mocks[testTargetChain].Sent()
+	sent := after.mocks[testTargetChain].Sent()
+	if len(sent) != 1 || sent[0].Nonce() != 0 {
+		t.Fatalf("sent %d transactions after restart, want one with nonce 0", len(sent))
+	}
+	if last := stuck[len(stuck)-1]; sent[0].GasPrice().Cmp(last.GasPrice()) <= 0 {
+		t.Errorf("replacement after restart pays %s, not more than the stuck %s", sent[0].GasPrice(), last.GasPrice())
+	}
+}
@@ -0,0 +1,111 @@
+package testutil
+
+import (
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// subscription implements ethereum.Subscription. Deliveries are queued and
+// pumped to the consumer's channel in order by one goroutine, so MockChain
+// never blocks on a slow reader while holding its lock.
+type subscription struct {
+	mu     sync.Mutex
+	queue  []func()
+	wake   chan struct{}
+	quit   chan struct{}
+	errc   chan error
+	closed bool
+}
+
+func newSubscription() subscription {
+	return subscription{
+		wake: make(chan struct{}, 1),
+		quit: make(chan struct{}),
+		errc: make(chan error, 1),
+	}
+}
+
+func (s *subscription) enqueue(send func()) {
+	s.mu.Lock()
+	s.queue = append(s.queue, send)
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *subscription) pump() {
+	for {
+		select {
+		case <-s.quit:
+			return
+		case <-s.wake:
+		}
+		for {
+			s.mu.Lock()
+			if len(s.queue) == 0 {
+				s.mu.Unlock()
+				break
+			}
+			send := s.queue[0]
+			s.queue = s.queue[1:]
+			s.mu.Unlock()
+			send()
+		}
+	}
+}
+
+func (s *subscription) Unsubscribe() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.quit)
+		close(s.errc)
+	}
+}
+
+func (s *subscription) Err() <-chan error { return s.errc }
+
+func (s *subscription) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.quit)
+		s.errc <- err
+	}
+}
+
+type logSubscription struct {
+	subscription
+	query ethereum.FilterQuery
+	ch    chan<- types.Log
+}
+
+func (s *logSubscription) deliver(vLog types.Log) {
+	s.enqueue(func() {
+		select {
+		case s.ch <- vLog:
+		case <-s.quit:
+		}
+	})
+}
+
+type headSubscription struct {
+	subscription
+	ch chan<- *types.Header
+}
+
+func (s *headSubscription) deliver(header *types.Header) {
+	header = types.CopyHeader(header)
+	s.enqueue(func() {
+		select {
+		case s.ch <- header:
+		case <-s.quit:
+		}
+	})
+}
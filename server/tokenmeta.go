@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	tokenMetadataTimeout = 5 * time.Second
+
+	// At most tokenLookupsPerWindow uncached lookups run per chain per
+	// tokenLookupWindow, so a burst of locks of spam tokens can't flood the
+	// RPC provider. Tokens over the limit are looked up on a later transfer.
+	tokenLookupsPerWindow = 10
+	tokenLookupWindow     = time.Minute
+)
+
+var (
+	symbolSelector   = []byte{0x95, 0xd8, 0x9b, 0x41}
+	nameSelector     = []byte{0x06, 0xfd, 0xde, 0x03}
+	decimalsSelector = []byte{0x31, 0x3c, 0xe5, 0x67}
+
+	stringType, _ = abi.NewType("string", "", nil)
+)
+
+// TokenMetadata is what a token contract reports about itself. A field the
+// contract doesn't implement stays empty (nil for Decimals).
+type TokenMetadata struct {
+	Symbol   string `json:"symbol"`
+	Name     string `json:"name"`
+	Decimals *uint8 `json:"decimals"`
+}
+
+type lookupWindow struct {
+	start time.Time
+	count int
+}
+
+// TokenMetadataCache fronts the store's token_metadata table. Only complete
+// lookups are cached, including ones where the contract reverted.
+type TokenMetadataCache struct {
+	clock Clock
+
+	mu      sync.Mutex
+	entries map[string]TokenMetadata
+	windows map[string]*lookupWindow
+}
+
+func NewTokenMetadataCache(clock Clock) *TokenMetadataCache {
+	return &TokenMetadataCache{
+		clock:   clock,
+		entries: make(map[string]TokenMetadata),
+		windows: make(map[string]*lookupWindow),
+	}
+}
+
+func (c *TokenMetadataCache) get(chainName, token string) (TokenMetadata, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	meta, ok := c.entries[chainName+"/"+token]
+	return meta, ok
+}
+
+func (c *TokenMetadataCache) put(chainName, token string, meta TokenMetadata) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[chainName+"/"+token] = meta
+}
+
+// allow takes one lookup from chainName's budget for the current window.
+func (c *TokenMetadataCache) allow(chainName string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.clock.Now()
+	window, ok := c.windows[chainName]
+	if !ok || now.Sub(window.start) >= tokenLookupWindow {
+		window = &lookupWindow{start: now}
+		c.windows[chainName] = window
+	}
+	if window.count >= tokenLookupsPerWindow {
+		return false
+	}
+	window.count++
+	return true
+}
+
+// enrichToken fills in the token's symbol and decimals when they are known
+// or can be looked up now. Failing to do so never holds up a transfer.
+func (bs *BridgeService) enrichToken(event *BridgeEvent) {
+	meta, ok := bs.tokenMetadata(event.FromChain, event.Token)
+	if !ok {
+		return
+	}
+	event.TokenSymbol = meta.Symbol
+	event.TokenDecimals = meta.Decimals
+}
+
+func (bs *BridgeService) tokenMetadata(chainName, token string) (TokenMetadata, bool) {
+	if meta, ok := bs.tokenMeta.get(chainName, token); ok {
+		return meta, true
+	}
+
+	meta, found, err := bs.store.GetTokenMetadata(chainName, token)
+	if err != nil {
+		log.Printf("Failed to read metadata of %s on %s: %v", token, chainName, err)
+		return TokenMetadata{}, false
+	}
+	if found {
+		bs.tokenMeta.put(chainName, token, meta)
+		return meta, true
+	}
+
+	if !bs.tokenMeta.allow(chainName) {
+		log.Printf("Token metadata lookups on %s are rate limited, skipping %s", chainName, token)
+		return TokenMetadata{}, false
+	}
+	meta, err = bs.fetchTokenMetadata(chainName, common.HexToAddress(token))
+	if err != nil {
+		log.Printf("Failed to fetch metadata of %s on %s: %v", token, chainName, err)
+		return TokenMetadata{}, false
+	}
+	if err := bs.store.SaveTokenMetadata(chainName, token, meta); err != nil {
+		log.Printf("Failed to save metadata of %s on %s: %v", token, chainName, err)
+	}
+	bs.tokenMeta.put(chainName, token, meta)
+	return meta, true
+}
+
+// fetchTokenMetadata calls symbol(), name() and decimals() on token. A call
+// the contract rejects leaves that field empty; only a transport failure is
+// an error, since it says nothing about the token.
+func (bs *BridgeService) fetchTokenMetadata(chainName string, token common.Address) (TokenMetadata, error) {
+	client, ok := bs.chains.GetClient(chainName)
+	if !ok {
+		return TokenMetadata{}, fmt.Errorf("no client for chain %s", chainName)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), tokenMetadataTimeout)
+	defer cancel()
+
+	call := func(selector []byte) ([]byte, error) {
+		out, err := client.CallContract(ctx, ethereum.CallMsg{To: &token, Data: selector}, nil)
+		var rpcErr rpc.Error
+		if errors.As(err, &rpcErr) {
+			return nil, nil
+		}
+		if err != nil {
+			rpcErrors.WithLabelValues(chainName, "call_contract").Inc()
+		}
+		return out, err
+	}
+
+	var meta TokenMetadata
+	out, err := call(symbolSelector)
+	if err != nil {
+		return TokenMetadata{}, err
+	}
+	meta.Symbol = decodeTokenString(out)
+
+	if out, err = call(nameSelector); err != nil {
+		return TokenMetadata{}, err
+	}
+	meta.Name = decodeTokenString(out)
+
+	if out, err = call(decimalsSelector); err != nil {
+		return TokenMetadata{}, err
+	}
+	meta.Decimals = decodeTokenDecimals(out)
+	return meta, nil
+}
+
+// decodeTokenString reads an ABI string, or the bytes32 some older tokens
+// (MKR, SAI) return instead.
+func decodeTokenString(out []byte) string {
+	if len(out) == 32 {
+		return sanitizeTokenString(string(bytes.TrimRight(out, "\x00")))
+	}
+	values, err := abi.Arguments{{Type: stringType}}.Unpack(out)
+	if err != nil || len(values) != 1 {
+		return ""
+	}
+	value, _ := values[0].(string)
+	return sanitizeTokenString(value)
+}
+
+// sanitizeTokenString keeps printable text only; symbols are shown to users
+// and anyone can deploy a token returning arbitrary bytes.
+func sanitizeTokenString(value string) string {
+	value = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || r == utf8.RuneError {
+			return -1
+		}
+		return r
+	}, value)
+	if runes := []rune(value); len(runes) > 64 {
+		value = string(runes[:64])
+	}
+	return strings.TrimSpace(value)
+}
+
+func decodeTokenDecimals(out []byte) *uint8 {
+	if len(out) != 32 {
+		return nil
+	}
+	for _, b := range out[:31] {
+		if b != 0 {
+			return nil
+		}
+	}
+	decimals := out[31]
+	return &decimals
+}
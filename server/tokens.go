@@ -0,0 +1,271 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gorilla/mux"
+)
+
+// TokenMapping pairs a token on its home chain with the wrapped token that
+// represents it on TargetChain. Locks of SourceToken mint TargetToken; burns
+// of TargetToken unlock SourceToken. Decimals is the wrapped token's. Both
+// decimals are required: an omitted one must not read as 0, which would
+// rescale every amount by a power of ten.
+type TokenMapping struct {
+	SourceChain    string `json:"sourceChain" yaml:"sourceChain"`
+	SourceToken    string `json:"sourceToken" yaml:"sourceToken"`
+	SourceDecimals *uint8 `json:"sourceDecimals" yaml:"sourceDecimals"`
+	TargetChain    string `json:"targetChain" yaml:"targetChain"`
+	TargetToken    string `json:"targetToken" yaml:"targetToken"`
+	Decimals       *uint8 `json:"decimals" yaml:"decimals"`
+	Symbol         string `json:"symbol" yaml:"symbol"`
+}
+
+// validate checks m against the configured chains and checksums its
+// addresses in place.
+func (m *TokenMapping) validate(chains map[string]bool) error {
+	if !chains[m.SourceChain] {
+		return fmt.Errorf("unknown source chain %q", m.SourceChain)
+	}
+	if !chains[m.TargetChain] {
+		return fmt.Errorf("unknown target chain %q", m.TargetChain)
+	}
+	if m.SourceChain == m.TargetChain {
+		return fmt.Errorf("source and target chain are both %s", m.SourceChain)
+	}
+	if !common.IsHexAddress(m.SourceToken) {
+		return fmt.Errorf("sourceToken %q is not a valid address", m.SourceToken)
+	}
+	if !common.IsHexAddress(m.TargetToken) {
+		return fmt.Errorf("targetToken %q is not a valid address", m.TargetToken)
+	}
+	if m.SourceDecimals == nil {
+		return errors.New("sourceDecimals is required")
+	}
+	if m.Decimals == nil {
+		return errors.New("decimals is required")
+	}
+	m.SourceToken = common.HexToAddress(m.SourceToken).Hex()
+	m.TargetToken = common.HexToAddress(m.TargetToken).Hex()
+	return nil
+}
+
+type tokenKey struct {
+	chain string
+	token string
+	peer  string
+}
+
+// TokenRegistry answers which token a settlement pays out. It is indexed both
+// ways: by home token for mints and by wrapped token for unlocks.
+type TokenRegistry struct {
+	mu      sync.RWMutex
+	forward map[tokenKey]TokenMapping
+	reverse map[tokenKey]TokenMapping
+}
+
+func NewTokenRegistry() *TokenRegistry {
+	return &TokenRegistry{
+		forward: make(map[tokenKey]TokenMapping),
+		reverse: make(map[tokenKey]TokenMapping),
+	}
+}
+
+func (tr *TokenRegistry) Put(m TokenMapping) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	key := tokenKey{m.SourceChain, m.SourceToken, m.TargetChain}
+	if old, ok := tr.forward[key]; ok {
+		delete(tr.reverse, tokenKey{old.TargetChain, old.TargetToken, old.SourceChain})
+	}
+	tr.forward[key] = m
+	tr.reverse[tokenKey{m.TargetChain, m.TargetToken, m.SourceChain}] = m
+}
+
+func (tr *TokenRegistry) Remove(sourceChain, sourceToken, targetChain string) bool {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	key := tokenKey{sourceChain, sourceToken, targetChain}
+	m, ok := tr.forward[key]
+	if !ok {
+		return false
+	}
+	delete(tr.forward, key)
+	delete(tr.reverse, tokenKey{m.TargetChain, m.TargetToken, m.SourceChain})
+	return true
+}
+
+// Destination returns the mapping for a lock or burn and the token its
+// settlement pays out on event.ToChain.
+func (tr *TokenRegistry) Destination(event BridgeEvent) (TokenMapping, common.Address, bool) {
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+
+	key := tokenKey{event.FromChain, common.HexToAddress(event.Token).Hex(), event.ToChain}
+	switch event.Type {
+	case "lock":
+		if m, ok := tr.forward[key]; ok {
+			return m, common.HexToAddress(m.TargetToken), true
+		}
+	case "burn":
+		if m, ok := tr.reverse[key]; ok {
+			return m, common.HexToAddress(m.SourceToken), true
+		}
+	}
+	return TokenMapping{}, common.Address{}, false
+}
+
+// amountDecimals returns the decimals of the token a lock or burn moved and
+// of the token its settlement pays out. m must have passed validate.
+func (m TokenMapping) amountDecimals(eventType string) (from, to uint8) {
+	from, to = *m.SourceDecimals, *m.Decimals
+	if eventType == "burn" {
+		from, to = to, from
+	}
+	return from, to
+}
+
+// payoutAmount converts the amount of a lock or burn into the smallest unit
+// of the token its settlement pays out. A conversion that would drop dust or
+// exceed uint256 fails rather than round.
+func (m TokenMapping) payoutAmount(event BridgeEvent) (*big.Int, error) {
+	value, ok := new(big.Int).SetString(event.Amount, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid amount %q", event.Amount)
+	}
+	from, to := m.amountDecimals(event.Type)
+	amount, err := NewAmount(value, from)
+	if err != nil {
+		return nil, err
+	}
+	scaled, err := Scale(amount, to)
+	if err != nil {
+		return nil, err
+	}
+	return scaled.Value, nil
+}
+
+func (tr *TokenRegistry) List() []TokenMapping {
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+
+	mappings := make([]TokenMapping, 0, len(tr.forward))
+	for _, m := range tr.forward {
+		mappings = append(mappings, m)
+	}
+	sort.Slice(mappings, func(i, j int) bool {
+		a, b := mappings[i], mappings[j]
+		if a.SourceChain != b.SourceChain {
+			return a.SourceChain < b.SourceChain
+		}
+		if a.SourceToken != b.SourceToken {
+			return a.SourceToken < b.SourceToken
+		}
+		return a.TargetChain < b.TargetChain
+	})
+	return mappings
+}
+
+func (bs *BridgeService) chainSet() map[string]bool {
+	names := bs.chains.Names()
+	chains := make(map[string]bool, len(names))
+	for _, name := range names {
+		chains[name] = true
+	}
+	return chains
+}
+
+// loadTokens writes the configured mappings to the store and loads the
+// registry from it, so mappings added through the admin API survive a
+// restart. A mapping removed at runtime comes back if it is still in config.
+// Configured mappings are validated and checksummed before they are saved,
+// as the admin API does, so the store never holds one the registry rejects.
+func (bs *BridgeService) loadTokens(configured []TokenMapping) error {
+	chains := bs.chainSet()
+	for _, m := range configured {
+		if err := m.validate(chains); err != nil {
+			return fmt.Errorf("token mapping %s/%s -> %s: %v", m.SourceChain, m.SourceToken, m.TargetChain, err)
+		}
+		if err := bs.store.SaveTokenMapping(m); err != nil {
+			return err
+		}
+	}
+
+	stored, err := bs.store.ListTokenMappings()
+	if err != nil {
+		return err
+	}
+	for _, m := range stored {
+		if err := m.validate(chains); err != nil {
+			log.Printf("Ignoring stored token mapping %s/%s -> %s: %v", m.SourceChain, m.SourceToken, m.TargetChain, err)
+			continue
+		}
+		bs.tokens.Put(m)
+	}
+	log.Printf("Loaded %d token mappings", len(bs.tokens.List()))
+	return nil
+}
+
+func (bs *BridgeService) handleListTokens(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"tokens": bs.tokens.List()})
+}
+
+func (bs *BridgeService) handlePutToken(w http.ResponseWriter, r *http.Request) {
+	var m TokenMapping
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		writeError(w, http.StatusBadRequest, "malformed token mapping")
+		return
+	}
+	if err := m.validate(bs.chainSet()); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := bs.store.SaveTokenMapping(m); err != nil {
+		log.Printf("Failed to save token mapping: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to save token mapping")
+		return
+	}
+	bs.tokens.Put(m)
+	log.Printf("Token mapping set: %s %s (%d decimals) -> %s %s (%s, %d decimals)",
+		m.SourceChain, m.SourceToken, *m.SourceDecimals, m.TargetChain, m.TargetToken, m.Symbol, *m.Decimals)
+	writeJSON(w, http.StatusOK, m)
+}
+
+func (bs *BridgeService) handleDeleteToken(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if !common.IsHexAddress(vars["sourceToken"]) {
+		writeError(w, http.StatusBadRequest, "malformed sourceToken")
+		return
+	}
+	sourceToken := common.HexToAddress(vars["sourceToken"]).Hex()
+
+	removed, err := bs.store.DeleteTokenMapping(vars["sourceChain"], sourceToken, vars["targetChain"])
+	if err != nil {
+		log.Printf("Failed to delete token mapping: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to delete token mapping")
+		return
+	}
+	if !bs.tokens.Remove(vars["sourceChain"], sourceToken, vars["targetChain"]) && !removed {
+		writeError(w, http.StatusNotFound, "token mapping not found")
+		return
+	}
+	log.Printf("Token mapping removed: %s %s -> %s", vars["sourceChain"], sourceToken, vars["targetChain"])
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (bs *BridgeService) registerTokenRoutes(router *mux.Router) {
+	admin := bs.auth.Require(roleAdmin)
+	router.Handle("/admin/tokens", admin(http.HandlerFunc(bs.handleListTokens))).Methods(http.MethodGet)
+	router.Handle("/admin/tokens", admin(http.HandlerFunc(bs.handlePutToken))).Methods(http.MethodPost)
+	router.Handle("/admin/tokens/{sourceChain}/{sourceToken}/{targetChain}", admin(http.HandlerFunc(bs.handleDeleteToken))).Methods(http.MethodDelete)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestTransferKeysNeverAlias(t *testing.T) {
+	var nonce [32]byte
+	nonce[31] = 1
+	keys := []TransferKey{
+		EVMTransferKey("ethereum", nonce),
+		EVMTransferKey("polygon", nonce),
+		SlotTransferKey("solana", 5, "sig", 0),
+		SlotTransferKey("solana-devnet", 5, "sig", 0),
+		HeightTransferKey("cosmoshub", 5, "sig", 0),
+		HeightTransferKey("osmosis", 5, "sig", 0),
+	}
+	seen := make(map[string]TransferKey)
+	for _, key := range keys {
+		s := key.String()
+		if other, ok := seen[s]; ok {
+			t.Errorf("%+v and %+v share the key %s", key, other, s)
+		}
+		seen[s] = key
+
+		parsed, err := ParseTransferKey(s)
+		if err != nil {
+			t.Errorf("parse %s: %v", s, err)
+		} else if parsed != key {
+			t.Errorf("%s parsed as %+v, want %+v", s, parsed, key)
+		}
+	}
+}
+
+func TestParseTransferKeyRejectsMalformed(t *testing.T) {
+	for _, s := range []string{"", "ethereum", ":0x01", "ethereum:", "ethereum:0x01", "solana:5/sig", "solana:x/sig/0", "solana:5//0"} {
+		if key, err := ParseTransferKey(s); err == nil {
+			t.Errorf("%q parsed as %+v", s, key)
+		}
+	}
+}
+
+func TestTransferClaimsAreKeyedByTransferKey(t *testing.T) {
+	store := newTestStore(t, "")
+	keys := []string{
+		SlotTransferKey("solana", 5, "sig", 0).String(),
+		SlotTransferKey("solana-devnet", 5, "sig", 0).String(),
+		HeightTransferKey("cosmoshub", 5, "sig", 0).String(),
+	}
+	for i, key := range keys {
+		first, err := store.MarkTransferProcessed(key, key+"-event")
+		if err != nil || !first {
+			t.Fatalf("claim %d (%s) = %v, %v; want a first claim", i, key, first, err)
+		}
+	}
+	for _, key := range keys {
+		first, err := store.MarkTransferProcessed(key, "other-event")
+		if err != nil || first {
+			t.Errorf("second claim of %s = %v, %v; want refused", key, first, err)
+		}
+		claimant, found, err := store.TransferClaimant(key)
+		if err != nil || !found || claimant != key+"-event" {
+			t.Errorf("claimant of %s = %q (found %v, err %v)", key, claimant, found, err)
+		}
+	}
+	if _, err := store.MarkTransferProcessed("no-chain", "event"); err == nil {
+		t.Error("claimed a malformed transfer key")
+	}
+}
+
+// A lock and a burn on different chains may carry the same bytes32 nonce,
+// since each bridge contract counts its own. Neither may be taken for a
+// duplicate of the other.
+func TestSameNonceOnTwoChainsSettlesIndependently(t *testing.T) {
+	tb := newTestBridge(t)
+	lock := tb.emit(testSourceChain, tb.lockLog(5, 1, 300))
+	burn := tb.emit(testTargetChain, tb.transferLog("Burned", testTargetChain, testWrapped, 5, 1, 400))
+	if got := tb.duplicates.Load(); got != 0 {
+		t.Fatalf("counted %d duplicates", got)
+	}
+	tb.drain()
+	tb.confirm()
+
+	lockID, burnID := lockEventID(testSourceChain, lock), lockEventID(testTargetChain, burn)
+	for _, id := range []string{lockID, burnID} {
+		if status := tb.status(id); status != StatusCompleted {
+			t.Errorf("%s is %s, want completed", id, status)
+		}
+	}
+	mints, unlocks := tb.minted(testTargetChain), tb.minted(testSourceChain)
+	if len(mints) != 1 || mints[0].Method != "mint" || mints[0].Amount.Cmp(big.NewInt(300)) != 0 {
+		t.Errorf("minted %+v, want one mint of 300", mints)
+	}
+	if len(unlocks) != 1 || unlocks[0].Method != "unlock" || unlocks[0].Token != testToken || unlocks[0].Amount.Cmp(big.NewInt(400)) != 0 {
+		t.Errorf("unlocked %+v, want one unlock of 400", unlocks)
+	}
+}
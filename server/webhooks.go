@@ -0,0 +1,511 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	webhookPollInterval    = 5 * time.Second
+	webhookDeliveryHeader  = "X-Bridge-Delivery"
+	webhookEventTypeHeader = "X-Bridge-Event-Type"
+)
+
+const (
+	deliveryPending   = "pending"
+	deliveryDelivered = "delivered"
+	deliveryFailed    = "failed"
+)
+
+// WebhooksConfig holds the webhook endpoints and how deliveries to them are
+// retried. An endpoint is disabled after DisableAfterFailures failed attempts
+// in a row and stays so until re-enabled through the admin API.
+type WebhooksConfig struct {
+	Endpoints            []WebhookConfig `json:"endpoints" yaml:"endpoints"`
+	TimeoutSeconds       int             `json:"timeoutSeconds" yaml:"timeoutSeconds"`
+	DisableAfterFailures int             `json:"disableAfterFailures" yaml:"disableAfterFailures"`
+	Retry                RetryConfig     `json:"retry" yaml:"retry"`
+}
+
+func (c *WebhooksConfig) applyDefaults() {
+	if c.TimeoutSeconds == 0 {
+		c.TimeoutSeconds = 10
+	}
+	if c.DisableAfterFailures == 0 {
+		c.DisableAfterFailures = 20
+	}
+	c.Retry.applyDefaults()
+}
+
+// WebhookConfig is one endpoint. Events filters by event type (lock, burn,
+// mint, unlock or alert); empty means every event. The body of each POST is
+// signed with Secret in X-Bridge-Signature, as for status callbacks.
+type WebhookConfig struct {
+	Name   string   `json:"name" yaml:"name"`
+	URL    string   `json:"url" yaml:"url"`
+	Secret string   `json:"secret,omitempty" yaml:"secret"`
+	Events []string `json:"events,omitempty" yaml:"events"`
+}
+
+func (c WebhookConfig) validate() error {
+	if c.Name == "" {
+		return errors.New("name is required")
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url %q is not an http(s) URL", c.URL)
+	}
+	if c.Secret == "" {
+		return errors.New("secret is required")
+	}
+	for _, eventType := range c.Events {
+		if !subscribableTypes[eventType] {
+			return fmt.Errorf("unknown event type %q", eventType)
+		}
+	}
+	return nil
+}
+
+func (c WebhookConfig) wants(eventType string) bool {
+	if len(c.Events) == 0 {
+		return true
+	}
+	for _, t := range c.Events {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Webhook is an endpoint together with its delivery health.
+type Webhook struct {
+	WebhookConfig
+	Disabled            bool `json:"disabled"`
+	ConsecutiveFailures int  `json:"consecutiveFailures"`
+}
+
+// WebhookDelivery is one event queued for, or delivered to, one webhook.
+// Deliveries are kept as history once they succeed or fail for good.
+type WebhookDelivery struct {
+	ID           string          `json:"id"`
+	Webhook      string          `json:"webhook"`
+	EventID      string          `json:"eventId"`
+	EventType    string          `json:"eventType"`
+	Payload      json.RawMessage `json:"payload"`
+	Status       string          `json:"status"`
+	Attempts     int             `json:"attempts"`
+	ResponseCode int             `json:"responseCode,omitempty"`
+	LastError    string          `json:"lastError,omitempty"`
+	NextAttempt  time.Time       `json:"nextAttempt"`
+	CreatedAt    time.Time       `json:"createdAt"`
+	UpdatedAt    time.Time       `json:"updatedAt"`
+}
+
+type registeredWebhook struct {
+	Webhook
+	client *http.Client
+}
+
+// Webhooks holds the registered endpoints. Endpoints can be added, removed
+// and re-enabled while deliveries are in flight.
+type Webhooks struct {
+	mu    sync.RWMutex
+	hooks map[string]*registeredWebhook
+}
+
+func NewWebhooks() *Webhooks {
+	return &Webhooks{hooks: make(map[string]*registeredWebhook)}
+}
+
+func (wh *Webhooks) put(hook Webhook, client *http.Client) {
+	wh.mu.Lock()
+	defer wh.mu.Unlock()
+	wh.hooks[hook.Name] = &registeredWebhook{Webhook: hook, client: client}
+}
+
+func (wh *Webhooks) remove(name string) bool {
+	wh.mu.Lock()
+	defer wh.mu.Unlock()
+	_, ok := wh.hooks[name]
+	delete(wh.hooks, name)
+	return ok
+}
+
+func (wh *Webhooks) get(name string) (registeredWebhook, bool) {
+	wh.mu.RLock()
+	defer wh.mu.RUnlock()
+	hook, ok := wh.hooks[name]
+	if !ok {
+		return registeredWebhook{}, false
+	}
+	return *hook, true
+}
+
+// subscribers returns the enabled webhooks that want eventType.
+func (wh *Webhooks) subscribers(eventType string) []string {
+	wh.mu.RLock()
+	defer wh.mu.RUnlock()
+	var names []string
+	for name, hook := range wh.hooks {
+		if !hook.Disabled && hook.wants(eventType) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// recordResult updates a webhook's health after an attempt and returns it.
+// disabled reports whether this failure disabled the webhook.
+func (wh *Webhooks) recordResult(name string, ok bool, disableAfter int) (hook Webhook, disabled bool, found bool) {
+	wh.mu.Lock()
+	defer wh.mu.Unlock()
+	h, found := wh.hooks[name]
+	if !found {
+		return Webhook{}, false, false
+	}
+	if ok {
+		h.ConsecutiveFailures = 0
+	} else {
+		h.ConsecutiveFailures++
+		if !h.Disabled && h.ConsecutiveFailures >= disableAfter {
+			h.Disabled = true
+			disabled = true
+		}
+	}
+	return h.Webhook, disabled, true
+}
+
+func (wh *Webhooks) enable(name string) (Webhook, bool) {
+	wh.mu.Lock()
+	defer wh.mu.Unlock()
+	h, ok := wh.hooks[name]
+	if !ok {
+		return Webhook{}, false
+	}
+	h.Disabled = false
+	h.ConsecutiveFailures = 0
+	return h.Webhook, true
+}
+
+// List returns the webhooks with their secrets left out.
+func (wh *Webhooks) List() []Webhook {
+	wh.mu.RLock()
+	defer wh.mu.RUnlock()
+	hooks := make([]Webhook, 0, len(wh.hooks))
+	for _, h := range wh.hooks {
+		hook := h.Webhook
+		hook.Secret = ""
+		hooks = append(hooks, hook)
+	}
+	sort.Slice(hooks, func(i, j int) bool { return hooks[i].Name < hooks[j].Name })
+	return hooks
+}
+
+func (bs *BridgeService) webhookClient(name string) *http.Client {
+	return bs.egress.HTTPClient("webhook_"+name, time.Duration(bs.webhookCfg.TimeoutSeconds)*time.Second)
+}
+
+// loadWebhooks writes the configured endpoints to the store and loads every
+// endpoint, with its health, from it.
+func (bs *BridgeService) loadWebhooks(cfg WebhooksConfig) error {
+	bs.webhookCfg = cfg
+	for _, endpoint := range cfg.Endpoints {
+		if err := bs.store.SaveWebhook(endpoint); err != nil {
+			return err
+		}
+	}
+
+	stored, err := bs.store.ListWebhooks()
+	if err != nil {
+		return err
+	}
+	for _, hook := range stored {
+		if err := hook.validate(); err != nil {
+			log.Printf("Ignoring stored webhook %s: %v", hook.Name, err)
+			continue
+		}
+		if hook.Disabled {
+			log.Printf("Webhook %s is disabled after %d consecutive failures", hook.Name, hook.ConsecutiveFailures)
+		}
+		bs.webhooks.put(hook, bs.webhookClient(hook.Name))
+	}
+	log.Printf("Loaded %d webhooks", len(stored))
+	return nil
+}
+
+func newDeliveryID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// notifyWebhooks queues event for every webhook that wants it. The queue is
+// persisted, so delivery is at least once even across restarts.
+func (bs *BridgeService) notifyWebhooks(event BridgeEvent) {
+	names := bs.webhooks.subscribers(event.Type)
+	if len(names) == 0 {
+		return
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to encode webhook payload for %s: %v", event.ID, err)
+		return
+	}
+
+	now := bs.clock.Now()
+	for _, name := range names {
+		delivery := WebhookDelivery{
+			ID:          newDeliveryID(),
+			Webhook:     name,
+			EventID:     event.ID,
+			EventType:   event.Type,
+			Payload:     payload,
+			Status:      deliveryPending,
+			NextAttempt: now,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		if err := bs.store.SaveWebhookDelivery(delivery); err != nil {
+			log.Printf("Failed to queue %s for webhook %s: %v", event.ID, name, err)
+			continue
+		}
+		go bs.deliverWebhook(delivery)
+	}
+}
+
+func (bs *BridgeService) RunWebhooks(ctx context.Context) {
+	Every(ctx, bs.clock, webhookPollInterval, bs.runDueDeliveries)
+}
+
+func (bs *BridgeService) runDueDeliveries(ctx context.Context) {
+	due, err := bs.store.DueWebhookDeliveries(bs.clock.Now())
+	if err != nil {
+		log.Printf("Failed to load due webhook deliveries: %v", err)
+		return
+	}
+	for _, delivery := range due {
+		go bs.deliverWebhook(delivery)
+	}
+}
+
+// deliverWebhook makes one attempt at delivery. Network errors, timeouts and
+// 5xx, 408 and 429 responses are retried with backoff; other responses are
+// final.
+func (bs *BridgeService) deliverWebhook(delivery WebhookDelivery) {
+	if _, busy := bs.delivering.LoadOrStore(delivery.ID, true); busy {
+		return
+	}
+	defer bs.delivering.Delete(delivery.ID)
+
+	hook, ok := bs.webhooks.get(delivery.Webhook)
+	if !ok {
+		bs.finishDelivery(delivery, deliveryFailed, 0, "webhook was removed")
+		return
+	}
+	if hook.Disabled {
+		return
+	}
+
+	delivery.Attempts++
+	code, err := bs.postWebhook(hook, delivery)
+	retryable := err != nil || code >= 500 || code == http.StatusRequestTimeout || code == http.StatusTooManyRequests
+	succeeded := err == nil && code >= 200 && code < 300
+	bs.recordWebhookResult(delivery.Webhook, succeeded)
+
+	switch {
+	case succeeded:
+		webhookDeliveries.WithLabelValues(delivery.Webhook, deliveryDelivered).Inc()
+		bs.finishDelivery(delivery, deliveryDelivered, code, "")
+	case !retryable:
+		log.Printf("Webhook %s rejected delivery %s of %s: HTTP %d", delivery.Webhook, delivery.ID, delivery.EventID, code)
+		webhookDeliveries.WithLabelValues(delivery.Webhook, deliveryFailed).Inc()
+		bs.finishDelivery(delivery, deliveryFailed, code, "HTTP "+strconv.Itoa(code))
+	default:
+		cause := "HTTP " + strconv.Itoa(code)
+		if err != nil {
+			cause = err.Error()
+		}
+		if delivery.Attempts >= bs.webhookCfg.Retry.MaxAttempts {
+			log.Printf("Giving up on delivery %s of %s to webhook %s after %d attempts: %s", delivery.ID, delivery.EventID, delivery.Webhook, delivery.Attempts, cause)
+			webhookDeliveries.WithLabelValues(delivery.Webhook, deliveryFailed).Inc()
+			bs.finishDelivery(delivery, deliveryFailed, code, cause)
+			return
+		}
+		delay := bs.webhookCfg.Retry.backoff(delivery.Attempts)
+		log.Printf("Delivery %s of %s to webhook %s failed, retrying in %s: %s", delivery.ID, delivery.EventID, delivery.Webhook, delay, cause)
+		delivery.NextAttempt = bs.clock.Now().Add(delay)
+		bs.finishDelivery(delivery, deliveryPending, code, cause)
+	}
+}
+
+func (bs *BridgeService) postWebhook(hook registeredWebhook, delivery WebhookDelivery) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookDeliveryHeader, delivery.ID)
+	req.Header.Set(webhookEventTypeHeader, delivery.EventType)
+	req.Header.Set(callbackSignatureHeader, signCallback(hook.Secret, delivery.Payload))
+
+	resp, err := hook.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+func (bs *BridgeService) finishDelivery(delivery WebhookDelivery, status string, code int, lastError string) {
+	delivery.Status = status
+	delivery.ResponseCode = code
+	delivery.LastError = lastError
+	delivery.UpdatedAt = bs.clock.Now()
+	if err := bs.store.SaveWebhookDelivery(delivery); err != nil {
+		log.Printf("Failed to record delivery %s to webhook %s: %v", delivery.ID, delivery.Webhook, err)
+	}
+}
+
+func (bs *BridgeService) recordWebhookResult(name string, succeeded bool) {
+	hook, disabled, found := bs.webhooks.recordResult(name, succeeded, bs.webhookCfg.DisableAfterFailures)
+	if !found {
+		return
+	}
+	if !succeeded {
+		webhookFailures.WithLabelValues(name).Inc()
+	}
+	if disabled {
+		log.Printf("ALERT: webhook %s disabled after %d consecutive failures", name, hook.ConsecutiveFailures)
+	}
+	if err := bs.store.SetWebhookHealth(name, hook.ConsecutiveFailures, hook.Disabled); err != nil {
+		log.Printf("Failed to persist health of webhook %s: %v", name, err)
+	}
+}
+
+func (bs *BridgeService) handleListWebhooks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"webhooks": bs.webhooks.List()})
+}
+
+func (bs *BridgeService) handlePutWebhook(w http.ResponseWriter, r *http.Request) {
+	var cfg WebhookConfig
+	if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+		writeError(w, http.StatusBadRequest, "malformed webhook")
+		return
+	}
+	if err := cfg.validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := bs.store.SaveWebhook(cfg); err != nil {
+		log.Printf("Failed to save webhook: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to save webhook")
+		return
+	}
+
+	hook := Webhook{WebhookConfig: cfg}
+	if existing, ok := bs.webhooks.get(cfg.Name); ok {
+		hook.Disabled, hook.ConsecutiveFailures = existing.Disabled, existing.ConsecutiveFailures
+	}
+	bs.webhooks.put(hook, bs.webhookClient(cfg.Name))
+	log.Printf("AUDIT: %s set webhook %s -> %s (events %v)", requestActor(r), cfg.Name, cfg.URL, cfg.Events)
+	hook.Secret = ""
+	writeJSON(w, http.StatusOK, hook)
+}
+
+func (bs *BridgeService) handleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	removed, err := bs.store.DeleteWebhook(name)
+	if err != nil {
+		log.Printf("Failed to delete webhook: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to delete webhook")
+		return
+	}
+	if !bs.webhooks.remove(name) && !removed {
+		writeError(w, http.StatusNotFound, "webhook not found")
+		return
+	}
+	log.Printf("AUDIT: %s removed webhook %s", requestActor(r), name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleEnableWebhook re-enables a webhook and resets its failure count.
+// Deliveries queued before it was disabled resume on the next poll.
+func (bs *BridgeService) handleEnableWebhook(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	hook, ok := bs.webhooks.enable(name)
+	if !ok {
+		writeError(w, http.StatusNotFound, "webhook not found")
+		return
+	}
+	if err := bs.store.SetWebhookHealth(name, 0, false); err != nil {
+		log.Printf("Failed to persist health of webhook %s: %v", name, err)
+		writeError(w, http.StatusInternalServerError, "failed to enable webhook")
+		return
+	}
+	log.Printf("AUDIT: %s enabled webhook %s", requestActor(r), name)
+	hook.Secret = ""
+	writeJSON(w, http.StatusOK, hook)
+}
+
+// handleListDeliveries returns a webhook's most recent deliveries, newest
+// first, optionally only those with ?status=.
+func (bs *BridgeService) handleListDeliveries(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	if _, ok := bs.webhooks.get(name); !ok {
+		writeError(w, http.StatusNotFound, "webhook not found")
+		return
+	}
+	query := r.URL.Query()
+	status := query.Get("status")
+	if status != "" && status != deliveryPending && status != deliveryDelivered && status != deliveryFailed {
+		writeError(w, http.StatusBadRequest, "status must be pending, delivered or failed")
+		return
+	}
+	limit := defaultPageSize
+	if raw := query.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxPageSize {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxPageSize))
+			return
+		}
+		limit = n
+	}
+
+	deliveries, err := bs.store.ListWebhookDeliveries(name, status, limit)
+	if err != nil {
+		log.Printf("Failed to list deliveries of webhook %s: %v", name, err)
+		writeError(w, http.StatusInternalServerError, "failed to list deliveries")
+		return
+	}
+	if deliveries == nil {
+		deliveries = []WebhookDelivery{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"deliveries": deliveries})
+}
+
+func (bs *BridgeService) registerWebhookRoutes(router *mux.Router) {
+	admin := bs.auth.Require(roleAdmin)
+	router.Handle("/admin/webhooks", admin(http.HandlerFunc(bs.handleListWebhooks))).Methods(http.MethodGet)
+	router.Handle("/admin/webhooks", admin(http.HandlerFunc(bs.handlePutWebhook))).Methods(http.MethodPost)
+	router.Handle("/admin/webhooks/{name}", admin(http.HandlerFunc(bs.handleDeleteWebhook))).Methods(http.MethodDelete)
+	router.Handle("/admin/webhooks/{name}/enable", admin(http.HandlerFunc(bs.handleEnableWebhook))).Methods(http.MethodPost)
+	router.Handle("/admin/webhooks/{name}/deliveries", admin(http.HandlerFunc(bs.handleListDeliveries))).Methods(http.MethodGet)
+}